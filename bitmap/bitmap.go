@@ -1,6 +1,12 @@
 // Package bitmap provides ways for filter to manipulate bit set.
 package bitmap
 
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
 //go:generate mockgen -package mock -destination ../mock/bitmap_mock.go -source=./bitmap.go
 
 type Bitmap interface {
@@ -9,3 +15,123 @@ type Bitmap interface {
 	// SetBits sets all bits on locs.
 	SetBits(locs []uint64) error
 }
+
+// Counter is implemented by Bitmap which is able to count the set bits.
+type Counter interface {
+	// CountBits returns the number of set bits.
+	CountBits() (uint64, error)
+}
+
+// Resetter is implemented by Bitmap which is able to clear all bits.
+type Resetter interface {
+	// Reset clears all bits.
+	Reset() error
+}
+
+// Exporter is implemented by Bitmap which is able to export its bits.
+type Exporter interface {
+	// Bytes returns bits in the format of the raw value of bitmap in redis, where bit 0 is the most significant bit
+	// of the first byte. It could be imported by NewInMemoryFromRedis.
+	Bytes() ([]byte, error)
+}
+
+// Combiner is implemented by Bitmap which is able to combine bitmaps of the same type and m.
+// It returns IncompatibleError if any of srcs is not combinable with the bitmap.
+type Combiner interface {
+	// Union overwrites bits with the union of srcs, the bitmap itself could be one of srcs to merge in place.
+	Union(srcs ...Bitmap) error
+	// Intersect overwrites bits with the intersection of srcs, the bitmap itself could be one of srcs.
+	Intersect(srcs ...Bitmap) error
+}
+
+// ErrIncompatible is matched by IncompatibleError with errors.Is.
+var ErrIncompatible = errors.New("incompatible")
+
+// IncompatibleError is returned if bitmaps or filters are combined but differ in Field, such as `type` or `m`.
+type IncompatibleError struct {
+	Field string
+	Want  any
+	Got   any
+}
+
+func (e *IncompatibleError) Error() string {
+	return fmt.Sprintf("incompatible %s: want %v, got %v", e.Field, e.Want, e.Got)
+}
+
+func (e *IncompatibleError) Is(target error) bool {
+	return target == ErrIncompatible
+}
+
+// ContextBitmap is implemented by Bitmap which performs operations with the per-call context, such as Redis.
+//
+// CheckBits and SetBits without the per-call context are performed with the context the bitmap is created with,
+// e.g. the one of NewRedis. Decorators keep it by calling CheckBits and SetBits of the decorated bitmap.
+type ContextBitmap interface {
+	// CheckBitsContext is the same with CheckBits but performed with ctx.
+	CheckBitsContext(ctx context.Context, locs []uint64) (bool, error)
+	// SetBitsContext is the same with SetBits but performed with ctx.
+	SetBitsContext(ctx context.Context, locs []uint64) error
+}
+
+// CheckBitsContext calls bm.CheckBitsContext if bm is ContextBitmap, otherwise bm.CheckBits.
+func CheckBitsContext(ctx context.Context, bm Bitmap, locs []uint64) (bool, error) {
+	if cbm, ok := bm.(ContextBitmap); ok {
+		return cbm.CheckBitsContext(ctx, locs)
+	}
+	return bm.CheckBits(locs)
+}
+
+// SetBitsAll sets bits on locs of all bms, Redis on the same server are set by a single pipeline.
+func SetBitsAll(ctx context.Context, locs []uint64, bms ...Bitmap) error {
+	var groups [][]*Redis
+	for _, bm := range bms {
+		rbm, ok := bm.(*Redis)
+		if !ok {
+			if err := SetBitsContext(ctx, bm, locs); err != nil {
+				return err
+			}
+			continue
+		}
+		grouped := false
+		for i, g := range groups {
+			if g[0].sameServer(rbm) {
+				groups[i] = append(g, rbm)
+				grouped = true
+				break
+			}
+		}
+		if !grouped {
+			groups = append(groups, []*Redis{rbm})
+		}
+	}
+	for _, g := range groups {
+		if err := g[0].SetBitsMulti(ctx, locs, g[1:]...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetBitsContext calls bm.SetBitsContext if bm is ContextBitmap, otherwise bm.SetBits.
+func SetBitsContext(ctx context.Context, bm Bitmap, locs []uint64) error {
+	if cbm, ok := bm.(ContextBitmap); ok {
+		return cbm.SetBitsContext(ctx, locs)
+	}
+	return bm.SetBits(locs)
+}
+
+// checkBits calls CheckBitsContext with ctx if perCall is true, otherwise bm.CheckBits, see ContextBitmap.
+func checkBits(ctx context.Context, bm Bitmap, locs []uint64, perCall bool) (bool, error) {
+	if perCall {
+		return CheckBitsContext(ctx, bm, locs)
+	}
+	return bm.CheckBits(locs)
+}
+
+// setBits calls SetBitsContext with ctx if perCall is true, otherwise bm.SetBits, see ContextBitmap.
+func setBits(ctx context.Context, bm Bitmap, locs []uint64, perCall bool) error {
+	if perCall {
+		return SetBitsContext(ctx, bm, locs)
+	}
+	return bm.SetBits(locs)
+}
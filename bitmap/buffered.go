@@ -0,0 +1,265 @@
+package bitmap
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	// BackPressureBlock blocks SetBits until pending bits are flushed.
+	BackPressureBlock BackPressure = iota
+	// BackPressureFlush flushes pending bits by the caller of SetBits.
+	BackPressureFlush
+	// BackPressureReject rejects SetBits with ErrBufferFull.
+	BackPressureReject
+)
+
+var (
+	ErrBufferFull   = errors.New("buffer is full")
+	ErrBufferClosed = errors.New("buffer is closed")
+)
+
+// BackPressure decides the behavior of Buffered.SetBits when pending bits reach the limit of BufferedMaxPending.
+type BackPressure int
+
+type BufferedOption func(*Buffered)
+
+// Buffered is a write-behind Bitmap which coalesces SetBits across calls into a single SetBits of the underlying
+// bitmap, e.g. a single pipeline of Redis. Pending bits are flushed when:
+//   - the number of them reaches BufferedMaxBatch.
+//   - every BufferedFlushInterval.
+//   - Flush or Close is called.
+//
+// CheckBits consults pending bits before the underlying bitmap, so that data set is visible to subsequent checks.
+// Buffered is closed once the context is done, pending bits are flushed at that time.
+type Buffered struct {
+	bm         Bitmap
+	interval   time.Duration
+	maxBatch   int
+	maxPending int
+	policy     BackPressure
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	pending map[uint64]struct{}
+	// flushing serializes flushes, so that SetBits of the underlying bitmap is called one at a time.
+	flushing sync.Mutex
+	// flushErr is the error of the last flush, it's returned to callers blocked by BackPressureBlock.
+	flushErr error
+	closed   bool
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+func (b *Buffered) CheckBits(locs []uint64) (bool, error) {
+	return b.checkBits(context.Background(), locs, false)
+}
+
+func (b *Buffered) CheckBitsContext(ctx context.Context, locs []uint64) (bool, error) {
+	return b.checkBits(ctx, locs, true)
+}
+
+func (b *Buffered) checkBits(ctx context.Context, locs []uint64, perCall bool) (bool, error) {
+	b.mu.Lock()
+	remaining := make([]uint64, 0, len(locs))
+	for _, loc := range locs {
+		if _, ok := b.pending[loc]; !ok {
+			remaining = append(remaining, loc)
+		}
+	}
+	b.mu.Unlock()
+	if len(remaining) == 0 {
+		return true, nil
+	}
+	return checkBits(ctx, b.bm, remaining, perCall)
+}
+
+// SetBits adds locs into pending bits, it's returned before bits are set to the underlying bitmap.
+// Error of flushing is returned only if it's flushed by the caller, see BackPressure and BufferedMaxBatch.
+func (b *Buffered) SetBits(locs []uint64) error {
+	b.mu.Lock()
+	for {
+		if b.closed {
+			b.mu.Unlock()
+			return ErrBufferClosed
+		}
+		if b.maxPending <= 0 || len(b.pending)+len(locs) <= b.maxPending || len(b.pending) == 0 {
+			break
+		}
+		policy := b.policy
+		// nothing would flush pending bits for the blocked caller.
+		if policy == BackPressureBlock && b.interval <= 0 {
+			policy = BackPressureFlush
+		}
+		switch policy {
+		case BackPressureReject:
+			b.mu.Unlock()
+			return ErrBufferFull
+		case BackPressureFlush:
+			b.mu.Unlock()
+			if err := b.Flush(); err != nil {
+				return err
+			}
+			b.mu.Lock()
+		default:
+			b.cond.Wait()
+			if err := b.flushErr; err != nil && !b.closed {
+				b.mu.Unlock()
+				return err
+			}
+		}
+	}
+	for _, loc := range locs {
+		b.pending[loc] = struct{}{}
+	}
+	full := b.maxBatch > 0 && len(b.pending) >= b.maxBatch
+	b.mu.Unlock()
+
+	if full {
+		return b.Flush()
+	}
+	return nil
+}
+
+// Flush sets all pending bits to the underlying bitmap, bits are kept pending if it fails.
+func (b *Buffered) Flush() error {
+	b.flushing.Lock()
+	defer b.flushing.Unlock()
+
+	b.mu.Lock()
+	if len(b.pending) == 0 {
+		b.mu.Unlock()
+		return nil
+	}
+	batch := make([]uint64, 0, len(b.pending))
+	for loc := range b.pending {
+		batch = append(batch, loc)
+	}
+	b.mu.Unlock()
+
+	err := b.bm.SetBits(batch)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.flushErr = err
+	// callers blocked by BackPressureBlock are woken up either way, so that they don't wait for a broken bitmap.
+	b.cond.Broadcast()
+	if err != nil {
+		return err
+	}
+	// bits added during flushing are still pending.
+	for _, loc := range batch {
+		delete(b.pending, loc)
+	}
+	return nil
+}
+
+// Reset drops pending bits and clears the underlying bitmap, it returns ErrUnsupported if the bitmap is not Resetter.
+func (b *Buffered) Reset() error {
+	rs, ok := b.bm.(Resetter)
+	if !ok {
+		return ErrUnsupported
+	}
+	b.flushing.Lock()
+	defer b.flushing.Unlock()
+
+	b.mu.Lock()
+	b.pending = map[uint64]struct{}{}
+	b.flushErr = nil
+	b.cond.Broadcast()
+	b.mu.Unlock()
+	return rs.Reset()
+}
+
+// Bytes flushes pending bits and delegates to the underlying bitmap, it returns ErrUnsupported if the bitmap is not Exporter.
+func (b *Buffered) Bytes() ([]byte, error) {
+	e, ok := b.bm.(Exporter)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	if err := b.Flush(); err != nil {
+		return nil, err
+	}
+	return e.Bytes()
+}
+
+// Close stops flushing periodically and flushes the pending bits, subsequent SetBits returns ErrBufferClosed.
+func (b *Buffered) Close() error {
+	b.cancel()
+	<-b.done
+	return b.Flush()
+}
+
+// close rejects subsequent SetBits and wakes up callers blocked by BackPressureBlock.
+func (b *Buffered) close() {
+	b.mu.Lock()
+	b.closed = true
+	b.cond.Broadcast()
+	b.mu.Unlock()
+}
+
+func (b *Buffered) handleFlushing(ctx context.Context) {
+	defer close(b.done)
+	if b.interval <= 0 {
+		<-ctx.Done()
+		b.close()
+		_ = b.Flush()
+		return
+	}
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			// failed bits are kept pending and retried next time.
+			_ = b.Flush()
+		case <-ctx.Done():
+			b.close()
+			_ = b.Flush()
+			return
+		}
+	}
+}
+
+// BufferedFlushInterval sets interval to flush pending bits, 0 disables flushing periodically.
+func BufferedFlushInterval(d time.Duration) BufferedOption {
+	return func(b *Buffered) {
+		b.interval = d
+	}
+}
+
+// BufferedMaxBatch flushes pending bits by the caller of SetBits once the number of them reaches n, 0 disables it.
+func BufferedMaxBatch(n int) BufferedOption {
+	return func(b *Buffered) {
+		b.maxBatch = n
+	}
+}
+
+// BufferedMaxPending bounds the number of pending bits by n with policy, 0 means unbounded.
+func BufferedMaxPending(n int, policy BackPressure) BufferedOption {
+	return func(b *Buffered) {
+		b.maxPending = n
+		b.policy = policy
+	}
+}
+
+// NewBuffered returns Buffered which buffers SetBits of bm, pending bits are flushed every second by default.
+// Pending bits are flushed periodically until ctx is done or Close is called.
+func NewBuffered(ctx context.Context, bm Bitmap, opts ...BufferedOption) *Buffered {
+	ctx, cancel := context.WithCancel(ctx)
+	b := &Buffered{
+		bm:       bm,
+		interval: time.Second,
+		pending:  map[uint64]struct{}{},
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	b.cond = sync.NewCond(&b.mu)
+	for _, opt := range opts {
+		opt(b)
+	}
+	go b.handleFlushing(ctx)
+	return b
+}
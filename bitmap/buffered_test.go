@@ -0,0 +1,185 @@
+package bitmap
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingBitmap counts calls of SetBits, and fails SetBits if err is present.
+type countingBitmap struct {
+	*InMemory
+	mu      sync.Mutex
+	setBits int
+	err     error
+}
+
+func (c *countingBitmap) SetBits(locs []uint64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	c.setBits++
+	return c.InMemory.SetBits(locs)
+}
+
+func (c *countingBitmap) calls() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.setBits
+}
+
+func TestBuffered_SetBits(t *testing.T) {
+	bm := &countingBitmap{InMemory: NewInMemory(100)}
+	b := NewBuffered(context.Background(), bm, BufferedFlushInterval(0))
+	defer b.Close()
+
+	err := b.SetBits([]uint64{1, 2, 3})
+	assert.NoError(t, err)
+	err = b.SetBits([]uint64{3, 4})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, bm.calls())
+
+	// read-your-writes: pending bits are visible
+	exist, err := b.CheckBits([]uint64{1, 4})
+	assert.NoError(t, err)
+	assert.True(t, exist)
+	exist, err = bm.CheckBits([]uint64{1, 4})
+	assert.NoError(t, err)
+	assert.False(t, exist)
+
+	// coalesced into a single SetBits
+	err = b.Flush()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, bm.calls())
+	exist, err = bm.CheckBits([]uint64{1, 2, 3, 4})
+	assert.NoError(t, err)
+	assert.True(t, exist)
+}
+
+func TestBuffered_triggers(t *testing.T) {
+	// size
+	bm := &countingBitmap{InMemory: NewInMemory(100)}
+	b := NewBuffered(context.Background(), bm, BufferedFlushInterval(0), BufferedMaxBatch(3))
+	_ = b.SetBits([]uint64{1, 2})
+	assert.Equal(t, 0, bm.calls())
+	_ = b.SetBits([]uint64{3})
+	assert.Equal(t, 1, bm.calls())
+	_ = b.Close()
+
+	// time
+	bm = &countingBitmap{InMemory: NewInMemory(100)}
+	b = NewBuffered(context.Background(), bm, BufferedFlushInterval(10*time.Millisecond))
+	_ = b.SetBits([]uint64{1})
+	assert.Eventually(t, func() bool { return bm.calls() == 1 }, time.Second, 10*time.Millisecond)
+	_ = b.Close()
+
+	// close flushes pending bits and rejects subsequent SetBits
+	bm = &countingBitmap{InMemory: NewInMemory(100)}
+	b = NewBuffered(context.Background(), bm, BufferedFlushInterval(0))
+	_ = b.SetBits([]uint64{1})
+	assert.NoError(t, b.Close())
+	assert.Equal(t, 1, bm.calls())
+	assert.ErrorIs(t, b.SetBits([]uint64{2}), ErrBufferClosed)
+}
+
+func TestBuffered_backPressure(t *testing.T) {
+	// reject
+	bm := &countingBitmap{InMemory: NewInMemory(100)}
+	b := NewBuffered(context.Background(), bm, BufferedFlushInterval(0), BufferedMaxPending(2, BackPressureReject))
+	assert.NoError(t, b.SetBits([]uint64{1, 2}))
+	assert.ErrorIs(t, b.SetBits([]uint64{3}), ErrBufferFull)
+	_ = b.Close()
+
+	// flush by caller
+	bm = &countingBitmap{InMemory: NewInMemory(100)}
+	b = NewBuffered(context.Background(), bm, BufferedFlushInterval(0), BufferedMaxPending(2, BackPressureFlush))
+	assert.NoError(t, b.SetBits([]uint64{1, 2}))
+	assert.NoError(t, b.SetBits([]uint64{3}))
+	assert.Equal(t, 1, bm.calls())
+	_ = b.Close()
+
+	// block until flushed periodically
+	bm = &countingBitmap{InMemory: NewInMemory(100)}
+	b = NewBuffered(context.Background(), bm, BufferedFlushInterval(10*time.Millisecond), BufferedMaxPending(2, BackPressureBlock))
+	assert.NoError(t, b.SetBits([]uint64{1, 2}))
+	assert.NoError(t, b.SetBits([]uint64{3}))
+	assert.GreaterOrEqual(t, bm.calls(), 1)
+	_ = b.Close()
+}
+
+func TestBuffered_Flush_error(t *testing.T) {
+	errInternal := errors.New("internal error")
+	bm := &countingBitmap{InMemory: NewInMemory(100), err: errInternal}
+	b := NewBuffered(context.Background(), bm, BufferedFlushInterval(0))
+	_ = b.SetBits([]uint64{1})
+	assert.ErrorIs(t, b.Flush(), errInternal)
+
+	// bits are kept pending and retried
+	exist, err := b.CheckBits([]uint64{1})
+	assert.NoError(t, err)
+	assert.True(t, exist)
+	bm.mu.Lock()
+	bm.err = nil
+	bm.mu.Unlock()
+	assert.NoError(t, b.Close())
+	exist, err = bm.CheckBits([]uint64{1})
+	assert.NoError(t, err)
+	assert.True(t, exist)
+}
+
+func TestBuffered_Reset(t *testing.T) {
+	bm := &countingBitmap{InMemory: NewInMemory(100)}
+	b := NewBuffered(context.Background(), bm, BufferedFlushInterval(0))
+	defer b.Close()
+
+	_ = b.SetBits([]uint64{1})
+	_ = b.Flush()
+	_ = b.SetBits([]uint64{2})
+	err := b.Reset()
+	assert.NoError(t, err)
+	exist, err := b.CheckBits([]uint64{1})
+	assert.NoError(t, err)
+	assert.False(t, exist)
+	exist, err = b.CheckBits([]uint64{2})
+	assert.NoError(t, err)
+	assert.False(t, exist)
+}
+
+func TestBuffered_backPressure_unblock(t *testing.T) {
+	// ctx is done while the caller is blocked
+	bm := &countingBitmap{InMemory: NewInMemory(100)}
+	ctx, cancel := context.WithCancel(context.Background())
+	b := NewBuffered(ctx, bm, BufferedFlushInterval(time.Hour), BufferedMaxPending(1, BackPressureBlock))
+	assert.NoError(t, b.SetBits([]uint64{1}))
+	done := make(chan error, 1)
+	go func() {
+		done <- b.SetBits([]uint64{2})
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, ErrBufferClosed)
+	case <-time.After(time.Second):
+		t.Fatal("SetBits is blocked after ctx is done")
+	}
+	// pending bits are flushed once ctx is done
+	assert.Eventually(t, func() bool { return bm.calls() == 1 }, time.Second, 10*time.Millisecond)
+	assert.NoError(t, b.Close())
+
+	// flushing fails while the caller is blocked
+	errInternal := errors.New("internal error")
+	bm = &countingBitmap{InMemory: NewInMemory(100), err: errInternal}
+	b = NewBuffered(context.Background(), bm, BufferedFlushInterval(10*time.Millisecond), BufferedMaxPending(1, BackPressureBlock))
+	assert.NoError(t, b.SetBits([]uint64{1}))
+	assert.ErrorIs(t, b.SetBits([]uint64{2}), errInternal)
+	bm.mu.Lock()
+	bm.err = nil
+	bm.mu.Unlock()
+	assert.NoError(t, b.Close())
+}
@@ -1,6 +1,7 @@
 package bitmap
 
 import (
+	"fmt"
 	"github.com/bits-and-blooms/bitset"
 )
 
@@ -18,6 +19,16 @@ func (im *InMemory) CheckBits(locs []uint64) (bool, error) {
 	return true, nil
 }
 
+// CheckBit returns true if the bit on loc has set.
+func (im *InMemory) CheckBit(loc uint64) bool {
+	return im.bs.Test(uint(loc % im.m))
+}
+
+// SetBit sets the bit on loc.
+func (im *InMemory) SetBit(loc uint64) {
+	im.bs.Set(uint(loc % im.m))
+}
+
 func (im *InMemory) SetBits(locs []uint64) error {
 	for _, loc := range locs {
 		im.bs.Set(uint(loc % im.m))
@@ -25,6 +36,59 @@ func (im *InMemory) SetBits(locs []uint64) error {
 	return nil
 }
 
+// Union overwrites bits with the union of srcs, each of them must be InMemory with the same m.
+func (im *InMemory) Union(srcs ...Bitmap) error {
+	return im.combine(srcs, (*bitset.BitSet).InPlaceUnion)
+}
+
+// Intersect overwrites bits with the intersection of srcs, each of them must be InMemory with the same m.
+func (im *InMemory) Intersect(srcs ...Bitmap) error {
+	return im.combine(srcs, (*bitset.BitSet).InPlaceIntersection)
+}
+
+func (im *InMemory) combine(srcs []Bitmap, op func(bs, other *bitset.BitSet)) error {
+	if len(srcs) == 0 {
+		return nil
+	}
+	bss := make([]*bitset.BitSet, 0, len(srcs))
+	for _, src := range srcs {
+		other, ok := src.(*InMemory)
+		if !ok {
+			return &IncompatibleError{Field: "type", Want: fmt.Sprintf("%T", im), Got: fmt.Sprintf("%T", src)}
+		}
+		if other.m != im.m {
+			return &IncompatibleError{Field: "m", Want: im.m, Got: other.m}
+		}
+		bss = append(bss, other.bs)
+	}
+	// srcs may contain im itself, so that the result is built on a copy.
+	bs := bss[0].Clone()
+	for _, other := range bss[1:] {
+		op(bs, other)
+	}
+	im.bs = bs
+	return nil
+}
+
+// Reset clears all bits.
+func (im *InMemory) Reset() error {
+	im.bs.ClearAll()
+	return nil
+}
+
+func (im *InMemory) CountBits() (uint64, error) {
+	return uint64(im.bs.Count()), nil
+}
+
+// Bytes returns bits in the format of Redis.Bytes, whose length is m bits rounded up to bytes.
+func (im *InMemory) Bytes() ([]byte, error) {
+	data := make([]byte, (im.m+7)/8)
+	for i, ok := im.bs.NextSet(0); ok && uint64(i) < im.m; i, ok = im.bs.NextSet(i + 1) {
+		data[i/8] |= 0x80 >> (i % 8)
+	}
+	return data, nil
+}
+
 // NewInMemory returns in-memory bitmap which is backed by github.com/bits-and-blooms/bitset.
 func NewInMemory(m uint64) *InMemory {
 	return &InMemory{
@@ -32,3 +96,21 @@ func NewInMemory(m uint64) *InMemory {
 		m:  m,
 	}
 }
+
+// NewInMemoryFromRedis returns in-memory bitmap whose bits are copied from data, data is the raw value of bitmap in redis
+// (see Redis.Bytes) where bit 0 is the most significant bit of the first byte. Bits beyond m are ignored.
+func NewInMemoryFromRedis(m uint64, data []byte) *InMemory {
+	im := NewInMemory(m)
+	for i, b := range data {
+		for j := 0; j < 8 && b != 0; j++ {
+			loc := uint64(i*8 + j)
+			if loc >= m {
+				return im
+			}
+			if b&(0x80>>uint(j)) != 0 {
+				im.bs.Set(uint(loc))
+			}
+		}
+	}
+	return im
+}
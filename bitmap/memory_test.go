@@ -2,6 +2,7 @@ package bitmap
 
 import (
 	"github.com/bits-and-blooms/bitset"
+	"github.com/stretchr/testify/assert"
 	"testing"
 )
 
@@ -79,3 +80,79 @@ func TestLocal_CheckBits(t *testing.T) {
 		})
 	}
 }
+
+func TestNewInMemoryFromRedis(t *testing.T) {
+	// 0b10000001, 0b01000000: bit 0, 7 and 9 are set
+	im := NewInMemoryFromRedis(9, []byte{0x81, 0x40})
+	for _, loc := range []uint64{0, 7} {
+		exist, _ := im.CheckBits([]uint64{loc})
+		if !exist {
+			t.Errorf("bit %d is not set", loc)
+		}
+	}
+	// bit 9 is beyond m
+	if n, _ := im.CountBits(); n != 2 {
+		t.Errorf("CountBits() got = %v, want %v", n, 2)
+	}
+}
+
+func TestInMemory_Union(t *testing.T) {
+	a, b := NewInMemory(100), NewInMemory(100)
+	_ = a.SetBits([]uint64{1, 2})
+	_ = b.SetBits([]uint64{2, 3})
+
+	// merge in place
+	err := a.Union(a, b)
+	assert.NoError(t, err)
+	exist, _ := a.CheckBits([]uint64{1, 2, 3})
+	assert.True(t, exist)
+
+	// b is not changed
+	exist, _ = b.CheckBits([]uint64{1})
+	assert.False(t, exist)
+
+	err = a.Union(NewInMemory(200))
+	assert.ErrorIs(t, err, ErrIncompatible)
+	var ie *IncompatibleError
+	assert.ErrorAs(t, err, &ie)
+	assert.Equal(t, "m", ie.Field)
+
+	err = a.Union(&Redis{m: 100})
+	assert.ErrorAs(t, err, &ie)
+	assert.Equal(t, "type", ie.Field)
+}
+
+func TestInMemory_Intersect(t *testing.T) {
+	a, b, dst := NewInMemory(100), NewInMemory(100), NewInMemory(100)
+	_ = a.SetBits([]uint64{1, 2})
+	_ = b.SetBits([]uint64{2, 3})
+	_ = dst.SetBits([]uint64{4})
+
+	err := dst.Intersect(a, b)
+	assert.NoError(t, err)
+	n, _ := dst.CountBits()
+	assert.Equal(t, uint64(1), n)
+	exist, _ := dst.CheckBits([]uint64{2})
+	assert.True(t, exist)
+}
+
+func TestInMemory_Reset(t *testing.T) {
+	im := NewInMemory(100)
+	_ = im.SetBits([]uint64{1, 2})
+	err := im.Reset()
+	assert.NoError(t, err)
+	n, _ := im.CountBits()
+	assert.Equal(t, uint64(0), n)
+}
+
+func TestInMemory_Bytes(t *testing.T) {
+	im := NewInMemory(12)
+	assert.NoError(t, im.SetBits([]uint64{1, 8, 11}))
+	data, err := im.Bytes()
+	assert.NoError(t, err)
+	// the same format as redis: bit 0 is the most significant bit of the first byte.
+	assert.Equal(t, []byte{0x40, 0x90}, data)
+
+	got := NewInMemoryFromRedis(12, data)
+	assert.True(t, im.bs.Equal(got.bs))
+}
@@ -2,7 +2,11 @@ package bitmap
 
 import (
 	"context"
+	"fmt"
 	"github.com/go-redis/redis/v8"
+	"github.com/x0rworld/go-bloomfilter/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"time"
 )
 
@@ -13,16 +17,26 @@ type Redis struct {
 	client *redis.Client
 	key    string
 	m      uint64
+	// preallocate keeps all m bits allocated across Reset, see RedisPreallocate.
+	preallocate bool
 }
 
 func (r *Redis) CheckBits(locs []uint64) (bool, error) {
+	return r.CheckBitsContext(r.ctx, locs)
+}
+
+// CheckBitsContext checks bits by a pipeline performed with ctx.
+func (r *Redis) CheckBitsContext(ctx context.Context, locs []uint64) (exist bool, err error) {
+	ctx, span := r.startSpan(ctx, "Redis.CheckBits", len(locs))
+	defer func() { tracing.End(span, err) }()
+
 	pl := r.client.Pipeline()
 
 	var results []*redis.IntCmd
 	for _, loc := range locs {
-		results = append(results, pl.GetBit(r.ctx, r.key, int64(loc%r.m)))
+		results = append(results, pl.GetBit(ctx, r.key, int64(loc%r.m)))
 	}
-	_, err := pl.Exec(r.ctx)
+	_, err = pl.Exec(ctx)
 	if err != nil {
 		return false, err
 	}
@@ -39,12 +53,20 @@ func (r *Redis) CheckBits(locs []uint64) (bool, error) {
 }
 
 func (r *Redis) SetBits(locs []uint64) error {
+	return r.SetBitsContext(r.ctx, locs)
+}
+
+// SetBitsContext sets bits by a pipeline performed with ctx.
+func (r *Redis) SetBitsContext(ctx context.Context, locs []uint64) (err error) {
+	ctx, span := r.startSpan(ctx, "Redis.SetBits", len(locs))
+	defer func() { tracing.End(span, err) }()
+
 	pl := r.client.Pipeline()
 	var results []*redis.IntCmd
 	for _, loc := range locs {
-		results = append(results, pl.SetBit(r.ctx, r.key, int64(loc%r.m), 1))
+		results = append(results, pl.SetBit(ctx, r.key, int64(loc%r.m), 1))
 	}
-	_, err := pl.Exec(r.ctx)
+	_, err = pl.Exec(ctx)
 	if err != nil {
 		return err
 	}
@@ -57,6 +79,155 @@ func (r *Redis) SetBits(locs []uint64) error {
 	return nil
 }
 
+// SetBitsMulti sets bits on locs of r and others by a single pipeline of r, others must be on the same server as r.
+func (r *Redis) SetBitsMulti(ctx context.Context, locs []uint64, others ...*Redis) (err error) {
+	ctx, span := r.startSpan(ctx, "Redis.SetBitsMulti", len(locs)*(1+len(others)))
+	defer func() { tracing.End(span, err) }()
+
+	pl := r.client.Pipeline()
+	for _, bm := range append([]*Redis{r}, others...) {
+		for _, loc := range locs {
+			pl.SetBit(ctx, bm.key, int64(loc%bm.m), 1)
+		}
+	}
+	cmds, err := pl.Exec(ctx)
+	if err != nil {
+		return err
+	}
+	for _, cmd := range cmds {
+		if err := cmd.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sameServer returns true if r and o connect to the same server and database.
+func (r *Redis) sameServer(o *Redis) bool {
+	ro, oo := r.client.Options(), o.client.Options()
+	return ro.Network == oo.Network && ro.Addr == oo.Addr && ro.DB == oo.DB
+}
+
+// server returns the address and database r connects to.
+func (r *Redis) server() string {
+	o := r.client.Options()
+	return fmt.Sprintf("%s/%d", o.Addr, o.DB)
+}
+
+func (r *Redis) startSpan(ctx context.Context, name string, size int) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(ctx, name, trace.WithAttributes(
+		attribute.String(tracing.AttrRedisKey, r.key),
+		attribute.Int(tracing.AttrRedisPipelineSize, size),
+	))
+}
+
+func (r *Redis) CountBits() (uint64, error) {
+	n, err := r.client.BitCount(r.ctx, r.key, nil).Result()
+	if err != nil {
+		return 0, err
+	}
+	return uint64(n), nil
+}
+
+// Bytes returns the raw value of bitmap in redis where bit 0 is the most significant bit of the first byte,
+// it returns nil if the key doesn't exist.
+func (r *Redis) Bytes() ([]byte, error) {
+	data, err := r.client.Get(r.ctx, r.key).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// resetScript deletes and recreates the empty bitmap of KEYS[1] with ARGV[1] bits allocated, at least 1 bit,
+// the remaining TTL is kept.
+var resetScript = redis.NewScript(`
+local ttl = redis.call('PTTL', KEYS[1])
+local bits = tonumber(ARGV[1])
+redis.call('DEL', KEYS[1])
+if bits > 0 then
+	redis.call('SETBIT', KEYS[1], bits - 1, 0)
+else
+	redis.call('SETBIT', KEYS[1], 0, 0)
+end
+if ttl > 0 then
+	redis.call('PEXPIRE', KEYS[1], ttl)
+end
+return 1
+`)
+
+// Reset clears all bits by deleting and recreating the key atomically, the remaining TTL of the key is kept.
+// The recreated bitmap is still pre-allocated if RedisPreallocate is applied.
+func (r *Redis) Reset() error {
+	return resetScript.Run(r.ctx, r.client, []string{r.key}, r.allocatedBits()).Err()
+}
+
+// allocatedBits returns the number of bits kept allocated by scripts recreating the key, it's m if RedisPreallocate
+// is applied, otherwise 0.
+func (r *Redis) allocatedBits() uint64 {
+	if r.preallocate {
+		return r.m
+	}
+	return 0
+}
+
+// bitOpScript performs BITOP into KEYS[1] from the rest of KEYS, the remaining TTL of KEYS[1] is kept.
+// BITOP deletes KEYS[1] if all sources are missing, then it's recreated with ARGV[2] bits allocated, at least 1 bit,
+// the same as the result shorter than ARGV[2] bits is grown.
+var bitOpScript = redis.NewScript(`
+local ttl = redis.call('PTTL', KEYS[1])
+local size = redis.call('BITOP', ARGV[1], KEYS[1], unpack(KEYS, 2))
+local bits = tonumber(ARGV[2])
+if size == 0 or size * 8 < bits then
+	redis.call('SETBIT', KEYS[1], math.max(bits, 1) - 1, 0)
+end
+if ttl > 0 then
+	redis.call('PEXPIRE', KEYS[1], ttl)
+end
+return 1
+`)
+
+// Union overwrites bits with the union of srcs by `BITOP OR`, each of them must be Redis with the same m on the same server.
+func (r *Redis) Union(srcs ...Bitmap) error {
+	return r.bitOp("OR", srcs)
+}
+
+// Intersect overwrites bits with the intersection of srcs by `BITOP AND`, each of them must be Redis with the same m on
+// the same server.
+func (r *Redis) Intersect(srcs ...Bitmap) error {
+	return r.bitOp("AND", srcs)
+}
+
+func (r *Redis) bitOp(op string, srcs []Bitmap) error {
+	if len(srcs) == 0 {
+		return nil
+	}
+	keys := []string{r.key}
+	for _, src := range srcs {
+		other, ok := src.(*Redis)
+		if !ok {
+			return &IncompatibleError{Field: "type", Want: fmt.Sprintf("%T", r), Got: fmt.Sprintf("%T", src)}
+		}
+		if other.m != r.m {
+			return &IncompatibleError{Field: "m", Want: r.m, Got: other.m}
+		}
+		// BITOP is performed on the server of r, where keys of other servers don't refer to their bits.
+		if !r.sameServer(other) {
+			return &IncompatibleError{Field: "server", Want: r.server(), Got: other.server()}
+		}
+		keys = append(keys, other.key)
+	}
+	return bitOpScript.Run(r.ctx, r.client, keys, op, r.allocatedBits()).Err()
+}
+
+// Key returns key of bitmap in redis.
+func (r *Redis) Key() string {
+	return r.key
+}
+
 // RedisSetExpireTTL sets expiry TTL with d.
 func RedisSetExpireTTL(d time.Duration) RedisOption {
 	return func(r *Redis) error {
@@ -69,15 +240,56 @@ func RedisSetExpireTTL(d time.Duration) RedisOption {
 	}
 }
 
-func (r *Redis) setEmptyBitmap() error {
-	res, err := r.client.Keys(r.ctx, r.key).Result()
+// preallocateScript grows the bitmap of KEYS[1] to ARGV[1] bits unless it's large enough, then returns its size in bytes.
+// Setting the last bit to 0 doesn't change any bit since bits beyond the length are 0.
+var preallocateScript = redis.NewScript(`
+local bits = tonumber(ARGV[1])
+if redis.call('STRLEN', KEYS[1]) * 8 < bits then
+	redis.call('SETBIT', KEYS[1], bits - 1, 0)
+end
+return redis.call('STRLEN', KEYS[1])
+`)
+
+// RedisPreallocate allocates all m bits of the bitmap in advance,
+// so that redis doesn't grow the string while higher offsets are set, which causes latency spikes for large m.
+// The bitmap is pre-allocated again whenever it's reset, including by RedisFresh.
+func RedisPreallocate() RedisOption {
+	return func(r *Redis) error {
+		if r.m == 0 {
+			return nil
+		}
+		r.preallocate = true
+		return preallocateScript.Run(r.ctx, r.client, []string{r.key}, r.m).Err()
+	}
+}
+
+// AllocatedBytes returns the size of bitmap allocated by redis in bytes, i.e. STRLEN of the key.
+func (r *Redis) AllocatedBytes() (uint64, error) {
+	n, err := r.client.StrLen(r.ctx, r.key).Result()
 	if err != nil {
-		return err
+		return 0, err
 	}
-	if len(res) == 0 {
-		r.client.SetBit(r.ctx, r.key, 0, 0)
+	return uint64(n), nil
+}
+
+// RedisFresh discards the existing bits of the key, so that the bitmap starts empty instead of reusing them.
+func RedisFresh() RedisOption {
+	return func(r *Redis) error {
+		return r.Reset()
 	}
-	return nil
+}
+
+// setEmptyBitmapScript creates the empty bitmap of KEYS[1] unless it exists, the existing bits are kept.
+var setEmptyBitmapScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	redis.call('SETBIT', KEYS[1], 0, 0)
+	return 1
+end
+return 0
+`)
+
+func (r *Redis) setEmptyBitmap() error {
+	return setEmptyBitmapScript.Run(r.ctx, r.client, []string{r.key}).Err()
 }
 
 // NewRedis returns bitmap that is store into redis and manipulated via github.com/go-redis/redis.
@@ -115,3 +115,344 @@ func TestNewRedis(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Contains(t, keys, key)
 }
+
+func TestRedis_CountBits(t *testing.T) {
+	m := miniredis.RunT(t)
+	defer m.Close()
+
+	client := redis.NewClient(&redis.Options{Addr: m.Addr()})
+	r, err := NewRedis(context.Background(), client, "test-CountBits", 100)
+	assert.NoError(t, err)
+	err = r.SetBits([]uint64{1, 2, 102})
+	assert.NoError(t, err)
+	n, err := r.CountBits()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), n)
+}
+
+func TestRedis_Bytes(t *testing.T) {
+	m := miniredis.RunT(t)
+	defer m.Close()
+
+	client := redis.NewClient(&redis.Options{Addr: m.Addr()})
+	r, err := NewRedis(context.Background(), client, "test-Bytes", 100)
+	assert.NoError(t, err)
+	err = r.SetBits([]uint64{0, 9, 99})
+	assert.NoError(t, err)
+	data, err := r.Bytes()
+	assert.NoError(t, err)
+
+	// bits are the same after being copied to in-memory bitmap
+	im := NewInMemoryFromRedis(100, data)
+	exist, err := im.CheckBits([]uint64{0, 9, 99})
+	assert.NoError(t, err)
+	assert.True(t, exist)
+	n, err := im.CountBits()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), n)
+
+	// key doesn't exist
+	m.Del("test-Bytes")
+	data, err = r.Bytes()
+	assert.NoError(t, err)
+	assert.Nil(t, data)
+}
+
+func TestRedis_Union(t *testing.T) {
+	m := miniredis.RunT(t)
+	defer m.Close()
+
+	ctx := context.Background()
+	client := redis.NewClient(&redis.Options{Addr: m.Addr()})
+	a, err := NewRedis(ctx, client, "test-Union-a", 100)
+	assert.NoError(t, err)
+	b, err := NewRedis(ctx, client, "test-Union-b", 100)
+	assert.NoError(t, err)
+	dst, err := NewRedis(ctx, client, "test-Union-dst", 100, RedisSetExpireTTL(time.Hour))
+	assert.NoError(t, err)
+	_ = a.SetBits([]uint64{1, 2})
+	_ = b.SetBits([]uint64{2, 3})
+
+	err = dst.Union(a, b)
+	assert.NoError(t, err)
+	exist, err := dst.CheckBits([]uint64{1, 2, 3})
+	assert.NoError(t, err)
+	assert.True(t, exist)
+	// TTL of destination is kept
+	assert.Equal(t, time.Hour, m.TTL("test-Union-dst"))
+
+	err = dst.Intersect(a, b)
+	assert.NoError(t, err)
+	n, err := dst.CountBits()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), n)
+
+	// destination is kept if all sources are missing
+	m.Del("test-Union-a")
+	m.Del("test-Union-b")
+	err = dst.Union(a, b)
+	assert.NoError(t, err)
+	assert.True(t, m.Exists("test-Union-dst"))
+	assert.Equal(t, time.Hour, m.TTL("test-Union-dst"))
+	n, err = dst.CountBits()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), n)
+
+	// pre-allocation is kept
+	dst, err = NewRedis(ctx, client, "test-Union-dst", 100, RedisPreallocate())
+	assert.NoError(t, err)
+	err = dst.Intersect(a, b)
+	assert.NoError(t, err)
+	size, err := dst.AllocatedBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(13), size)
+	assert.Equal(t, time.Hour, m.TTL("test-Union-dst"))
+
+	other, err := NewRedis(ctx, client, "test-Union-other", 200)
+	assert.NoError(t, err)
+	err = dst.Union(other)
+	assert.ErrorIs(t, err, ErrIncompatible)
+	err = dst.Union(NewInMemory(100))
+	assert.ErrorIs(t, err, ErrIncompatible)
+}
+
+func TestRedis_Union_server(t *testing.T) {
+	m := miniredis.RunT(t)
+	defer m.Close()
+	o := miniredis.RunT(t)
+	defer o.Close()
+
+	ctx := context.Background()
+	dst, err := NewRedis(ctx, redis.NewClient(&redis.Options{Addr: m.Addr()}), "test-Union_server-dst", 100)
+	assert.NoError(t, err)
+	assert.NoError(t, dst.SetBits([]uint64{1}))
+	src, err := NewRedis(ctx, redis.NewClient(&redis.Options{Addr: o.Addr()}), "test-Union_server-src", 100)
+	assert.NoError(t, err)
+	assert.NoError(t, src.SetBits([]uint64{2}))
+
+	// sources on another server are rejected and the destination is unchanged
+	var ie *IncompatibleError
+	err = dst.Union(dst, src)
+	assert.ErrorAs(t, err, &ie)
+	assert.Equal(t, "server", ie.Field)
+	err = dst.Intersect(dst, src)
+	assert.ErrorIs(t, err, ErrIncompatible)
+	exist, err := dst.CheckBits([]uint64{1})
+	assert.NoError(t, err)
+	assert.True(t, exist)
+	exist, err = dst.CheckBits([]uint64{2})
+	assert.NoError(t, err)
+	assert.False(t, exist)
+}
+
+func TestRedis_Reset(t *testing.T) {
+	m := miniredis.RunT(t)
+	defer m.Close()
+
+	ctx := context.Background()
+	client := redis.NewClient(&redis.Options{Addr: m.Addr()})
+	key := "test-Reset"
+	r, err := NewRedis(ctx, client, key, 100, RedisSetExpireTTL(time.Hour))
+	assert.NoError(t, err)
+	err = r.SetBits([]uint64{1, 2})
+	assert.NoError(t, err)
+
+	err = r.Reset()
+	assert.NoError(t, err)
+	n, err := r.CountBits()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), n)
+	// the key still exists with the remaining TTL
+	assert.True(t, m.Exists(key))
+	assert.Equal(t, time.Hour, m.TTL(key))
+}
+
+func TestRedisFresh(t *testing.T) {
+	m := miniredis.RunT(t)
+	defer m.Close()
+
+	ctx := context.Background()
+	client := redis.NewClient(&redis.Options{Addr: m.Addr()})
+	key := "test-RedisFresh"
+	r, err := NewRedis(ctx, client, key, 100)
+	assert.NoError(t, err)
+	err = r.SetBits([]uint64{1, 2})
+	assert.NoError(t, err)
+
+	// existing bits are reused by default
+	r, err = NewRedis(ctx, client, key, 100)
+	assert.NoError(t, err)
+	n, err := r.CountBits()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), n)
+
+	r, err = NewRedis(ctx, client, key, 100, RedisFresh())
+	assert.NoError(t, err)
+	n, err = r.CountBits()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), n)
+}
+
+// commandRecorder is redis.Hook recording names of the processed commands and the number of pipelines.
+type commandRecorder struct {
+	cmds      []string
+	pipelines int
+}
+
+func (c *commandRecorder) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	c.cmds = append(c.cmds, cmd.Name())
+	return ctx, nil
+}
+
+func (c *commandRecorder) AfterProcess(_ context.Context, _ redis.Cmder) error {
+	return nil
+}
+
+func (c *commandRecorder) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	c.pipelines++
+	for _, cmd := range cmds {
+		c.cmds = append(c.cmds, cmd.Name())
+	}
+	return ctx, nil
+}
+
+func (c *commandRecorder) AfterProcessPipeline(_ context.Context, _ []redis.Cmder) error {
+	return nil
+}
+
+func TestNewRedis_setEmptyBitmap(t *testing.T) {
+	m := miniredis.RunT(t)
+	defer m.Close()
+
+	key := "test-NewRedis_setEmptyBitmap"
+	client := redis.NewClient(&redis.Options{Addr: m.Addr()})
+	rec := &commandRecorder{}
+	client.AddHook(rec)
+	ctx := context.Background()
+
+	r, err := NewRedis(ctx, client, key, 100)
+	assert.NoError(t, err)
+	assert.True(t, m.Exists(key))
+	err = r.SetBits([]uint64{0, 1})
+	assert.NoError(t, err)
+
+	// existing bits are kept
+	r, err = NewRedis(ctx, client, key, 100)
+	assert.NoError(t, err)
+	n, err := r.CountBits()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), n)
+
+	assert.NotEmpty(t, rec.cmds)
+	assert.NotContains(t, rec.cmds, "keys")
+
+	// error is propagated
+	m.Close()
+	_, err = NewRedis(ctx, client, key, 100)
+	assert.Error(t, err)
+}
+
+func TestRedisPreallocate(t *testing.T) {
+	m := miniredis.RunT(t)
+	defer m.Close()
+
+	ctx := context.Background()
+	client := redis.NewClient(&redis.Options{Addr: m.Addr()})
+	key := "test-RedisPreallocate"
+	r, err := NewRedis(ctx, client, key, 100)
+	assert.NoError(t, err)
+	n, err := r.AllocatedBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), n)
+	err = r.SetBits([]uint64{0, 1})
+	assert.NoError(t, err)
+
+	// 100 bits are allocated by 13 bytes, existing bits are kept
+	r, err = NewRedis(ctx, client, key, 100, RedisPreallocate())
+	assert.NoError(t, err)
+	n, err = r.AllocatedBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(13), n)
+	n, err = r.CountBits()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), n)
+
+	// allocation is kept across reset
+	assert.NoError(t, r.Reset())
+	n, err = r.AllocatedBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(13), n)
+	n, err = r.CountBits()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), n)
+
+	// regardless of the order of options
+	for _, opts := range [][]RedisOption{{RedisFresh(), RedisPreallocate()}, {RedisPreallocate(), RedisFresh()}} {
+		assert.NoError(t, r.SetBits([]uint64{0}))
+		r, err = NewRedis(ctx, client, key, 100, opts...)
+		assert.NoError(t, err)
+		n, err = r.AllocatedBytes()
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(13), n)
+		n, err = r.CountBits()
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(0), n)
+	}
+}
+
+func TestSetBitsAll(t *testing.T) {
+	m := miniredis.RunT(t)
+	defer m.Close()
+
+	ctx := context.Background()
+	rec := &commandRecorder{}
+	newRedis := func(key string) *Redis {
+		client := redis.NewClient(&redis.Options{Addr: m.Addr()})
+		r, err := NewRedis(ctx, client, key, 100)
+		assert.NoError(t, err)
+		client.AddHook(rec)
+		return r
+	}
+	a, b := newRedis("test-SetBitsAll-a"), newRedis("test-SetBitsAll-b")
+	im := NewInMemory(100)
+
+	err := SetBitsAll(ctx, []uint64{1, 102}, a, b, im)
+	assert.NoError(t, err)
+	// redis on the same server are set by a single pipeline
+	assert.Equal(t, 1, rec.pipelines)
+	for _, bm := range []Bitmap{a, b, im} {
+		exist, err := bm.CheckBits([]uint64{1, 2})
+		assert.NoError(t, err)
+		assert.True(t, exist)
+	}
+}
+
+func TestRedis_ctx(t *testing.T) {
+	m := miniredis.RunT(t)
+	defer m.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client := redis.NewClient(&redis.Options{Addr: m.Addr()})
+	r, err := NewRedis(ctx, client, "test-Redis_ctx", 100)
+	assert.NoError(t, err)
+	cancel()
+
+	// decorators keep the context of r without the per-call one
+	res, err := NewResilient(r)
+	assert.NoError(t, err)
+	for _, bm := range []Bitmap{r, res} {
+		_, err = bm.CheckBits([]uint64{1})
+		assert.ErrorIs(t, err, context.Canceled)
+		exist, err := CheckBitsContext(context.Background(), bm, []uint64{1})
+		assert.NoError(t, err)
+		assert.False(t, exist)
+	}
+	b := NewBuffered(context.Background(), r, BufferedFlushInterval(0))
+	defer b.Close()
+	_, err = b.CheckBits([]uint64{1})
+	assert.ErrorIs(t, err, context.Canceled)
+	exist, err := b.CheckBitsContext(context.Background(), []uint64{1})
+	assert.NoError(t, err)
+	assert.False(t, exist)
+	assert.ErrorIs(t, res.SetBits([]uint64{1}), context.Canceled)
+}
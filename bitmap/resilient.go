@@ -0,0 +1,298 @@
+package bitmap
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	// FailPolicyError returns the error of the underlying bitmap.
+	FailPolicyError FailPolicy = iota
+	// FailPolicyOpen treats bits as set, i.e. CheckBits returns true, and drops SetBits.
+	FailPolicyOpen
+	// FailPolicyClosed treats bits as unset, i.e. CheckBits returns false, and drops SetBits.
+	FailPolicyClosed
+	// FailPolicyFallback consults the fallback bitmap, which is always set along with the underlying bitmap.
+	FailPolicyFallback
+)
+
+var (
+	// ErrCircuitOpen is returned if calls are rejected by the circuit breaker.
+	ErrCircuitOpen = errors.New("circuit breaker is open")
+	// ErrUnsupported is returned if the operation is not supported by the underlying bitmap.
+	ErrUnsupported = errors.New("unsupported operation")
+)
+
+// FailPolicy decides the result of Resilient when the underlying bitmap fails or the circuit breaker is open.
+type FailPolicy int
+
+type ResilientOption func(*Resilient)
+
+// Resilient decorates bitmap such as Redis with timeout, retries and circuit breaker:
+//   - each attempt is bounded by ResilientTimeout.
+//   - a failed call is retried as many as ResilientRetries.
+//   - the circuit breaker opens once consecutive failed calls reach ResilientBreaker, calls are rejected with
+//     ErrCircuitOpen until cooldown passes, then a single trial call decides to close or re-open it.
+//
+// The failed or rejected call is resolved by FailPolicy.
+//
+// Attempts bounded by ResilientTimeout are performed with the context of the timeout, including those of CheckBits
+// and SetBits, so that the context the decorated bitmap is created with is not used by them.
+type Resilient struct {
+	bm       Bitmap
+	timeout  time.Duration
+	retries  int
+	backoff  time.Duration
+	policy   FailPolicy
+	fallback Bitmap
+	// fbMu guards fallback, which is not necessarily safe for concurrent use, e.g. InMemory.
+	fbMu sync.RWMutex
+
+	threshold int
+	cooldown  time.Duration
+	mu        sync.Mutex
+	failures  int
+	openedAt  time.Time
+	trial     bool
+}
+
+func (r *Resilient) CheckBits(locs []uint64) (bool, error) {
+	return r.checkBits(context.Background(), locs, false)
+}
+
+func (r *Resilient) CheckBitsContext(ctx context.Context, locs []uint64) (bool, error) {
+	return r.checkBits(ctx, locs, true)
+}
+
+func (r *Resilient) checkBits(ctx context.Context, locs []uint64, perCall bool) (bool, error) {
+	perCall = perCall || r.timeout > 0
+	var exist bool
+	err := r.do(ctx, func(ctx context.Context) error {
+		var err error
+		exist, err = checkBits(ctx, r.bm, locs, perCall)
+		return err
+	})
+	if err == nil {
+		return exist, nil
+	}
+	switch r.policy {
+	case FailPolicyOpen:
+		return true, nil
+	case FailPolicyClosed:
+		return false, nil
+	case FailPolicyFallback:
+		r.fbMu.RLock()
+		defer r.fbMu.RUnlock()
+		return checkBits(ctx, r.fallback, locs, perCall)
+	}
+	return false, err
+}
+
+func (r *Resilient) SetBits(locs []uint64) error {
+	return r.setBits(context.Background(), locs, false)
+}
+
+func (r *Resilient) SetBitsContext(ctx context.Context, locs []uint64) error {
+	return r.setBits(ctx, locs, true)
+}
+
+func (r *Resilient) setBits(ctx context.Context, locs []uint64, perCall bool) error {
+	perCall = perCall || r.timeout > 0
+	if r.policy == FailPolicyFallback {
+		r.fbMu.Lock()
+		err := setBits(ctx, r.fallback, locs, perCall)
+		r.fbMu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	err := r.do(ctx, func(ctx context.Context) error {
+		return setBits(ctx, r.bm, locs, perCall)
+	})
+	if err != nil && r.policy == FailPolicyError {
+		return err
+	}
+	return nil
+}
+
+// CountBits delegates to the decorated bitmap without fail policy, it returns ErrUnsupported if the bitmap is not Counter.
+func (r *Resilient) CountBits() (uint64, error) {
+	c, ok := r.bm.(Counter)
+	if !ok {
+		return 0, ErrUnsupported
+	}
+	var n uint64
+	err := r.do(context.Background(), func(context.Context) error {
+		var err error
+		n, err = c.CountBits()
+		return err
+	})
+	return n, err
+}
+
+// Reset clears the decorated bitmap and the fallback one without fail policy,
+// it returns ErrUnsupported if the bitmap is not Resetter.
+func (r *Resilient) Reset() error {
+	rs, ok := r.bm.(Resetter)
+	if !ok {
+		return ErrUnsupported
+	}
+	if fb, ok := r.fallback.(Resetter); ok {
+		r.fbMu.Lock()
+		err := fb.Reset()
+		r.fbMu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	return r.do(context.Background(), func(context.Context) error {
+		return rs.Reset()
+	})
+}
+
+// Bytes delegates to the decorated bitmap without fail policy, it returns ErrUnsupported if the bitmap is not Exporter.
+func (r *Resilient) Bytes() ([]byte, error) {
+	e, ok := r.bm.(Exporter)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	var data []byte
+	err := r.do(context.Background(), func(context.Context) error {
+		var err error
+		data, err = e.Bytes()
+		return err
+	})
+	return data, err
+}
+
+// Unwrap returns the decorated bitmap.
+func (r *Resilient) Unwrap() Bitmap {
+	return r.bm
+}
+
+// do calls fn with retries if it's allowed by the circuit breaker.
+// The call ended by ctx is not counted by the circuit breaker, since the caller gives up rather than bitmap fails.
+func (r *Resilient) do(ctx context.Context, fn func(ctx context.Context) error) error {
+	if !r.allow() {
+		return ErrCircuitOpen
+	}
+	var err error
+	for i := 0; i <= r.retries; i++ {
+		if i > 0 && r.backoff > 0 {
+			select {
+			case <-time.After(r.backoff):
+			case <-ctx.Done():
+				r.abandon()
+				return ctx.Err()
+			}
+		}
+		if err = r.attempt(ctx, fn); err == nil {
+			break
+		}
+		if ctx.Err() != nil {
+			r.abandon()
+			return ctx.Err()
+		}
+	}
+	r.done(err)
+	return err
+}
+
+func (r *Resilient) attempt(ctx context.Context, fn func(ctx context.Context) error) error {
+	if r.timeout <= 0 {
+		return fn(ctx)
+	}
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	return fn(ctx)
+}
+
+func (r *Resilient) allow() bool {
+	if r.threshold <= 0 {
+		return true
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.failures < r.threshold {
+		return true
+	}
+	// half-open: only a single trial is allowed after cooldown.
+	if r.trial || time.Since(r.openedAt) < r.cooldown {
+		return false
+	}
+	r.trial = true
+	return true
+}
+
+// abandon releases the trial of half-open circuit breaker without counting the call.
+func (r *Resilient) abandon() {
+	if r.threshold <= 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.trial = false
+}
+
+func (r *Resilient) done(err error) {
+	if r.threshold <= 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.trial = false
+	if err == nil {
+		r.failures = 0
+		return
+	}
+	r.failures++
+	if r.failures >= r.threshold {
+		r.openedAt = time.Now()
+	}
+}
+
+// ResilientTimeout bounds each attempt by d, 0 means no timeout.
+func ResilientTimeout(d time.Duration) ResilientOption {
+	return func(r *Resilient) {
+		r.timeout = d
+	}
+}
+
+// ResilientRetries retries a failed call n times, waiting backoff between attempts.
+func ResilientRetries(n int, backoff time.Duration) ResilientOption {
+	return func(r *Resilient) {
+		r.retries = n
+		r.backoff = backoff
+	}
+}
+
+// ResilientBreaker opens the circuit breaker after threshold consecutive failed calls for cooldown, 0 disables it.
+func ResilientBreaker(threshold int, cooldown time.Duration) ResilientOption {
+	return func(r *Resilient) {
+		r.threshold = threshold
+		r.cooldown = cooldown
+	}
+}
+
+// ResilientFailPolicy sets policy, fallback is only used by FailPolicyFallback.
+// Resilient serializes access to fallback, so that it needn't be safe for concurrent use.
+func ResilientFailPolicy(policy FailPolicy, fallback Bitmap) ResilientOption {
+	return func(r *Resilient) {
+		r.policy = policy
+		r.fallback = fallback
+	}
+}
+
+// NewResilient returns Resilient which decorates bm, it returns the error of bm with FailPolicyError by default.
+func NewResilient(bm Bitmap, opts ...ResilientOption) (*Resilient, error) {
+	r := &Resilient{bm: bm}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.policy == FailPolicyFallback && r.fallback == nil {
+		return nil, errors.New("nil fallback bitmap")
+	}
+	return r, nil
+}
@@ -0,0 +1,238 @@
+package bitmap
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errFlaky = errors.New("flaky error")
+
+// flakyBitmap fails the following calls as many as fails.
+type flakyBitmap struct {
+	*InMemory
+	fails int
+	calls int
+}
+
+func (f *flakyBitmap) CheckBits(locs []uint64) (bool, error) {
+	f.calls++
+	if f.fails > 0 {
+		f.fails--
+		return false, errFlaky
+	}
+	return f.InMemory.CheckBits(locs)
+}
+
+func (f *flakyBitmap) SetBits(locs []uint64) error {
+	f.calls++
+	if f.fails > 0 {
+		f.fails--
+		return errFlaky
+	}
+	return f.InMemory.SetBits(locs)
+}
+
+func TestResilient_retries(t *testing.T) {
+	bm := &flakyBitmap{InMemory: NewInMemory(100), fails: 2}
+	r, err := NewResilient(bm, ResilientRetries(2, time.Millisecond))
+	assert.NoError(t, err)
+	err = r.SetBits([]uint64{1})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, bm.calls)
+
+	bm.fails, bm.calls = 3, 0
+	_, err = r.CheckBits([]uint64{1})
+	assert.ErrorIs(t, err, errFlaky)
+	assert.Equal(t, 3, bm.calls)
+}
+
+func TestResilient_timeout(t *testing.T) {
+	bm := &blockingBitmap{}
+	r, err := NewResilient(bm, ResilientTimeout(10*time.Millisecond))
+	assert.NoError(t, err)
+	_, err = r.CheckBits([]uint64{1})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+// blockingBitmap blocks until ctx is done.
+type blockingBitmap struct{}
+
+func (b *blockingBitmap) CheckBits(_ []uint64) (bool, error) {
+	return b.CheckBitsContext(context.Background(), nil)
+}
+
+func (b *blockingBitmap) CheckBitsContext(ctx context.Context, _ []uint64) (bool, error) {
+	<-ctx.Done()
+	return false, ctx.Err()
+}
+
+func (b *blockingBitmap) SetBits(_ []uint64) error {
+	return b.SetBitsContext(context.Background(), nil)
+}
+
+func (b *blockingBitmap) SetBitsContext(ctx context.Context, _ []uint64) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestResilient_breaker(t *testing.T) {
+	bm := &flakyBitmap{InMemory: NewInMemory(100), fails: 3}
+	r, err := NewResilient(bm, ResilientBreaker(2, 20*time.Millisecond))
+	assert.NoError(t, err)
+
+	// open after 2 consecutive failures
+	for i := 0; i < 2; i++ {
+		_, err = r.CheckBits([]uint64{1})
+		assert.ErrorIs(t, err, errFlaky)
+	}
+	_, err = r.CheckBits([]uint64{1})
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, 2, bm.calls)
+
+	// failed trial re-opens
+	time.Sleep(30 * time.Millisecond)
+	_, err = r.CheckBits([]uint64{1})
+	assert.ErrorIs(t, err, errFlaky)
+	_, err = r.CheckBits([]uint64{1})
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+
+	// succeeded trial closes
+	time.Sleep(30 * time.Millisecond)
+	_, err = r.CheckBits([]uint64{1})
+	assert.NoError(t, err)
+	_, err = r.CheckBits([]uint64{1})
+	assert.NoError(t, err)
+	assert.Equal(t, 5, bm.calls)
+}
+
+func TestResilient_breaker_ctx(t *testing.T) {
+	bm := &flakyBitmap{InMemory: NewInMemory(100), fails: 1}
+	r, err := NewResilient(bm, ResilientRetries(1, time.Hour), ResilientBreaker(1, time.Hour))
+	assert.NoError(t, err)
+
+	// cancelled during backoff
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = r.CheckBitsContext(ctx, []uint64{1})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	// cancelled during attempt
+	b, err := NewResilient(&blockingBitmap{}, ResilientBreaker(1, time.Hour))
+	assert.NoError(t, err)
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = b.CheckBitsContext(ctx, []uint64{1})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// calls given up by callers don't open the circuit breaker
+	_, err = r.CheckBits([]uint64{1})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, b.failures)
+
+	// nor hold the trial of half-open circuit breaker
+	r.failures, r.openedAt = 1, time.Now().Add(-2*time.Hour)
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	bm.fails = 1
+	_, err = r.CheckBitsContext(ctx, []uint64{1})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.False(t, r.trial)
+}
+
+func TestResilient_FailPolicy(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    FailPolicy
+		fallback  Bitmap
+		wantExist bool
+		wantErr   bool
+	}{
+		{
+			name:    "error",
+			policy:  FailPolicyError,
+			wantErr: true,
+		},
+		{
+			name:      "open",
+			policy:    FailPolicyOpen,
+			wantExist: true,
+		},
+		{
+			name:      "closed",
+			policy:    FailPolicyClosed,
+			wantExist: false,
+		},
+		{
+			name:      "fallback",
+			policy:    FailPolicyFallback,
+			fallback:  NewInMemory(100),
+			wantExist: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bm := &flakyBitmap{InMemory: NewInMemory(100), fails: 2}
+			r, err := NewResilient(bm, ResilientFailPolicy(tt.policy, tt.fallback))
+			assert.NoError(t, err)
+			err = r.SetBits([]uint64{1, 2})
+			if tt.wantErr {
+				assert.ErrorIs(t, err, errFlaky)
+			} else {
+				assert.NoError(t, err)
+			}
+			exist, err := r.CheckBits([]uint64{1, 2})
+			if tt.wantErr {
+				assert.ErrorIs(t, err, errFlaky)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantExist, exist)
+		})
+	}
+
+	_, err := NewResilient(NewInMemory(100), ResilientFailPolicy(FailPolicyFallback, nil))
+	assert.Error(t, err)
+}
+
+// brokenBitmap always fails, it's safe for concurrent use.
+type brokenBitmap struct{}
+
+func (brokenBitmap) CheckBits([]uint64) (bool, error) {
+	return false, errFlaky
+}
+
+func (brokenBitmap) SetBits([]uint64) error {
+	return errFlaky
+}
+
+func TestResilient_FailPolicy_fallbackConcurrent(t *testing.T) {
+	r, err := NewResilient(brokenBitmap{}, ResilientFailPolicy(FailPolicyFallback, NewInMemory(1000)))
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				loc := uint64(i*100 + j)
+				assert.NoError(t, r.SetBits([]uint64{loc}))
+				exist, err := r.CheckBits([]uint64{loc})
+				assert.NoError(t, err)
+				assert.True(t, exist)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	locs := make([]uint64, 800)
+	for i := range locs {
+		locs[i] = uint64(i)
+	}
+	exist, err := r.CheckBits(locs)
+	assert.NoError(t, err)
+	assert.True(t, exist)
+}
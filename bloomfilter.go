@@ -0,0 +1,186 @@
+// Package bloomfilter builds filters by functional options, it's an alternative to config and factory, e.g.
+//
+//	f, err := bloomfilter.New(ctx,
+//		bloomfilter.WithRedis(client, "go-bloomfilter"),
+//		bloomfilter.WithCapacity(1_000_000, 0.01),
+//		bloomfilter.WithRotation(24*time.Hour, config.RotatorModeTruncatedTime),
+//	)
+package bloomfilter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/bits-and-blooms/bloom/v3"
+	"github.com/go-redis/redis/v8"
+	"github.com/x0rworld/go-bloomfilter/bitmap"
+	"github.com/x0rworld/go-bloomfilter/config"
+	"github.com/x0rworld/go-bloomfilter/factory"
+	"github.com/x0rworld/go-bloomfilter/filter"
+	"github.com/x0rworld/go-bloomfilter/filter/rotator"
+	"github.com/x0rworld/go-bloomfilter/metrics"
+	"time"
+)
+
+// Option configures the filter returned by New.
+type Option func(*options) error
+
+type options struct {
+	m, k       uint64
+	client     *redis.Client
+	key        string
+	rotator    *config.RotatorConfig
+	resilience []bitmap.ResilientOption
+	recorder   metrics.Recorder
+
+	// redis creates bitmaps by client, so that keys, TTL and metadata of them are the same as factory.
+	redis *factory.RedisBitmapFactory
+}
+
+// WithSize sets m bits and k hashes of the filter, it's 256 * 1024 * 1024 bits and 3 hashes by default.
+func WithSize(m, k uint64) Option {
+	return func(o *options) error {
+		if m == 0 || k == 0 {
+			return fmt.Errorf("invalid size: m %v, k %v", m, k)
+		}
+		o.m, o.k = m, k
+		return nil
+	}
+}
+
+// WithCapacity sets m and k estimated for n items with false positive rate fp, see bloom.EstimateParameters.
+func WithCapacity(n uint, fp float64) Option {
+	return func(o *options) error {
+		if n == 0 || fp <= 0 || fp >= 1 {
+			return fmt.Errorf("invalid capacity: n %v, fp %v", n, fp)
+		}
+		m, k := bloom.EstimateParameters(n, fp)
+		o.m, o.k = uint64(m), uint64(k)
+		return nil
+	}
+}
+
+// WithRedis stores the filter in redis by client and key instead of in memory.
+// Bitmaps are named and accompanied by metadata the same as factory.RedisBitmapFactory.
+func WithRedis(client *redis.Client, key string) Option {
+	return func(o *options) error {
+		if client == nil {
+			return errors.New("nil redis client")
+		}
+		if key == "" {
+			return errors.New("empty key")
+		}
+		o.client, o.key = client, key
+		return nil
+	}
+}
+
+// WithRotation rotates the filter every freq by mode, see rotator.Rotator.
+func WithRotation(freq time.Duration, mode config.RotatorMode) Option {
+	return func(o *options) error {
+		if err := mode.Validate(); err != nil {
+			return err
+		}
+		o.rotator = &config.RotatorConfig{Enable: true, Mode: mode, Freq: freq}
+		return nil
+	}
+}
+
+// WithResilience decorates redis bitmaps by bitmap.Resilient with opts, it's ignored without WithRedis.
+func WithResilience(opts ...bitmap.ResilientOption) Option {
+	return func(o *options) error {
+		o.resilience = opts
+		return nil
+	}
+}
+
+// WithMetrics records operations of the filter, bitmaps and rotations into rec.
+func WithMetrics(rec metrics.Recorder) Option {
+	return func(o *options) error {
+		if rec == nil {
+			return errors.New("nil recorder")
+		}
+		o.recorder = rec
+		return nil
+	}
+}
+
+// New returns filter.BloomFilter composed by opts, or rotator.Rotator if WithRotation is given.
+// It's in memory unless WithRedis is given. The filter is decorated by metrics.Filter if WithMetrics is given,
+// use metrics.Filter.Unwrap to get the underlying one.
+func New(ctx context.Context, opts ...Option) (filter.Filter, error) {
+	def := config.NewDefaultFactoryConfig().FilterConfig
+	o := &options{m: def.M, k: def.K}
+	for _, opt := range opts {
+		if err := opt(o); err != nil {
+			return nil, err
+		}
+	}
+	if o.rotator != nil {
+		if err := o.rotator.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	if o.client != nil {
+		cfg := config.FactoryConfig{
+			FilterConfig: config.FilterConfig{M: o.m, K: o.k},
+			RedisConfig:  config.RedisConfig{Key: o.key},
+		}
+		if o.rotator != nil {
+			cfg.RotatorConfig = *o.rotator
+		}
+		var err error
+		o.redis, err = factory.NewRedisBitmapFactory(cfg, o.client)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if o.rotator == nil {
+		bf, err := o.newFilter(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return o.instrument(bf), nil
+	}
+	r, err := rotator.NewRotator(ctx, *o.rotator, o.newFilter)
+	if err != nil {
+		return nil, err
+	}
+	if o.recorder != nil {
+		r.OnRotateDone(func(_ context.Context, d time.Duration, err error) {
+			o.recorder.ObserveRotation(d, err)
+		})
+	}
+	return o.instrument(r), nil
+}
+
+// newFilter returns filter.BloomFilter by a new bitmap, it's also used by rotator to create rotated filters.
+func (o *options) newFilter(ctx context.Context) (filter.Filter, error) {
+	var bm bitmap.Bitmap = bitmap.NewInMemory(o.m)
+	if o.redis != nil {
+		var err error
+		bm, err = o.redis.NewBitmap(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if o.recorder != nil {
+		bm = metrics.NewBitmap(bm, o.recorder)
+	}
+	if o.redis != nil && o.resilience != nil {
+		var err error
+		bm, err = bitmap.NewResilient(bm, o.resilience...)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return filter.NewBloomFilter(bm, o.m, o.k), nil
+}
+
+func (o *options) instrument(f filter.Filter) filter.Filter {
+	if o.recorder == nil {
+		return f
+	}
+	return metrics.NewFilter(f, o.recorder)
+}
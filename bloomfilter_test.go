@@ -0,0 +1,119 @@
+package bloomfilter
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/x0rworld/go-bloomfilter/bitmap"
+	"github.com/x0rworld/go-bloomfilter/config"
+	"github.com/x0rworld/go-bloomfilter/factory"
+	"github.com/x0rworld/go-bloomfilter/filter"
+	"github.com/x0rworld/go-bloomfilter/filter/rotator"
+	"github.com/x0rworld/go-bloomfilter/metrics"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+	f, err := New(context.Background())
+	assert.NoError(t, err)
+	assert.IsType(t, &filter.BloomFilter{}, f)
+
+	assert.NoError(t, f.Add("hello"))
+	exist, err := f.Exist("hello")
+	assert.NoError(t, err)
+	assert.True(t, exist)
+}
+
+func TestNew_invalid(t *testing.T) {
+	client := redis.NewClient(&redis.Options{})
+	defer client.Close()
+
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{name: "size", opts: []Option{WithSize(0, 3)}},
+		{name: "capacity", opts: []Option{WithCapacity(100, 1)}},
+		{name: "nil redis client", opts: []Option{WithRedis(nil, "key")}},
+		{name: "empty redis key", opts: []Option{WithRedis(client, "")}},
+		{name: "rotation mode", opts: []Option{WithRotation(time.Hour, "")}},
+		{name: "rotation freq", opts: []Option{WithRotation(0, config.RotatorModeDefault)}},
+		{name: "nil recorder", opts: []Option{WithMetrics(nil)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := New(context.Background(), tt.opts...)
+			assert.Error(t, err)
+			assert.Nil(t, f)
+		})
+	}
+}
+
+func TestNew_redis(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	key := "test-New_redis"
+	f, err := New(context.Background(),
+		WithRedis(client, key),
+		WithCapacity(1000, 0.01),
+		WithResilience(bitmap.ResilientTimeout(time.Second)),
+	)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Add("hello"))
+	exist, err := f.Exist("hello")
+	assert.NoError(t, err)
+	assert.True(t, exist)
+
+	assert.True(t, mr.Exists(key))
+	assert.True(t, mr.Exists(factory.RedisMetaKey(key)))
+}
+
+func TestNew_rotation(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	key := "test-New_rotation"
+	rec := &countRecorder{}
+	f, err := New(context.Background(),
+		WithRedis(client, key),
+		WithSize(1000, 3),
+		WithRotation(time.Hour, config.RotatorModeDefault),
+		WithMetrics(rec),
+	)
+	assert.NoError(t, err)
+	assert.IsType(t, &metrics.Filter{}, f)
+	r, ok := f.(*metrics.Filter).Unwrap().(*rotator.Rotator)
+	assert.True(t, ok)
+
+	assert.NoError(t, f.Add("hello"))
+	assert.NoError(t, r.Rotate(context.Background()))
+	exist, err := f.Exist("hello")
+	assert.NoError(t, err)
+	assert.True(t, exist)
+	assert.Equal(t, 1, rec.rotations)
+
+	var bitmaps int
+	for _, k := range mr.Keys() {
+		if strings.HasPrefix(k, key+"_") && !strings.HasSuffix(k, "_meta") && k != factory.RedisRegistryKey(key) {
+			bitmaps++
+			assert.True(t, mr.TTL(k) > 0)
+		}
+	}
+	// current and next bitmaps, plus the next one created by rotation.
+	assert.Equal(t, 3, bitmaps)
+}
+
+type countRecorder struct {
+	metrics.NopRecorder
+	rotations int
+}
+
+func (r *countRecorder) ObserveRotation(time.Duration, error) {
+	r.rotations++
+}
@@ -0,0 +1,509 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/bits-and-blooms/bloom/v3"
+	"github.com/go-redis/redis/v8"
+	"github.com/x0rworld/go-bloomfilter/bitmap"
+	"github.com/x0rworld/go-bloomfilter/config"
+	"github.com/x0rworld/go-bloomfilter/factory"
+	"github.com/x0rworld/go-bloomfilter/filter"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// redisFlags connect to the redis bitmap in key.
+type redisFlags struct {
+	addr    string
+	key     string
+	timeout time.Duration
+}
+
+func (f *redisFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.addr, "redis", "", "address of redis")
+	fs.StringVar(&f.key, "key", "", "key of the redis bitmap")
+	fs.DurationVar(&f.timeout, "timeout", 5*time.Second, "timeout of redis commands")
+}
+
+func (f *redisFlags) client() (*redis.Client, error) {
+	if f.addr == "" {
+		return nil, errors.New("-redis is required")
+	}
+	if f.key == "" {
+		return nil, errors.New("-key is required")
+	}
+	return redis.NewClient(&redis.Options{
+		Addr:         f.addr,
+		ReadTimeout:  f.timeout,
+		WriteTimeout: f.timeout,
+	}), nil
+}
+
+// source is the filter read from either a snapshot file or redis.
+type source struct {
+	file  string
+	redis redisFlags
+	m     uint64
+	k     uint64
+}
+
+func (s *source) register(fs *flag.FlagSet) {
+	fs.StringVar(&s.file, "file", "", "path of snapshot file")
+	s.redis.register(fs)
+	s.registerParameters(fs)
+}
+
+// registerParameters registers -m and -k, which are required by redis bitmaps without metadata.
+func (s *source) registerParameters(fs *flag.FlagSet) {
+	fs.Uint64Var(&s.m, "m", 0, "m of the redis bitmap without metadata")
+	fs.Uint64Var(&s.k, "k", 0, "k of the redis bitmap without metadata")
+}
+
+func (s *source) load(ctx context.Context) (snapshot, error) {
+	switch {
+	case s.file != "" && s.redis.addr != "":
+		return snapshot{}, errors.New("either -file or -redis is allowed")
+	case s.file != "":
+		return readSnapshotFile(s.file)
+	case s.redis.addr != "":
+		client, err := s.redis.client()
+		if err != nil {
+			return snapshot{}, err
+		}
+		defer client.Close()
+		return s.readRedis(ctx, client, s.redis.key)
+	}
+	return snapshot{}, errors.New("either -file or -redis is required")
+}
+
+// readRedis returns snapshot of the redis bitmap in key, m and k refer to its metadata or the flags if it's absent.
+func (s *source) readRedis(ctx context.Context, client *redis.Client, key string) (snapshot, error) {
+	m, k, err := s.parameters(ctx, client, key)
+	if err != nil {
+		return snapshot{}, err
+	}
+	data, err := client.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return snapshot{}, fmt.Errorf("redis key %s not found", key)
+	}
+	if err != nil {
+		return snapshot{}, err
+	}
+	return newSnapshot(m, k, data), nil
+}
+
+func (s *source) parameters(ctx context.Context, client *redis.Client, key string) (uint64, uint64, error) {
+	meta, err := factory.ReadRedisMeta(ctx, client, key)
+	if errors.Is(err, factory.ErrMetaNotFound) {
+		if s.m == 0 || s.k == 0 {
+			return 0, 0, fmt.Errorf("%w, -m and -k are required", err)
+		}
+		return s.m, s.k, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	if meta.HashStrategy != filter.HashStrategyBloom {
+		return 0, 0, fmt.Errorf("redis bitmap %s uses unknown hash strategy %q", key, meta.HashStrategy)
+	}
+	if (s.m != 0 && s.m != meta.M) || (s.k != 0 && s.k != meta.K) {
+		return 0, 0, fmt.Errorf("redis bitmap %s was written with m %d, k %d, but m %d, k %d is given", key, meta.M, meta.K, s.m, s.k)
+	}
+	return meta.M, meta.K, nil
+}
+
+// eachLine calls fn with each non-empty line of r.
+func eachLine(r io.Reader, fn func(line string) error) error {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if line = trimLine(line); line != "" {
+			if err := fn(line); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// openInput returns the file of path, or stdin if path is "-".
+func openInput(e *env, path string) (io.ReadCloser, error) {
+	if path == "-" {
+		return io.NopCloser(e.stdin), nil
+	}
+	return os.Open(path)
+}
+
+// runBuild builds the filter of data from -in, m and k are estimated by -n and -fp unless they're given.
+// If none of them is given, n is the number of lines.
+func runBuild(_ context.Context, e *env, args []string) error {
+	fs := newFlagSet(e, "build", "")
+	in := fs.String("in", "-", "path of newline-delimited data, - means stdin")
+	out := fs.String("out", "", "path of the snapshot file, - means stdout")
+	n := fs.Uint("n", 0, "expected number of items, it's the number of lines if neither -n nor -m is given")
+	fp := fs.Float64("fp", 0.01, "expected false positive rate")
+	m := fs.Uint64("m", 0, "number of bits, it's given with -k instead of -n and -fp")
+	k := fs.Uint64("k", 0, "number of hash functions")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *out == "" {
+		return errors.New("-out is required")
+	}
+	if (*m == 0) != (*k == 0) {
+		return errors.New("-m and -k are given together")
+	}
+	if *fp <= 0 || *fp >= 1 {
+		return fmt.Errorf("invalid -fp %v", *fp)
+	}
+
+	r, err := openInput(e, *in)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	var items []string
+	if *m == 0 {
+		if *n == 0 {
+			if err := eachLine(r, func(line string) error {
+				items = append(items, line)
+				return nil
+			}); err != nil {
+				return err
+			}
+			if len(items) == 0 {
+				return errors.New("no data to build")
+			}
+			*n = uint(len(items))
+		}
+		mm, kk := bloom.EstimateParameters(*n, *fp)
+		*m, *k = uint64(mm), uint64(kk)
+	}
+
+	bf := filter.NewBloomFilter(bitmap.NewInMemory(*m), *m, *k)
+	var added int
+	add := func(line string) error {
+		added++
+		return bf.Add(line)
+	}
+	if items != nil {
+		for _, item := range items {
+			if err := add(item); err != nil {
+				return err
+			}
+		}
+	} else if err := eachLine(r, add); err != nil {
+		return err
+	}
+	data, err := bf.Snapshot()
+	if err != nil {
+		return err
+	}
+	if err := writeSnapshotFile(*out, newSnapshot(*m, *k, data), e.stdout); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.stderr, "added %d items into filter of m %d, k %d\n", added, *m, *k)
+	return nil
+}
+
+// runQuery prints whether each of data exists, data is given by arguments or stdin.
+func runQuery(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet(e, "query", "[data ...]")
+	var src source
+	src.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	s, err := src.load(ctx)
+	if err != nil {
+		return err
+	}
+	bf := s.filter()
+	w := bufio.NewWriter(e.stdout)
+	query := func(data string) error {
+		exist, err := bf.Exist(data)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\t%t\n", data, exist)
+		return err
+	}
+	if fs.NArg() > 0 {
+		for _, data := range fs.Args() {
+			if err := query(data); err != nil {
+				return err
+			}
+		}
+	} else if err := eachLine(e.stdin, query); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+func runStats(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet(e, "stats", "")
+	var src source
+	src.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	s, err := src.load(ctx)
+	if err != nil {
+		return err
+	}
+	st := s.stats()
+	w := tabwriter.NewWriter(e.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "m\t%d\n", s.m)
+	fmt.Fprintf(w, "k\t%d\n", s.k)
+	fmt.Fprintf(w, "set bits\t%d\n", st.setBits)
+	fmt.Fprintf(w, "fill ratio\t%.4f\n", st.fillRatio)
+	fmt.Fprintf(w, "estimated items\t%.0f\n", st.estimatedItems)
+	fmt.Fprintf(w, "false positive rate\t%.6g\n", st.fpRate)
+	return w.Flush()
+}
+
+// runDump writes the redis bitmap in -key into the snapshot file, see inspect for keys of rotation generations.
+func runDump(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet(e, "dump", "")
+	var src source
+	src.redis.register(fs)
+	src.registerParameters(fs)
+	out := fs.String("out", "", "path of the snapshot file, - means stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *out == "" {
+		return errors.New("-out is required")
+	}
+	s, err := src.load(ctx)
+	if err != nil {
+		return err
+	}
+	return writeSnapshotFile(*out, s, e.stdout)
+}
+
+// runLoad writes the snapshot file into the redis bitmap in -key with metadata, the remaining TTL of the key is kept.
+func runLoad(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet(e, "load", "")
+	file := fs.String("file", "", "path of the snapshot file")
+	var rf redisFlags
+	rf.register(fs)
+	force := fs.Bool("force", false, "overwrite the bitmap written with different m or k")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *file == "" {
+		return errors.New("-file is required")
+	}
+	s, err := readSnapshotFile(*file)
+	if err != nil {
+		return err
+	}
+	client, err := rf.client()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	cfg := config.FactoryConfig{
+		FilterConfig: config.FilterConfig{
+			BitmapConfig: config.BitmapConfig{
+				Type: config.BitmapTypeRedis,
+			},
+			M: s.m,
+			K: s.k,
+		},
+		RedisConfig: config.RedisConfig{
+			Key:     rf.key,
+			Timeout: rf.timeout,
+		},
+	}
+	if *force {
+		cfg.RedisConfig.MetaPolicy = config.MetaPolicyReset
+	}
+	bmf, err := factory.NewRedisBitmapFactory(cfg, client)
+	if err != nil {
+		return err
+	}
+	// the bitmap is created with metadata, or validated against the existing one.
+	if _, err := bmf.NewBitmap(ctx); err != nil {
+		return err
+	}
+	if err := client.Set(ctx, rf.key, s.data, redis.KeepTTL).Err(); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.stderr, "loaded filter of m %d, k %d into %s\n", s.m, s.k, rf.key)
+	return nil
+}
+
+// generation is a redis bitmap of the key, i.e. the key itself or one named by rotator.
+type generation struct {
+	key string
+	// at is the time in the key named by rotator, it's zero for the key itself.
+	at   time.Time
+	role string
+	// ttl is negative if the key doesn't expire.
+	ttl     time.Duration
+	meta    *factory.RedisMeta
+	setBits int64
+}
+
+// runInspect prints the redis bitmap in -key and the generations named by rotator, i.e. `<key>_<unix nano>`.
+// Roles of generations refer to the registry of rotator, or their time for config.RotatorModeTruncatedTime.
+func runInspect(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet(e, "inspect", "")
+	var src source
+	src.redis.register(fs)
+	src.registerParameters(fs)
+	export := fs.String("export", "", "directory to export generations into as snapshot files named by their keys")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	client, err := src.redis.client()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	gens, err := generations(ctx, client, src.redis.key)
+	if err != nil {
+		return err
+	}
+	if len(gens) == 0 {
+		return fmt.Errorf("redis key %s not found", src.redis.key)
+	}
+	w := tabwriter.NewWriter(e.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tROLE\tTIME\tTTL\tM\tK\tFILL RATIO")
+	for _, g := range gens {
+		at, ttl, m, k, fill := "-", "-", "-", "-", "-"
+		if !g.at.IsZero() {
+			at = g.at.UTC().Format(time.RFC3339)
+		}
+		if g.ttl >= 0 {
+			ttl = g.ttl.Round(time.Second).String()
+		}
+		if g.meta != nil {
+			m, k = strconv.FormatUint(g.meta.M, 10), strconv.FormatUint(g.meta.K, 10)
+			fill = fmt.Sprintf("%.4f", float64(g.setBits)/float64(g.meta.M))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", g.key, g.role, at, ttl, m, k, fill)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if *export == "" {
+		return nil
+	}
+	if err := os.MkdirAll(*export, 0o755); err != nil {
+		return err
+	}
+	for _, g := range gens {
+		s, err := src.readRedis(ctx, client, g.key)
+		if err != nil {
+			return err
+		}
+		if err := writeSnapshotFile(filepath.Join(*export, g.key+".bf"), s, e.stdout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// generations returns the bitmap in key and generations of rotator ordered by time.
+func generations(ctx context.Context, client *redis.Client, key string) ([]*generation, error) {
+	var gens []*generation
+	n, err := client.Exists(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+	if n > 0 {
+		gens = append(gens, &generation{key: key, role: "-"})
+	}
+	iter := client.Scan(ctx, 0, globEscape(key)+"_*", 1000).Iterator()
+	for iter.Next(ctx) {
+		ns, err := strconv.ParseInt(strings.TrimPrefix(iter.Val(), key+"_"), 10, 64)
+		if err != nil {
+			// registry and metadata
+			continue
+		}
+		gens = append(gens, &generation{key: iter.Val(), at: time.Unix(0, ns)})
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(gens, func(i, j int) bool {
+		return gens[i].at.Before(gens[j].at)
+	})
+
+	registry, err := client.HGetAll(ctx, factory.RedisRegistryKey(key)).Result()
+	if err != nil {
+		return nil, err
+	}
+	assignRoles(gens, registry, time.Now())
+
+	for _, g := range gens {
+		if g.ttl, err = client.PTTL(ctx, g.key).Result(); err != nil {
+			return nil, err
+		}
+		if g.setBits, err = client.BitCount(ctx, g.key, nil).Result(); err != nil {
+			return nil, err
+		}
+		meta, err := factory.ReadRedisMeta(ctx, client, g.key)
+		if errors.Is(err, factory.ErrMetaNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		g.meta = &meta
+	}
+	return gens, nil
+}
+
+// assignRoles assigns current, next and retired to generations ordered by time, by registry if it's present,
+// otherwise the latest one not after now is current and the ones after now are next.
+func assignRoles(gens []*generation, registry map[string]string, now time.Time) {
+	current := -1
+	for i, g := range gens {
+		switch {
+		case g.at.IsZero():
+			continue
+		case len(registry) > 0:
+			g.role = "retired"
+			if registry["current"] == g.key {
+				g.role = "current"
+			} else if registry["next"] == g.key {
+				g.role = "next"
+			}
+		case g.at.After(now):
+			g.role = "next"
+		default:
+			g.role = "retired"
+			current = i
+		}
+	}
+	if current >= 0 {
+		gens[current].role = "current"
+	}
+}
+
+// globEscape escapes special characters of the pattern of SCAN.
+func globEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`).Replace(s)
+}
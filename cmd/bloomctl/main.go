@@ -0,0 +1,101 @@
+// Command bloomctl builds, queries and inspects filters stored in snapshot files or redis.
+//
+// Usage:
+//
+//	bloomctl build -n 1000000 -fp 0.01 -in keys.txt -out keys.bf
+//	bloomctl query -file keys.bf hello world
+//	bloomctl stats -redis localhost:6379 -key go-bloomfilter
+//	bloomctl dump -redis localhost:6379 -key go-bloomfilter_1662444000000000000 -out gen.bf
+//	bloomctl load -file keys.bf -redis localhost:6379 -key go-bloomfilter
+//	bloomctl inspect -redis localhost:6379 -key go-bloomfilter -export ./generations
+//
+// Data of build and query is newline-delimited, read from stdin if it's not given.
+// Filters in redis are read by their metadata (see factory.RedisMeta), -m and -k are required for bitmaps without it.
+// Snapshot files start with a header of m and k, followed by bits in the format of bitmap.Exporter.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+type command struct {
+	summary string
+	run     func(ctx context.Context, env *env, args []string) error
+}
+
+var commands = map[string]command{
+	"build":   {summary: "build a snapshot file from newline-delimited data", run: runBuild},
+	"query":   {summary: "query whether data exists in the filter", run: runQuery},
+	"stats":   {summary: "print fill ratio, estimated items and false positive rate of the filter", run: runStats},
+	"dump":    {summary: "dump the filter in redis into a snapshot file", run: runDump},
+	"load":    {summary: "load a snapshot file into redis", run: runLoad},
+	"inspect": {summary: "inspect the redis key with its rotation generations", run: runInspect},
+}
+
+// env is the standard streams of the command.
+type env struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func main() {
+	e := &env{stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr}
+	err := run(context.Background(), e, os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "bloomctl:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, e *env, args []string) error {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "-help" || args[0] == "help" {
+		usage(e.stderr)
+		return flag.ErrHelp
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		usage(e.stderr)
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+	return cmd.run(ctx, e, args[1:])
+}
+
+func usage(w io.Writer) {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintln(w, "Usage: bloomctl <command> [flags]\n\nCommands:")
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-8s %s\n", name, commands[name].summary)
+	}
+	fmt.Fprintln(w, "\nRun 'bloomctl <command> -h' for flags of the command.")
+}
+
+// newFlagSet returns flag set of the command whose errors are returned instead of exiting.
+func newFlagSet(e *env, name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(e.stderr, "Usage: bloomctl %s [flags] %s\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// trimLine returns line without the trailing newline, CRLF is accepted.
+func trimLine(line string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/x0rworld/go-bloomfilter/factory"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// exec runs bloomctl with args and stdin, it returns stdout.
+func exec(t *testing.T, stdin string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	e := &env{stdin: strings.NewReader(stdin), stdout: &stdout, stderr: &stderr}
+	err := run(context.Background(), e, args)
+	return stdout.String(), err
+}
+
+func TestBuild(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "keys.txt")
+	assert.NoError(t, os.WriteFile(in, []byte("hello\r\nworld\n\nfoo"), 0o600))
+	out := filepath.Join(dir, "keys.bf")
+
+	_, err := exec(t, "", "build", "-in", in, "-out", out)
+	assert.NoError(t, err)
+	s, err := readSnapshotFile(out)
+	assert.NoError(t, err)
+	// n is the number of lines
+	assert.Equal(t, uint64(29), s.m)
+	assert.Equal(t, uint64(7), s.k)
+
+	stdout, err := exec(t, "", "query", "-file", out, "hello", "world", "foo", "bar")
+	assert.NoError(t, err)
+	assert.Equal(t, "hello\ttrue\nworld\ttrue\nfoo\ttrue\nbar\tfalse\n", stdout)
+
+	// data is read from stdin
+	_, err = exec(t, "x\ny\n", "build", "-n", "1000", "-fp", "0.001", "-out", out)
+	assert.NoError(t, err)
+	stdout, err = exec(t, "x\nz\n", "query", "-file", out)
+	assert.NoError(t, err)
+	assert.Equal(t, "x\ttrue\nz\tfalse\n", stdout)
+
+	stdout, err = exec(t, "", "stats", "-file", out)
+	assert.NoError(t, err)
+	assert.Contains(t, stdout, "m                    14378\n")
+	assert.Contains(t, stdout, "k                    10\n")
+	assert.Contains(t, stdout, "set bits             20\n")
+	assert.Contains(t, stdout, "estimated items      2\n")
+
+	// the snapshot is written to stdout
+	stdout, err = exec(t, "x\n", "build", "-m", "100", "-k", "3", "-out", "-")
+	assert.NoError(t, err)
+	s, err = readSnapshot(strings.NewReader(stdout))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(100), s.m)
+	assert.Equal(t, uint64(3), s.k)
+}
+
+func TestRun_invalid(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "keys.bf")
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "no command", args: nil},
+		{name: "unknown command", args: []string{"remove"}},
+		{name: "unknown flag", args: []string{"build", "-size", "1"}},
+		{name: "no out", args: []string{"build"}},
+		{name: "m without k", args: []string{"build", "-m", "100", "-out", out}},
+		{name: "fp", args: []string{"build", "-fp", "1", "-out", out}},
+		{name: "no data", args: []string{"build", "-out", out}},
+		{name: "no source", args: []string{"query", "x"}},
+		{name: "both sources", args: []string{"stats", "-file", out, "-redis", "localhost:6379"}},
+		{name: "no redis", args: []string{"inspect"}},
+		{name: "no key", args: []string{"dump", "-redis", "localhost:6379", "-out", out}},
+		{name: "missing file", args: []string{"stats", "-file", out}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := exec(t, "", tt.args...)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestReadSnapshot_invalid(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, newSnapshot(100, 3, nil).writeTo(&buf))
+	valid := buf.Bytes()
+	header := func(m, k uint64) []byte {
+		h := append([]byte{}, valid[:21]...)
+		binary.BigEndian.PutUint64(h[5:], m)
+		binary.BigEndian.PutUint64(h[13:], k)
+		return h
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "empty", data: nil},
+		{name: "magic", data: append([]byte("BLOM"), valid[4:]...)},
+		{name: "version", data: append(append([]byte("GOBF"), 2), valid[5:]...)},
+		{name: "short", data: valid[:len(valid)-1]},
+		{name: "long", data: append(append([]byte{}, valid...), 0)},
+		{name: "m overflows", data: header(math.MaxUint64, 3)},
+		{name: "m beyond data", data: append(header(1<<40, 3), valid[21:]...)},
+		{name: "k", data: append(header(100, 0), valid[21:]...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readSnapshot(bytes.NewReader(tt.data))
+			assert.ErrorIs(t, err, errInvalidSnapshot)
+		})
+	}
+}
+
+func TestNewSnapshot(t *testing.T) {
+	// bits beyond m are cleared and data is padded to m bits
+	s := newSnapshot(12, 3, []byte{0xff, 0xff, 0xff})
+	assert.Equal(t, []byte{0xff, 0xf0}, s.data)
+	assert.Equal(t, uint64(12), s.stats().setBits)
+	s = newSnapshot(24, 3, []byte{0x80})
+	assert.Equal(t, []byte{0x80, 0, 0}, s.data)
+}
+
+func TestLoadDump(t *testing.T) {
+	mr := miniredis.RunT(t)
+	dir := t.TempDir()
+	out := filepath.Join(dir, "keys.bf")
+	_, err := exec(t, "hello\nworld\n", "build", "-n", "100", "-out", out)
+	assert.NoError(t, err)
+
+	_, err = exec(t, "", "load", "-file", out, "-redis", mr.Addr(), "-key", "test-bloomctl")
+	assert.NoError(t, err)
+	assert.Equal(t, "959", mr.HGet(factory.RedisMetaKey("test-bloomctl"), "m"))
+	stdout, err := exec(t, "", "query", "-redis", mr.Addr(), "-key", "test-bloomctl", "hello", "x")
+	assert.NoError(t, err)
+	assert.Equal(t, "hello\ttrue\nx\tfalse\n", stdout)
+
+	dump := filepath.Join(dir, "dump.bf")
+	_, err = exec(t, "", "dump", "-redis", mr.Addr(), "-key", "test-bloomctl", "-out", dump)
+	assert.NoError(t, err)
+	want, err := os.ReadFile(out)
+	assert.NoError(t, err)
+	got, err := os.ReadFile(dump)
+	assert.NoError(t, err)
+	assert.Equal(t, want, got)
+
+	// the existing bitmap is written with different m
+	_, err = exec(t, "x\n", "build", "-m", "100", "-k", "3", "-out", out)
+	assert.NoError(t, err)
+	_, err = exec(t, "", "load", "-file", out, "-redis", mr.Addr(), "-key", "test-bloomctl")
+	assert.ErrorIs(t, err, factory.ErrMetaMismatch)
+	_, err = exec(t, "", "load", "-file", out, "-redis", mr.Addr(), "-key", "test-bloomctl", "-force")
+	assert.NoError(t, err)
+	stdout, err = exec(t, "", "query", "-redis", mr.Addr(), "-key", "test-bloomctl", "hello", "x")
+	assert.NoError(t, err)
+	assert.Equal(t, "hello\tfalse\nx\ttrue\n", stdout)
+
+	// bitmap without metadata requires m and k
+	mr.Set("test-bloomctl-raw", "\x80")
+	_, err = exec(t, "", "stats", "-redis", mr.Addr(), "-key", "test-bloomctl-raw")
+	assert.ErrorIs(t, err, factory.ErrMetaNotFound)
+	stdout, err = exec(t, "", "stats", "-redis", mr.Addr(), "-key", "test-bloomctl-raw", "-m", "8", "-k", "1")
+	assert.NoError(t, err)
+	assert.Contains(t, stdout, "fill ratio           0.1250\n")
+	_, err = exec(t, "", "stats", "-redis", mr.Addr(), "-key", "test-bloomctl", "-m", "8", "-k", "1")
+	assert.Error(t, err)
+}
+
+func TestInspect(t *testing.T) {
+	mr := miniredis.RunT(t)
+	dir := t.TempDir()
+	out := filepath.Join(dir, "keys.bf")
+	_, err := exec(t, "hello\n", "build", "-m", "100", "-k", "3", "-out", out)
+	assert.NoError(t, err)
+
+	key := "test-bloomctl"
+	now := time.Now()
+	past, current, next := now.Add(-2*time.Hour).UnixNano(), now.Add(-time.Hour).UnixNano(), now.Add(time.Hour).UnixNano()
+	for _, ns := range []int64{past, current, next} {
+		_, err = exec(t, "", "load", "-file", out, "-redis", mr.Addr(), "-key", fmt.Sprintf("%s_%d", key, ns))
+		assert.NoError(t, err)
+	}
+	mr.SetTTL(fmt.Sprintf("%s_%d", key, next), 3*time.Hour)
+	// keys of other filters are excluded
+	mr.Set(key+"x_1", "")
+
+	stdout, err := exec(t, "", "inspect", "-redis", mr.Addr(), "-key", key)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if assert.Len(t, lines, 4) {
+		assert.Regexp(t, `^KEY\s+ROLE\s+TIME\s+TTL\s+M\s+K\s+FILL RATIO$`, lines[0])
+		assert.Regexp(t, fmt.Sprintf(`^%s_%d\s+retired\s+\S+\s+-\s+100\s+3\s+0.0300$`, key, past), lines[1])
+		assert.Regexp(t, fmt.Sprintf(`^%s_%d\s+current\s`, key, current), lines[2])
+		assert.Regexp(t, fmt.Sprintf(`^%s_%d\s+next\s+\S+\s+3h0m0s\s`, key, next), lines[3])
+	}
+
+	// roles refer to registry if it's present
+	mr.HSet(factory.RedisRegistryKey(key), "current", fmt.Sprintf("%s_%d", key, past), "next", fmt.Sprintf("%s_%d", key, current))
+	export := filepath.Join(dir, "generations")
+	stdout, err = exec(t, "", "inspect", "-redis", mr.Addr(), "-key", key, "-export", export)
+	assert.NoError(t, err)
+	assert.Regexp(t, fmt.Sprintf(`%s_%d\s+current\s`, key, past), stdout)
+	assert.Regexp(t, fmt.Sprintf(`%s_%d\s+next\s`, key, current), stdout)
+	assert.Regexp(t, fmt.Sprintf(`%s_%d\s+retired\s`, key, next), stdout)
+	for _, ns := range []int64{past, current, next} {
+		s, err := readSnapshotFile(filepath.Join(export, fmt.Sprintf("%s_%d.bf", key, ns)))
+		assert.NoError(t, err)
+		exist, err := s.filter().Exist("hello")
+		assert.NoError(t, err)
+		assert.True(t, exist)
+	}
+
+	_, err = exec(t, "", "inspect", "-redis", mr.Addr(), "-key", "unknown")
+	assert.Error(t, err)
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/x0rworld/go-bloomfilter/bitmap"
+	"github.com/x0rworld/go-bloomfilter/filter"
+	"io"
+	"math"
+	"math/bits"
+	"os"
+)
+
+// snapshotMagic starts a snapshot file, followed by the version, m and k, then bits in the format of bitmap.Exporter.
+// Version 1 locates bits by filter.HashStrategyBloom.
+const (
+	snapshotMagic   = "GOBF"
+	snapshotVersion = 1
+)
+
+var errInvalidSnapshot = errors.New("invalid snapshot")
+
+// snapshot is bits of a filter with m and k.
+type snapshot struct {
+	m    uint64
+	k    uint64
+	data []byte
+}
+
+// newSnapshot returns snapshot of data which is padded or truncated to m bits,
+// e.g. the bitmap in redis is only as long as its highest set bit.
+func newSnapshot(m, k uint64, data []byte) snapshot {
+	size := (m + 7) / 8
+	buf := make([]byte, size)
+	copy(buf, data)
+	// bits beyond m are ignored.
+	if rem := m % 8; rem != 0 {
+		buf[size-1] &= ^byte(0xff >> rem)
+	}
+	return snapshot{m: m, k: k, data: buf}
+}
+
+// filter returns the in-memory filter of the snapshot.
+func (s snapshot) filter() *filter.BloomFilter {
+	return filter.NewBloomFilter(bitmap.NewInMemoryFromRedis(s.m, s.data), s.m, s.k)
+}
+
+// stats of snapshot, the estimations assume the filter is never rotated.
+type stats struct {
+	setBits uint64
+	// fillRatio is the ratio of set bits to m.
+	fillRatio float64
+	// estimatedItems is the number of distinct items estimated by fill ratio.
+	estimatedItems float64
+	// fpRate is the false positive rate of the filter as it is, i.e. fillRatio ^ k.
+	fpRate float64
+}
+
+func (s snapshot) stats() stats {
+	var st stats
+	for _, b := range s.data {
+		st.setBits += uint64(bits.OnesCount8(b))
+	}
+	m, k := float64(s.m), float64(s.k)
+	st.fillRatio = float64(st.setBits) / m
+	st.estimatedItems = math.Inf(1)
+	if st.fillRatio < 1 {
+		st.estimatedItems = -m / k * math.Log(1-st.fillRatio)
+	}
+	st.fpRate = math.Pow(st.fillRatio, k)
+	return st
+}
+
+func (s snapshot) writeTo(w io.Writer) error {
+	header := make([]byte, len(snapshotMagic)+1+16)
+	copy(header, snapshotMagic)
+	header[len(snapshotMagic)] = snapshotVersion
+	binary.BigEndian.PutUint64(header[len(snapshotMagic)+1:], s.m)
+	binary.BigEndian.PutUint64(header[len(snapshotMagic)+9:], s.k)
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(s.data)
+	return err
+}
+
+func readSnapshot(r io.Reader) (snapshot, error) {
+	header := make([]byte, len(snapshotMagic)+1+16)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return snapshot{}, fmt.Errorf("%w: %v", errInvalidSnapshot, err)
+	}
+	if string(header[:len(snapshotMagic)]) != snapshotMagic {
+		return snapshot{}, fmt.Errorf("%w: unknown magic %q", errInvalidSnapshot, header[:len(snapshotMagic)])
+	}
+	if v := header[len(snapshotMagic)]; v != snapshotVersion {
+		return snapshot{}, fmt.Errorf("%w: unknown version %d", errInvalidSnapshot, v)
+	}
+	m := binary.BigEndian.Uint64(header[len(snapshotMagic)+1:])
+	k := binary.BigEndian.Uint64(header[len(snapshotMagic)+9:])
+	// (m+7)/8 overflows beyond math.MaxUint64-7.
+	if m == 0 || k == 0 || m > math.MaxUint64-7 {
+		return snapshot{}, fmt.Errorf("%w: m %d, k %d", errInvalidSnapshot, m, k)
+	}
+	size := (m + 7) / 8
+	// bits are read up to size, so that the allocation is bounded by the actual data rather than the header.
+	data, err := io.ReadAll(io.LimitReader(r, int64(size)+1))
+	if err != nil {
+		return snapshot{}, err
+	}
+	if uint64(len(data)) != size {
+		return snapshot{}, fmt.Errorf("%w: %d bytes of bits, want %d", errInvalidSnapshot, len(data), size)
+	}
+	return snapshot{m: m, k: k, data: data}, nil
+}
+
+func readSnapshotFile(path string) (snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return snapshot{}, err
+	}
+	defer f.Close()
+	s, err := readSnapshot(bufio.NewReader(f))
+	if err != nil {
+		return snapshot{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// writeSnapshotFile writes s into path, or stdout if path is "-".
+func writeSnapshotFile(path string, s snapshot, stdout io.Writer) error {
+	if path == "-" {
+		return s.writeTo(stdout)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if err := s.writeTo(w); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,154 @@
+// Command bloomd serves named filters over HTTP, and optionally gRPC and RESP speaking RedisBloom commands, filters are created by the config file as the template,
+// see manager.Manager for naming of the redis key.
+//
+// Usage:
+//
+//	bloomd -config bloomd.yaml -addr :8080 -grpc-addr :9090 -resp-addr :6380
+//
+// Config is overlaid by environment variables prefixed by BLOOMD, e.g. BLOOMD_REDIS_ADDR, see config.LoadFile.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"github.com/x0rworld/go-bloomfilter/config"
+	"github.com/x0rworld/go-bloomfilter/manager"
+	"github.com/x0rworld/go-bloomfilter/resp"
+	"github.com/x0rworld/go-bloomfilter/rpc"
+	"github.com/x0rworld/go-bloomfilter/rpc/pb"
+	"google.golang.org/grpc"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// options are given by flags.
+type options struct {
+	config          string
+	addr            string
+	grpcAddr        string
+	respAddr        string
+	budget          uint64
+	shutdownTimeout time.Duration
+}
+
+// defaultMemoryBudget bounds local bitmaps of filters created by requests of arbitrary names, e.g. 32 filters of the default config.
+const defaultMemoryBudget = 1 << 30
+
+func main() {
+	var opts options
+	flag.StringVar(&opts.config, "config", "", "path of config file in yaml, json or toml, the default config is used if it's empty")
+	flag.StringVar(&opts.addr, "addr", ":8080", "address to listen on for HTTP")
+	flag.StringVar(&opts.grpcAddr, "grpc-addr", "", "address to listen on for gRPC, gRPC is disabled if it's empty")
+	flag.StringVar(&opts.respAddr, "resp-addr", "", "address to listen on for RESP, RESP is disabled if it's empty")
+	flag.Uint64Var(&opts.budget, "memory-budget", defaultMemoryBudget, "bytes of local bitmaps of filters before evicting idle in-memory ones, 0 means unbounded")
+	flag.DurationVar(&opts.shutdownTimeout, "shutdown-timeout", 10*time.Second, "timeout of graceful shutdown")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := run(ctx, opts); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run serves until ctx is done, then shuts down gracefully within shutdownTimeout and closes filters.
+func run(ctx context.Context, opts options) error {
+	cfg, err := loadConfig(opts.config)
+	if err != nil {
+		return err
+	}
+
+	m, err := manager.NewManager(context.Background(), cfg, manager.ManagerMemoryBudget(opts.budget))
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	errCh := make(chan error, 3)
+	var gs *grpc.Server
+	if opts.grpcAddr != "" {
+		lis, err := net.Listen("tcp", opts.grpcAddr)
+		if err != nil {
+			return err
+		}
+		gs = grpc.NewServer()
+		pb.RegisterBloomFilterServer(gs, rpc.NewServer(m.Get, rpc.ServerLookupFunc(m.Lookup)))
+		go func() {
+			log.Printf("bloomd listening on %s for gRPC", opts.grpcAddr)
+			if err := gs.Serve(lis); err != nil {
+				errCh <- err
+			}
+		}()
+	}
+
+	var rs *resp.Server
+	if opts.respAddr != "" {
+		lis, err := net.Listen("tcp", opts.respAddr)
+		if err != nil {
+			return err
+		}
+		rs = resp.NewServer(m)
+		defer rs.Close()
+		go func() {
+			log.Printf("bloomd listening on %s for RESP", opts.respAddr)
+			if err := rs.Serve(lis); !errors.Is(err, resp.ErrServerClosed) {
+				errCh <- err
+			}
+		}()
+	}
+
+	srv := &http.Server{
+		Addr:              opts.addr,
+		Handler:           newServer(m),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		log.Printf("bloomd listening on %s", opts.addr)
+		if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			errCh <- err
+		}
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+	log.Print("bloomd shutting down")
+	ctx, cancel := context.WithTimeout(context.Background(), opts.shutdownTimeout)
+	defer cancel()
+	if gs != nil {
+		go func() {
+			<-ctx.Done()
+			gs.Stop()
+		}()
+		gs.GracefulStop()
+	}
+	if rs != nil {
+		if err := rs.Close(); err != nil {
+			return err
+		}
+	}
+	if err := srv.Shutdown(ctx); err != nil {
+		return err
+	}
+	return m.Close()
+}
+
+// loadConfig returns config of path overlaid by environment variables, or the default config if path is empty.
+func loadConfig(path string) (config.FactoryConfig, error) {
+	if path != "" {
+		return config.LoadFile(path, "BLOOMD")
+	}
+	cfg := config.NewDefaultFactoryConfig()
+	if err := config.DecodeEnv(&cfg, "BLOOMD"); err != nil {
+		return cfg, err
+	}
+	return cfg, cfg.Validate()
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/x0rworld/go-bloomfilter/filter"
+	"github.com/x0rworld/go-bloomfilter/filter/rotator"
+	"github.com/x0rworld/go-bloomfilter/manager"
+	"net/http"
+	"strings"
+)
+
+// maxBodyBytes bounds the request body, e.g. data of batch add.
+const maxBodyBytes = 4 << 20
+
+// server exposes filters of manager by REST endpoints:
+//
+//	POST /v1/filters/{name}/add           {"data": "x"}
+//	POST /v1/filters/{name}/add/batch     {"data": ["x", "y"]}
+//	GET  /v1/filters/{name}/exist?data=x
+//	POST /v1/filters/{name}/exist         {"data": "x"}
+//	POST /v1/filters/{name}/exist/batch   {"data": ["x", "y"]}
+//	GET  /v1/filters/{name}/stats
+//	POST /v1/filters/{name}/rotate
+//	GET  /v1/filters/{name}/snapshot
+//	GET  /v1/stats
+//
+// Filters are created by name at the first add, the other endpoints respond 404 for unknown names so that
+// requests of arbitrary names don't allocate filters, see manager.Manager.Lookup.
+type server struct {
+	manager *manager.Manager
+	mux     *http.ServeMux
+}
+
+type dataRequest struct {
+	Data string `json:"data"`
+}
+
+type batchRequest struct {
+	Data []string `json:"data"`
+}
+
+type existResponse struct {
+	Exist bool `json:"exist"`
+}
+
+type batchExistResponse struct {
+	Exist []bool `json:"exist"`
+}
+
+type filterStatsResponse struct {
+	Name      string   `json:"name"`
+	FillRatio *float64 `json:"fill_ratio,omitempty"`
+}
+
+type statsResponse struct {
+	Filters     int    `json:"filters"`
+	MemoryUsage uint64 `json:"memory_usage"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// httpError is returned by handlers to respond with status.
+type httpError struct {
+	status int
+	err    error
+}
+
+func (e *httpError) Error() string {
+	return e.err.Error()
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *server) handleStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, &httpError{http.StatusMethodNotAllowed, errors.New("method not allowed")})
+		return
+	}
+	writeJSON(w, http.StatusOK, statsResponse{Filters: s.manager.Len(), MemoryUsage: s.manager.MemoryUsage()})
+}
+
+// handleFilter routes /v1/filters/{name}/{action} by action.
+func (s *server) handleFilter(w http.ResponseWriter, r *http.Request) {
+	name, action, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/v1/filters/"), "/")
+	if !ok || name == "" {
+		writeError(w, &httpError{http.StatusNotFound, errors.New("not found")})
+		return
+	}
+	var handle func(w http.ResponseWriter, r *http.Request, name string, f filter.Filter) error
+	method := http.MethodPost
+	filterOf := s.manager.Lookup
+	switch action {
+	case "add":
+		handle, filterOf = s.add, s.manager.Get
+	case "add/batch":
+		handle, filterOf = s.addBatch, s.manager.Get
+	case "exist":
+		handle = s.exist
+		if r.Method == http.MethodGet {
+			method = http.MethodGet
+		}
+	case "exist/batch":
+		handle = s.existBatch
+	case "stats":
+		handle, method = s.stats, http.MethodGet
+	case "rotate":
+		handle = s.rotate
+	case "snapshot":
+		handle, method = s.snapshot, http.MethodGet
+	default:
+		writeError(w, &httpError{http.StatusNotFound, errors.New("not found")})
+		return
+	}
+	if r.Method != method {
+		writeError(w, &httpError{http.StatusMethodNotAllowed, errors.New("method not allowed")})
+		return
+	}
+	f, err := filterOf(name)
+	if err != nil {
+		switch {
+		case errors.Is(err, manager.ErrManagerClosed):
+			err = &httpError{http.StatusServiceUnavailable, err}
+		case errors.Is(err, manager.ErrFilterNotFound):
+			err = &httpError{http.StatusNotFound, err}
+		}
+		writeError(w, err)
+		return
+	}
+	if err := handle(w, r, name, f); err != nil {
+		writeError(w, err)
+	}
+}
+
+func (s *server) add(w http.ResponseWriter, r *http.Request, _ string, f filter.Filter) error {
+	var req dataRequest
+	if err := decode(w, r, &req); err != nil {
+		return err
+	}
+	if err := filter.AddContext(r.Context(), f, req.Data); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (s *server) addBatch(w http.ResponseWriter, r *http.Request, _ string, f filter.Filter) error {
+	var req batchRequest
+	if err := decode(w, r, &req); err != nil {
+		return err
+	}
+	for _, data := range req.Data {
+		if err := filter.AddContext(r.Context(), f, data); err != nil {
+			return err
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (s *server) exist(w http.ResponseWriter, r *http.Request, _ string, f filter.Filter) error {
+	var req dataRequest
+	if r.Method == http.MethodGet {
+		if !r.URL.Query().Has("data") {
+			return &httpError{http.StatusBadRequest, errors.New("missing data")}
+		}
+		req.Data = r.URL.Query().Get("data")
+	} else if err := decode(w, r, &req); err != nil {
+		return err
+	}
+	exist, err := filter.ExistContext(r.Context(), f, req.Data)
+	if err != nil {
+		return err
+	}
+	writeJSON(w, http.StatusOK, existResponse{Exist: exist})
+	return nil
+}
+
+func (s *server) existBatch(w http.ResponseWriter, r *http.Request, _ string, f filter.Filter) error {
+	var req batchRequest
+	if err := decode(w, r, &req); err != nil {
+		return err
+	}
+	resp := batchExistResponse{Exist: make([]bool, len(req.Data))}
+	for i, data := range req.Data {
+		exist, err := filter.ExistContext(r.Context(), f, data)
+		if err != nil {
+			return err
+		}
+		resp.Exist[i] = exist
+	}
+	writeJSON(w, http.StatusOK, resp)
+	return nil
+}
+
+// stats responds fill ratio if the filter supports it.
+func (s *server) stats(w http.ResponseWriter, _ *http.Request, name string, f filter.Filter) error {
+	resp := filterStatsResponse{Name: name}
+	for _, d := range chain(f) {
+		fr, ok := d.(filter.FillRatioReporter)
+		if !ok {
+			continue
+		}
+		ratio, err := fr.FillRatio()
+		if err == nil {
+			resp.FillRatio = &ratio
+		} else if !errors.Is(err, filter.ErrUnsupported) {
+			return err
+		}
+		break
+	}
+	writeJSON(w, http.StatusOK, resp)
+	return nil
+}
+
+func (s *server) rotate(w http.ResponseWriter, r *http.Request, _ string, f filter.Filter) error {
+	for _, d := range chain(f) {
+		if rt, ok := d.(*rotator.Rotator); ok {
+			if err := rt.Rotate(r.Context()); err != nil {
+				return err
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return nil
+		}
+	}
+	return &httpError{http.StatusConflict, errors.New("filter is not rotated")}
+}
+
+// snapshot responds bits of the filter in the format of bitmap.Exporter.
+func (s *server) snapshot(w http.ResponseWriter, _ *http.Request, _ string, f filter.Filter) error {
+	sn, ok := f.(filter.Snapshotter)
+	if !ok {
+		return &httpError{http.StatusConflict, filter.ErrUnsupported}
+	}
+	data, err := sn.Snapshot()
+	if errors.Is(err, filter.ErrUnsupported) {
+		return &httpError{http.StatusConflict, err}
+	}
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	// the response has been started, error of writing is left to the client.
+	_, _ = w.Write(data)
+	return nil
+}
+
+// chain returns f followed by the filters decorated by it, such as the one of metrics.Filter.
+func chain(f filter.Filter) []filter.Filter {
+	filters := []filter.Filter{f}
+	for {
+		u, ok := f.(interface{ Unwrap() filter.Filter })
+		if !ok {
+			return filters
+		}
+		f = u.Unwrap()
+		filters = append(filters, f)
+	}
+}
+
+func decode(w http.ResponseWriter, r *http.Request, v any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return &httpError{http.StatusBadRequest, err}
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var he *httpError
+	if errors.As(err, &he) {
+		status = he.status
+	}
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
+
+func newServer(m *manager.Manager) *server {
+	s := &server{manager: m, mux: http.NewServeMux()}
+	s.mux.HandleFunc("/v1/stats", s.handleStats)
+	s.mux.HandleFunc("/v1/filters/", s.handleFilter)
+	return s
+}
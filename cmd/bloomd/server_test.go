@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/x0rworld/go-bloomfilter/bitmap"
+	"github.com/x0rworld/go-bloomfilter/config"
+	"github.com/x0rworld/go-bloomfilter/filter"
+	"github.com/x0rworld/go-bloomfilter/manager"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestServer(t *testing.T, cfg config.FactoryConfig) *httptest.Server {
+	m, err := manager.NewManager(context.Background(), cfg)
+	assert.NoError(t, err)
+	ts := httptest.NewServer(newServer(m))
+	t.Cleanup(func() {
+		ts.Close()
+		_ = m.Close()
+	})
+	return ts
+}
+
+func do(t *testing.T, ts *httptest.Server, method, path, body string) (int, string) {
+	req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+	assert.NoError(t, err)
+	resp, err := ts.Client().Do(req)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	return resp.StatusCode, string(data)
+}
+
+func TestServer(t *testing.T) {
+	cfg := config.FactoryConfig{
+		FilterConfig: config.FilterConfig{
+			BitmapConfig: config.BitmapConfig{
+				Type: config.BitmapTypeInMemory,
+			},
+			M: 1000,
+			K: 3,
+		},
+	}
+	ts := newTestServer(t, cfg)
+
+	status, _ := do(t, ts, http.MethodPost, "/v1/filters/a/add", `{"data": "hello"}`)
+	assert.Equal(t, http.StatusNoContent, status)
+	status, body := do(t, ts, http.MethodGet, "/v1/filters/a/exist?data=hello", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.JSONEq(t, `{"exist": true}`, body)
+	status, body = do(t, ts, http.MethodPost, "/v1/filters/a/exist", `{"data": "world"}`)
+	assert.Equal(t, http.StatusOK, status)
+	assert.JSONEq(t, `{"exist": false}`, body)
+	// filters are isolated by name
+	status, _ = do(t, ts, http.MethodPost, "/v1/filters/b/add", `{"data": "world"}`)
+	assert.Equal(t, http.StatusNoContent, status)
+	status, body = do(t, ts, http.MethodGet, "/v1/filters/b/exist?data=hello", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.JSONEq(t, `{"exist": false}`, body)
+
+	status, _ = do(t, ts, http.MethodPost, "/v1/filters/a/add/batch", `{"data": ["x", "y"]}`)
+	assert.Equal(t, http.StatusNoContent, status)
+	status, body = do(t, ts, http.MethodPost, "/v1/filters/a/exist/batch", `{"data": ["x", "hello", "z"]}`)
+	assert.Equal(t, http.StatusOK, status)
+	assert.JSONEq(t, `{"exist": [true, true, false]}`, body)
+
+	status, body = do(t, ts, http.MethodGet, "/v1/filters/a/stats", "")
+	assert.Equal(t, http.StatusOK, status)
+	var stats filterStatsResponse
+	assert.NoError(t, json.Unmarshal([]byte(body), &stats))
+	assert.Equal(t, "a", stats.Name)
+	if assert.NotNil(t, stats.FillRatio) {
+		assert.Greater(t, *stats.FillRatio, 0.0)
+	}
+
+	status, body = do(t, ts, http.MethodGet, "/v1/filters/a/snapshot", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Len(t, body, 125)
+	bf := filter.NewBloomFilter(bitmap.NewInMemoryFromRedis(1000, []byte(body)), 1000, 3)
+	exist, err := bf.Exist("hello")
+	assert.NoError(t, err)
+	assert.True(t, exist)
+
+	status, body = do(t, ts, http.MethodGet, "/v1/stats", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.JSONEq(t, `{"filters": 2, "memory_usage": 256}`, body)
+
+	// not rotated
+	status, _ = do(t, ts, http.MethodPost, "/v1/filters/a/rotate", "")
+	assert.Equal(t, http.StatusConflict, status)
+}
+
+func TestServer_invalid(t *testing.T) {
+	ts := newTestServer(t, config.NewDefaultFactoryConfig())
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{name: "unknown action", method: http.MethodPost, path: "/v1/filters/a/remove", want: http.StatusNotFound},
+		{name: "empty name", method: http.MethodPost, path: "/v1/filters//add", want: http.StatusNotFound},
+		{name: "method", method: http.MethodGet, path: "/v1/filters/a/add", want: http.StatusMethodNotAllowed},
+		{name: "invalid body", method: http.MethodPost, path: "/v1/filters/a/add", body: `{"data": 1}`, want: http.StatusBadRequest},
+		{name: "unknown field", method: http.MethodPost, path: "/v1/filters/a/add", body: `{"item": "x"}`, want: http.StatusBadRequest},
+		{name: "missing data", method: http.MethodGet, path: "/v1/filters/a/exist", want: http.StatusBadRequest},
+		{name: "stats method", method: http.MethodPost, path: "/v1/stats", want: http.StatusMethodNotAllowed},
+		{name: "unknown filter", method: http.MethodGet, path: "/v1/filters/unknown/exist?data=x", want: http.StatusNotFound},
+		{name: "unknown filter of batch", method: http.MethodPost, path: "/v1/filters/unknown/exist/batch", body: `{"data": ["x"]}`, want: http.StatusNotFound},
+		{name: "unknown filter of stats", method: http.MethodGet, path: "/v1/filters/unknown/stats", want: http.StatusNotFound},
+		{name: "unknown filter of snapshot", method: http.MethodGet, path: "/v1/filters/unknown/snapshot", want: http.StatusNotFound},
+		{name: "unknown filter of rotate", method: http.MethodPost, path: "/v1/filters/unknown/rotate", want: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := do(t, ts, tt.method, tt.path, tt.body)
+			assert.Equal(t, tt.want, status)
+			assert.Contains(t, body, `"error"`)
+		})
+	}
+}
+
+func TestServer_rotate(t *testing.T) {
+	mr := miniredis.RunT(t)
+	cfg := config.FactoryConfig{
+		FilterConfig: config.FilterConfig{
+			BitmapConfig: config.BitmapConfig{
+				Type: config.BitmapTypeRedis,
+			},
+			M: 1000,
+			K: 3,
+		},
+		RedisConfig: config.RedisConfig{
+			Addr:    mr.Addr(),
+			Timeout: time.Second,
+			Key:     "test-bloomd",
+		},
+		RotatorConfig: config.RotatorConfig{
+			Enable: true,
+			Mode:   config.RotatorModeManual,
+		},
+	}
+	ts := newTestServer(t, cfg)
+
+	status, _ := do(t, ts, http.MethodPost, "/v1/filters/a/add", `{"data": "hello"}`)
+	assert.Equal(t, http.StatusNoContent, status)
+	status, body := do(t, ts, http.MethodGet, "/v1/filters/a/snapshot", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.NotEmpty(t, body)
+
+	// data is added to the next filter as well, so that it's found until the second rotation.
+	for i := 0; i < 2; i++ {
+		status, _ = do(t, ts, http.MethodPost, "/v1/filters/a/rotate", "")
+		assert.Equal(t, http.StatusNoContent, status)
+	}
+	status, body = do(t, ts, http.MethodGet, "/v1/filters/a/exist?data=hello", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.JSONEq(t, `{"exist": false}`, body)
+}
+
+func TestRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- run(ctx, options{addr: "127.0.0.1:0", grpcAddr: "127.0.0.1:0", respAddr: "127.0.0.1:0", shutdownTimeout: time.Second})
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("run is not shut down")
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bloomd.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte("filter:\n  m: 1KiB\n"), 0o600))
+	t.Setenv("BLOOMD_FILTER_K", "5")
+
+	cfg, err := loadConfig(path)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1024), cfg.FilterConfig.M)
+	assert.Equal(t, uint64(5), cfg.FilterConfig.K)
+
+	t.Setenv("BLOOMD_FILTER_K", "0")
+	_, err = loadConfig("")
+	var fe *config.FieldError
+	if assert.ErrorAs(t, err, &fe) {
+		assert.Equal(t, "filter.k", fe.Path)
+	}
+}
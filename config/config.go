@@ -4,6 +4,10 @@ package config
 import (
 	"errors"
 	"fmt"
+	"github.com/x0rworld/go-bloomfilter/internal/registry"
+	"github.com/x0rworld/go-bloomfilter/logging"
+	"github.com/x0rworld/go-bloomfilter/metrics"
+	"github.com/x0rworld/go-bloomfilter/schedule"
 	"time"
 )
 
@@ -12,6 +16,30 @@ const (
 	BitmapTypeRedis          BitmapType  = "redis"
 	RotatorModeDefault       RotatorMode = "default"
 	RotatorModeTruncatedTime RotatorMode = "truncated-time"
+	RotatorModeManual        RotatorMode = "manual"
+	// RotatorLookupCurrent checks current filter only.
+	// Data added by Rotator.Add is guaranteed to be found for at least Freq (at most 2 times of Freq).
+	RotatorLookupCurrent RotatorLookup = "current"
+	// RotatorLookupAny checks current and next filter.
+	// It has the same guarantee with RotatorLookupCurrent,
+	// and additionally finds data only added to next filter such as by another rotator sharing the bitmap.
+	RotatorLookupAny RotatorLookup = "any"
+	// RotatorLookupAll checks current, next and the retired filters retained by RotatorConfig.Retain.
+	// Data added by Rotator.Add is guaranteed to be found for at least (1 + Retain) times of Freq.
+	RotatorLookupAll RotatorLookup = "all"
+	// FailPolicyError returns error of redis.
+	FailPolicyError FailPolicy = "error"
+	// FailPolicyOpen treats data as existing if redis fails.
+	FailPolicyOpen FailPolicy = "open"
+	// FailPolicyClosed treats data as absent if redis fails.
+	FailPolicyClosed FailPolicy = "closed"
+	// FailPolicyFallback consults a local in-memory bitmap if redis fails.
+	FailPolicyFallback FailPolicy = "fallback"
+	// MetaPolicyReject fails to open redis bitmap whose metadata doesn't match config.
+	MetaPolicyReject MetaPolicy = "reject"
+	// MetaPolicyReset migrates redis bitmap whose metadata doesn't match config by clearing its bits,
+	// since bits written by different m, k or hash strategy can't be interpreted.
+	MetaPolicyReset MetaPolicy = "reset"
 )
 
 var (
@@ -22,17 +50,24 @@ var (
 			K:            3,
 		},
 	}
-	ErrInvalidBitmapType  = errors.New("invalid bitmap type")
-	ErrInvalidRotatorMode = errors.New("invalid rotator mode")
+	ErrInvalidBitmapType    = errors.New("invalid bitmap type")
+	ErrInvalidRotatorMode   = errors.New("invalid rotator mode")
+	ErrInvalidRotatorLookup = errors.New("invalid rotator lookup")
+	ErrInvalidFailPolicy    = errors.New("invalid fail policy")
+	ErrInvalidMetaPolicy    = errors.New("invalid meta policy")
 )
 
 type BitmapType string
 
+// Validate returns nil if b is built-in or registered by factory.RegisterBitmap.
 func (b BitmapType) Validate() error {
 	switch b {
 	case BitmapTypeInMemory, BitmapTypeRedis:
 		return nil
 	}
+	if _, ok := registry.Bitmap(string(b)); ok {
+		return nil
+	}
 	return ErrInvalidBitmapType
 }
 
@@ -40,96 +75,300 @@ type RotatorMode string
 
 func (r RotatorMode) Validate() error {
 	switch r {
-	case RotatorModeDefault, RotatorModeTruncatedTime:
+	case RotatorModeDefault, RotatorModeTruncatedTime, RotatorModeManual:
 		return nil
 	}
 	return ErrInvalidRotatorMode
 }
 
+// RotatorLookup decides which filters are checked by Rotator.Exist, empty value is treated as RotatorLookupCurrent.
+type RotatorLookup string
+
+func (r RotatorLookup) Validate() error {
+	switch r {
+	case "", RotatorLookupCurrent, RotatorLookupAny, RotatorLookupAll:
+		return nil
+	}
+	return ErrInvalidRotatorLookup
+}
+
+// FailPolicy decides the result of filter if redis fails, empty value is treated as FailPolicyError.
+type FailPolicy string
+
+func (f FailPolicy) Validate() error {
+	switch f {
+	case "", FailPolicyError, FailPolicyOpen, FailPolicyClosed, FailPolicyFallback:
+		return nil
+	}
+	return ErrInvalidFailPolicy
+}
+
+// MetaPolicy decides the handling of redis bitmap whose metadata doesn't match config,
+// empty value is treated as MetaPolicyReject.
+type MetaPolicy string
+
+func (m MetaPolicy) Validate() error {
+	switch m {
+	case "", MetaPolicyReject, MetaPolicyReset:
+		return nil
+	}
+	return ErrInvalidMetaPolicy
+}
+
 func NewDefaultFactoryConfig() FactoryConfig {
 	return defaultFactoryConfig
 }
 
 type FilterConfig struct {
-	BitmapConfig BitmapConfig
-	// M is the number of bit in bloom filter.
-	M uint64
+	BitmapConfig BitmapConfig `config:"bitmap"`
+	// M is the number of bit in bloom filter, Decode accepts size such as `256MiB` as well.
+	M uint64 `config:"m,size"`
 	// K is the number of hash function.
-	K uint64
+	K uint64 `config:"k"`
 }
 
 func (c FilterConfig) Validate() error {
 	if err := c.BitmapConfig.Validate(); err != nil {
-		return err
+		return fieldError("bitmap", err)
 	}
 	if c.M == 0 {
-		return fmt.Errorf("invalid M: %v", c.M)
+		return fieldError("m", fmt.Errorf("invalid M: %v", c.M))
 	}
 	if c.K == 0 {
-		return fmt.Errorf("invalid K: %v", c.K)
+		return fieldError("k", fmt.Errorf("invalid K: %v", c.K))
 	}
 	return nil
 }
 
 type BitmapConfig struct {
-	Type BitmapType
+	Type BitmapType `config:"type"`
 }
 
 func (b BitmapConfig) Validate() error {
-	return b.Type.Validate()
+	if err := b.Type.Validate(); err != nil {
+		return fieldError("type", err)
+	}
+	return nil
 }
 
 type RedisConfig struct {
-	Addr    string
-	Timeout time.Duration
-	Key     string
+	Addr    string        `config:"addr"`
+	Timeout time.Duration `config:"timeout"`
+	Key     string        `config:"key"`
+	// Resilience decorates redis bitmap by bitmap.Resilient if it's enabled.
+	Resilience ResilienceConfig `config:"resilience"`
+	// MetaPolicy decides the handling of the existing bitmap written by different m, k or hash strategy.
+	MetaPolicy MetaPolicy `config:"meta_policy"`
+	// Preallocate allocates all M bits of each bitmap at creation, see bitmap.RedisPreallocate.
+	Preallocate bool `config:"preallocate"`
 }
 
 func (c RedisConfig) Validate() error {
 	if c.Addr == "" {
-		return errors.New("empty addr")
+		return fieldError("addr", errors.New("empty addr"))
 	}
 	if c.Key == "" {
-		return errors.New("empty key")
+		return fieldError("key", errors.New("empty key"))
 	}
 	if c.Timeout <= 0 {
-		return errors.New("timeout <= 0")
+		return fieldError("timeout", errors.New("timeout <= 0"))
+	}
+	if err := c.MetaPolicy.Validate(); err != nil {
+		return fieldError("meta_policy", err)
+	}
+	if c.Resilience.Enable {
+		if err := c.Resilience.Validate(); err != nil {
+			return fieldError("resilience", err)
+		}
+	}
+	return nil
+}
+
+// ResilienceConfig configures timeout, retries, circuit breaker and fail policy of redis bitmap, see bitmap.Resilient.
+type ResilienceConfig struct {
+	Enable bool `config:"enable"`
+	// CallTimeout bounds each attempt to redis, 0 means RedisConfig.Timeout is the only bound.
+	CallTimeout time.Duration `config:"call_timeout"`
+	// Retries is the number of retries of a failed call, waiting RetryBackoff between attempts.
+	Retries      int           `config:"retries"`
+	RetryBackoff time.Duration `config:"retry_backoff"`
+	// FailureThreshold is the number of consecutive failed calls to open the circuit breaker, 0 disables it.
+	FailureThreshold int `config:"failure_threshold"`
+	// Cooldown is the duration of the open circuit breaker before a trial call.
+	Cooldown   time.Duration `config:"cooldown"`
+	FailPolicy FailPolicy    `config:"fail_policy"`
+}
+
+func (c ResilienceConfig) Validate() error {
+	if err := c.FailPolicy.Validate(); err != nil {
+		return fieldError("fail_policy", err)
+	}
+	if c.CallTimeout < 0 {
+		return fieldError("call_timeout", errors.New("call timeout < 0"))
+	}
+	if c.Retries < 0 {
+		return fieldError("retries", errors.New("retries < 0"))
+	}
+	if c.RetryBackoff < 0 {
+		return fieldError("retry_backoff", errors.New("retry backoff < 0"))
+	}
+	if c.FailureThreshold < 0 {
+		return fieldError("failure_threshold", errors.New("failure threshold < 0"))
+	}
+	if c.FailureThreshold > 0 && c.Cooldown <= 0 {
+		return fieldError("cooldown", errors.New("cooldown <= 0"))
 	}
 	return nil
 }
 
 type RotatorConfig struct {
-	Enable bool
-	Mode   RotatorMode
-	Freq   time.Duration
+	Enable bool          `config:"enable"`
+	Mode   RotatorMode   `config:"mode"`
+	Freq   time.Duration `config:"freq"`
+	Lookup RotatorLookup `config:"lookup"`
+	// Retain is the number of retired filters kept for RotatorLookupAll.
+	Retain int `config:"retain"`
+	// Schedule drives rotation instead of Freq if present, see schedule.Parse for supported spec.
+	// For example, `0 0 * * *` with TimeZone `America/New_York` rotates at midnight of New York.
+	Schedule string `config:"schedule"`
+	// TimeZone is the IANA time zone name of Schedule, UTC by default.
+	TimeZone string `config:"time_zone"`
+}
+
+// NewSchedule returns schedule.Schedule parsed from Schedule in TimeZone, or schedule.Every by Freq if Schedule is absent.
+func (c RotatorConfig) NewSchedule() (schedule.Schedule, error) {
+	if c.Schedule == "" {
+		return schedule.NewEvery(c.Freq), nil
+	}
+	loc, err := time.LoadLocation(c.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone: %w", err)
+	}
+	return schedule.Parse(c.Schedule, loc)
+}
+
+// Generations returns the number of filters kept alive by rotator: current, next and the retained ones.
+func (c RotatorConfig) Generations() int {
+	if c.Lookup == RotatorLookupAll {
+		return 2 + c.Retain
+	}
+	return 2
 }
 
 func (c RotatorConfig) Validate() error {
+	if err := c.Lookup.Validate(); err != nil {
+		return fieldError("lookup", err)
+	}
+	if c.Retain < 0 {
+		return fieldError("retain", errors.New("retain < 0"))
+	}
+	if c.Schedule != "" {
+		if c.Freq < 0 {
+			return fieldError("freq", errors.New("freq < 0"))
+		}
+		if _, err := c.NewSchedule(); err != nil {
+			return fieldError("schedule", err)
+		}
+		return nil
+	}
+	// freq is optional in manual mode, it's only used to set expiry of bitmap if present.
+	if c.Mode == RotatorModeManual {
+		if c.Freq < 0 {
+			return fieldError("freq", errors.New("freq < 0"))
+		}
+		return nil
+	}
 	if c.Freq <= 0 {
-		return errors.New("freq <= 0")
+		return fieldError("freq", errors.New("freq <= 0"))
+	}
+	return nil
+}
+
+// MetricsConfig enables instrumentation of filter and bitmap, see package metrics.
+type MetricsConfig struct {
+	Enable   bool             `config:"enable"`
+	Recorder metrics.Recorder `config:"-"`
+}
+
+func (c MetricsConfig) Validate() error {
+	if c.Recorder == nil {
+		return fieldError("recorder", errors.New("nil recorder"))
+	}
+	return nil
+}
+
+// TieredConfig enables local bitmap.InMemory in front of bitmap.Redis, see package tiered.
+type TieredConfig struct {
+	Enable bool `config:"enable"`
+	// SyncInterval is the interval to re-sync the local tier from redis.
+	SyncInterval time.Duration `config:"sync_interval"`
+}
+
+func (c TieredConfig) Validate() error {
+	if c.SyncInterval <= 0 {
+		return fieldError("sync_interval", errors.New("sync interval <= 0"))
 	}
 	return nil
 }
 
 type FactoryConfig struct {
-	FilterConfig  FilterConfig
-	RedisConfig   RedisConfig
-	RotatorConfig RotatorConfig
+	FilterConfig  FilterConfig  `config:"filter"`
+	RedisConfig   RedisConfig   `config:"redis"`
+	RotatorConfig RotatorConfig `config:"rotator"`
+	MetricsConfig MetricsConfig `config:"metrics"`
+	TieredConfig  TieredConfig  `config:"tiered"`
+	// Logger receives events such as filter creation, rotation and naming of redis key, logging.NopLogger if it's nil.
+	Logger logging.Logger `config:"-"`
 }
 
 func (c FactoryConfig) Validate() error {
 	if err := c.FilterConfig.Validate(); err != nil {
-		return err
+		return fieldError("filter", err)
 	}
 	if c.FilterConfig.BitmapConfig.Type == BitmapTypeRedis {
 		if err := c.RedisConfig.Validate(); err != nil {
-			return err
+			return fieldError("redis", err)
 		}
 	}
 	if c.RotatorConfig.Enable {
 		if err := c.RotatorConfig.Validate(); err != nil {
-			return err
+			return fieldError("rotator", err)
+		}
+	}
+	if c.TieredConfig.Enable {
+		if c.FilterConfig.BitmapConfig.Type != BitmapTypeRedis {
+			return fieldError("tiered.enable", errors.New("tiered filter requires redis bitmap"))
+		}
+		if err := c.TieredConfig.Validate(); err != nil {
+			return fieldError("tiered", err)
+		}
+	}
+	if c.MetricsConfig.Enable {
+		if err := c.MetricsConfig.Validate(); err != nil {
+			return fieldError("metrics", err)
 		}
 	}
 	return nil
 }
+
+// FieldError is returned by Validate and Decode with the dotted path of the invalid field, e.g. `redis.resilience.cooldown`.
+type FieldError struct {
+	Path string
+	Err  error
+}
+
+func (e *FieldError) Error() string {
+	return e.Path + ": " + e.Err.Error()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// fieldError prefixes the path of err by name, err is wrapped by FieldError if it's not.
+func fieldError(name string, err error) error {
+	if fe, ok := err.(*FieldError); ok {
+		return &FieldError{Path: name + "." + fe.Path, Err: fe.Err}
+	}
+	return &FieldError{Path: name, Err: err}
+}
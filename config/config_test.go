@@ -10,6 +10,7 @@ func TestFactoryConfig_Validate(t *testing.T) {
 		FilterConfig  FilterConfig
 		RedisConfig   RedisConfig
 		RotatorConfig RotatorConfig
+		TieredConfig  TieredConfig
 	}
 	tests := []struct {
 		name    string
@@ -127,6 +128,154 @@ func TestFactoryConfig_Validate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "valid: redis, resilience",
+			fields: fields{
+				FilterConfig: FilterConfig{
+					BitmapConfig: BitmapConfig{
+						BitmapTypeRedis,
+					},
+					M: 100,
+					K: 2,
+				},
+				RedisConfig: RedisConfig{
+					Addr:    "localhost",
+					Timeout: 5 * time.Second,
+					Key:     "filter-redis",
+					Resilience: ResilienceConfig{
+						Enable:           true,
+						Retries:          1,
+						FailureThreshold: 5,
+						Cooldown:         time.Second,
+						FailPolicy:       FailPolicyFallback,
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid: redis, resilience fail policy",
+			fields: fields{
+				FilterConfig: FilterConfig{
+					BitmapConfig: BitmapConfig{
+						BitmapTypeRedis,
+					},
+					M: 100,
+					K: 2,
+				},
+				RedisConfig: RedisConfig{
+					Addr:    "localhost",
+					Timeout: 5 * time.Second,
+					Key:     "filter-redis",
+					Resilience: ResilienceConfig{
+						Enable:     true,
+						FailPolicy: "unknown",
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid: redis, resilience zero cooldown",
+			fields: fields{
+				FilterConfig: FilterConfig{
+					BitmapConfig: BitmapConfig{
+						BitmapTypeRedis,
+					},
+					M: 100,
+					K: 2,
+				},
+				RedisConfig: RedisConfig{
+					Addr:    "localhost",
+					Timeout: 5 * time.Second,
+					Key:     "filter-redis",
+					Resilience: ResilienceConfig{
+						Enable:           true,
+						FailureThreshold: 5,
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid: redis, meta policy",
+			fields: fields{
+				FilterConfig: FilterConfig{
+					BitmapConfig: BitmapConfig{
+						BitmapTypeRedis,
+					},
+					M: 100,
+					K: 2,
+				},
+				RedisConfig: RedisConfig{
+					Addr:       "localhost",
+					Timeout:    5 * time.Second,
+					Key:        "filter-redis",
+					MetaPolicy: "unknown",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid: tiered",
+			fields: fields{
+				FilterConfig: FilterConfig{
+					BitmapConfig: BitmapConfig{
+						BitmapTypeRedis,
+					},
+					M: 100,
+					K: 2,
+				},
+				RedisConfig: RedisConfig{
+					Addr:    "localhost:6379",
+					Timeout: 5 * time.Second,
+					Key:     "filter-redis",
+				},
+				TieredConfig: TieredConfig{
+					Enable:       true,
+					SyncInterval: time.Minute,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid: tiered, in-memory bitmap",
+			fields: fields{
+				FilterConfig: FilterConfig{
+					BitmapConfig: BitmapConfig{
+						BitmapTypeInMemory,
+					},
+					M: 100,
+					K: 2,
+				},
+				TieredConfig: TieredConfig{
+					Enable:       true,
+					SyncInterval: time.Minute,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid: tiered, zero sync interval",
+			fields: fields{
+				FilterConfig: FilterConfig{
+					BitmapConfig: BitmapConfig{
+						BitmapTypeRedis,
+					},
+					M: 100,
+					K: 2,
+				},
+				RedisConfig: RedisConfig{
+					Addr:    "localhost:6379",
+					Timeout: 5 * time.Second,
+					Key:     "filter-redis",
+				},
+				TieredConfig: TieredConfig{
+					Enable: true,
+				},
+			},
+			wantErr: true,
+		},
 		{
 			name: "invalid: rotator",
 			fields: fields{
@@ -151,6 +300,7 @@ func TestFactoryConfig_Validate(t *testing.T) {
 				FilterConfig:  tt.fields.FilterConfig,
 				RedisConfig:   tt.fields.RedisConfig,
 				RotatorConfig: tt.fields.RotatorConfig,
+				TieredConfig:  tt.fields.TieredConfig,
 			}
 			if err := fc.Validate(); (err != nil) != tt.wantErr {
 				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
@@ -161,8 +311,13 @@ func TestFactoryConfig_Validate(t *testing.T) {
 
 func TestRotatorConfig_Validate(t *testing.T) {
 	type fields struct {
-		Enable bool
-		Freq   time.Duration
+		Enable   bool
+		Mode     RotatorMode
+		Freq     time.Duration
+		Lookup   RotatorLookup
+		Retain   int
+		Schedule string
+		TimeZone string
 	}
 	tests := []struct {
 		name    string
@@ -185,12 +340,90 @@ func TestRotatorConfig_Validate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "valid: manual mode without freq",
+			fields: fields{
+				Enable: true,
+				Mode:   RotatorModeManual,
+				Freq:   0,
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid: manual mode with negative freq",
+			fields: fields{
+				Enable: true,
+				Mode:   RotatorModeManual,
+				Freq:   -1,
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid: lookup all with retain",
+			fields: fields{
+				Enable: true,
+				Freq:   1,
+				Lookup: RotatorLookupAll,
+				Retain: 3,
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid: lookup",
+			fields: fields{
+				Enable: true,
+				Freq:   1,
+				Lookup: "unknown",
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid: schedule without freq",
+			fields: fields{
+				Enable:   true,
+				Schedule: "0 0 * * MON",
+				TimeZone: "UTC",
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid: schedule",
+			fields: fields{
+				Enable:   true,
+				Schedule: "0 0 * *",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid: time zone",
+			fields: fields{
+				Enable:   true,
+				Schedule: "@daily",
+				TimeZone: "Nowhere/Unknown",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid: negative retain",
+			fields: fields{
+				Enable: true,
+				Freq:   1,
+				Lookup: RotatorLookupAll,
+				Retain: -1,
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			c := RotatorConfig{
-				Enable: tt.fields.Enable,
-				Freq:   tt.fields.Freq,
+				Enable:   tt.fields.Enable,
+				Mode:     tt.fields.Mode,
+				Freq:     tt.fields.Freq,
+				Lookup:   tt.fields.Lookup,
+				Retain:   tt.fields.Retain,
+				Schedule: tt.fields.Schedule,
+				TimeZone: tt.fields.TimeZone,
 			}
 			if err := c.Validate(); (err != nil) != tt.wantErr {
 				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
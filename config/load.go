@@ -0,0 +1,316 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FormatYAML Format = "yaml"
+	FormatJSON Format = "json"
+	FormatTOML Format = "toml"
+)
+
+var (
+	ErrInvalidFormat = errors.New("invalid format")
+	ErrUnknownField  = errors.New("unknown field")
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// sizeUnits are the units accepted by the field tagged by `size`.
+var sizeUnits = map[string]uint64{
+	"B":   1,
+	"KB":  1e3,
+	"MB":  1e6,
+	"GB":  1e9,
+	"TB":  1e12,
+	"KiB": 1 << 10,
+	"MiB": 1 << 20,
+	"GiB": 1 << 30,
+	"TiB": 1 << 40,
+}
+
+// Format is the format of config file decoded by Decode.
+type Format string
+
+// FormatOf returns Format by the extension of path, e.g. `.yml` and `.yaml` are FormatYAML.
+func FormatOf(path string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return FormatYAML, nil
+	case ".json":
+		return FormatJSON, nil
+	case ".toml":
+		return FormatTOML, nil
+	}
+	return "", fmt.Errorf("%w: %v", ErrInvalidFormat, path)
+}
+
+// Decode overlays data in format onto cfg, fields absent in data are kept. Keys are the `config` tags of fields, e.g.
+//
+//	filter:
+//	  bitmap:
+//	    type: redis
+//	  m: 256MiB
+//	  k: 7
+//	rotator:
+//	  enable: true
+//	  freq: 3h
+//
+// Durations are strings parsed by time.ParseDuration. FilterConfig.M accepts size with unit as well,
+// e.g. `256MiB` is 256 * 1024 * 1024 bits and `1GB` is 1e9 bits.
+// Unknown or invalid fields are returned as FieldError, cfg isn't validated.
+func Decode(cfg *FactoryConfig, data []byte, format Format) error {
+	var m map[string]any
+	switch format {
+	case FormatYAML:
+		if err := yaml.Unmarshal(data, &m); err != nil {
+			return err
+		}
+	case FormatJSON:
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		if err := dec.Decode(&m); err != nil {
+			return err
+		}
+	case FormatTOML:
+		if err := toml.Unmarshal(data, &m); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%w: %v", ErrInvalidFormat, format)
+	}
+	return decodeMap(reflect.ValueOf(cfg).Elem(), m, "")
+}
+
+// DecodeEnv overlays environment variables named by prefix and the upper-cased path of the field onto cfg,
+// e.g. `BLOOMFILTER_FILTER_M` and `BLOOMFILTER_ROTATOR_FREQ` with prefix `BLOOMFILTER`.
+// Values are parsed the same as Decode, unset variables are ignored.
+func DecodeEnv(cfg *FactoryConfig, prefix string) error {
+	return decodeEnv(reflect.ValueOf(cfg).Elem(), strings.ToUpper(prefix), "")
+}
+
+// LoadFile returns FactoryConfig which is NewDefaultFactoryConfig overlaid by the file at path and
+// environment variables with envPrefix in order, see Decode and DecodeEnv.
+// Format is decided by the extension of path, environment variables are ignored if envPrefix is empty.
+func LoadFile(path, envPrefix string) (FactoryConfig, error) {
+	cfg := NewDefaultFactoryConfig()
+	format, err := FormatOf(path)
+	if err != nil {
+		return cfg, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+	if err := Decode(&cfg, data, format); err != nil {
+		return cfg, fmt.Errorf("%v: %w", path, err)
+	}
+	if envPrefix != "" {
+		if err := DecodeEnv(&cfg, envPrefix); err != nil {
+			return cfg, err
+		}
+	}
+	return cfg, cfg.Validate()
+}
+
+func decodeMap(v reflect.Value, m map[string]any, path string) error {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	// the first invalid field is reported deterministically.
+	sort.Strings(keys)
+	for _, key := range keys {
+		p := joinPath(path, key)
+		f, size, ok := fieldByTag(v, key)
+		if !ok {
+			return &FieldError{Path: p, Err: ErrUnknownField}
+		}
+		raw := m[key]
+		if f.Kind() == reflect.Struct {
+			sub, ok := raw.(map[string]any)
+			if !ok {
+				return &FieldError{Path: p, Err: fmt.Errorf("want table, got %T", raw)}
+			}
+			if err := decodeMap(f, sub, p); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := setField(f, raw, size); err != nil {
+			return &FieldError{Path: p, Err: err}
+		}
+	}
+	return nil
+}
+
+func decodeEnv(v reflect.Value, prefix, path string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, size := parseTag(t.Field(i).Tag.Get("config"))
+		if name == "" || name == "-" {
+			continue
+		}
+		p := joinPath(path, name)
+		env := prefix + "_" + strings.ToUpper(name)
+		f := v.Field(i)
+		if f.Kind() == reflect.Struct {
+			if err := decodeEnv(f, env, p); err != nil {
+				return err
+			}
+			continue
+		}
+		s, ok := os.LookupEnv(env)
+		if !ok {
+			continue
+		}
+		if err := setField(f, s, size); err != nil {
+			return &FieldError{Path: p, Err: fmt.Errorf("%v: %w", env, err)}
+		}
+	}
+	return nil
+}
+
+// fieldByTag returns the field of struct v tagged by name, and whether it accepts byte size.
+func fieldByTag(v reflect.Value, name string) (reflect.Value, bool, bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		tag, size := parseTag(t.Field(i).Tag.Get("config"))
+		if tag == name && tag != "-" {
+			return v.Field(i), size, true
+		}
+	}
+	return reflect.Value{}, false, false
+}
+
+// parseTag returns the name of tag such as `m,size`, and whether it has the option `size`.
+func parseTag(tag string) (string, bool) {
+	name, opt, _ := strings.Cut(tag, ",")
+	return name, opt == "size"
+}
+
+func setField(f reflect.Value, raw any, size bool) error {
+	if f.Type() == durationType {
+		s, ok := raw.(string)
+		if !ok {
+			return fmt.Errorf("want duration string such as \"3h\", got %v", raw)
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		f.SetInt(int64(d))
+		return nil
+	}
+	switch f.Kind() {
+	case reflect.String:
+		s, ok := raw.(string)
+		if !ok {
+			return fmt.Errorf("want string, got %v", raw)
+		}
+		f.SetString(s)
+	case reflect.Bool:
+		switch b := raw.(type) {
+		case bool:
+			f.SetBool(b)
+		case string:
+			v, err := strconv.ParseBool(b)
+			if err != nil {
+				return err
+			}
+			f.SetBool(v)
+		default:
+			return fmt.Errorf("want bool, got %v", raw)
+		}
+	case reflect.Int:
+		s, err := number(raw)
+		if err != nil {
+			return err
+		}
+		n, err := strconv.ParseInt(s, 10, 0)
+		if err != nil {
+			return err
+		}
+		f.SetInt(n)
+	case reflect.Uint64:
+		s, err := number(raw)
+		if err != nil {
+			return err
+		}
+		var n uint64
+		if size {
+			n, err = parseSize(s)
+		} else {
+			n, err = strconv.ParseUint(s, 10, 64)
+		}
+		if err != nil {
+			return err
+		}
+		f.SetUint(n)
+	default:
+		return fmt.Errorf("unsupported type %v", f.Type())
+	}
+	return nil
+}
+
+// number returns raw decoded as integer or string in its string form.
+func number(raw any) (string, error) {
+	switch n := raw.(type) {
+	case string:
+		return strings.TrimSpace(n), nil
+	case int:
+		return strconv.Itoa(n), nil
+	case int64:
+		return strconv.FormatInt(n, 10), nil
+	case uint64:
+		return strconv.FormatUint(n, 10), nil
+	case json.Number:
+		return n.String(), nil
+	case float64:
+		if n == float64(int64(n)) {
+			return strconv.FormatInt(int64(n), 10), nil
+		}
+	}
+	return "", fmt.Errorf("want integer, got %v", raw)
+}
+
+// parseSize returns s which is either a number or a number with unit in sizeUnits.
+func parseSize(s string) (uint64, error) {
+	i := strings.IndexFunc(s, func(r rune) bool { return r < '0' || r > '9' })
+	if i < 0 {
+		return strconv.ParseUint(s, 10, 64)
+	}
+	unit, ok := sizeUnits[strings.TrimSpace(s[i:])]
+	if !ok || i == 0 {
+		return 0, fmt.Errorf("invalid size: %q", s)
+	}
+	n, err := strconv.ParseUint(s[:i], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if n > math.MaxUint64/unit {
+		return 0, fmt.Errorf("size overflows: %q", s)
+	}
+	return n * unit, nil
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
@@ -0,0 +1,219 @@
+package config
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDecode(t *testing.T) {
+	want := NewDefaultFactoryConfig()
+	want.FilterConfig.BitmapConfig.Type = BitmapTypeRedis
+	want.FilterConfig.M = 256 << 20
+	want.FilterConfig.K = 7
+	want.RedisConfig.Addr = "localhost:6379"
+	want.RedisConfig.Timeout = 500 * time.Millisecond
+	want.RedisConfig.Key = "bf"
+	want.RedisConfig.Resilience.Enable = true
+	want.RedisConfig.Resilience.Retries = 2
+	want.RedisConfig.Resilience.FailPolicy = FailPolicyOpen
+	want.RotatorConfig.Enable = true
+	want.RotatorConfig.Mode = RotatorModeTruncatedTime
+	want.RotatorConfig.Freq = 3 * time.Hour
+
+	tests := []struct {
+		name   string
+		format Format
+		data   string
+	}{
+		{
+			name:   "yaml",
+			format: FormatYAML,
+			data: `
+filter:
+  bitmap:
+    type: redis
+  m: 256MiB
+  k: 7
+redis:
+  addr: localhost:6379
+  timeout: 500ms
+  key: bf
+  resilience:
+    enable: true
+    retries: 2
+    fail_policy: open
+rotator:
+  enable: true
+  mode: truncated-time
+  freq: 3h
+`,
+		},
+		{
+			name:   "json",
+			format: FormatJSON,
+			data: `{
+  "filter": {"bitmap": {"type": "redis"}, "m": 268435456, "k": 7},
+  "redis": {
+    "addr": "localhost:6379", "timeout": "500ms", "key": "bf",
+    "resilience": {"enable": true, "retries": 2, "fail_policy": "open"}
+  },
+  "rotator": {"enable": true, "mode": "truncated-time", "freq": "3h"}
+}`,
+		},
+		{
+			name:   "toml",
+			format: FormatTOML,
+			data: `
+[filter]
+m = "256MiB"
+k = 7
+[filter.bitmap]
+type = "redis"
+[redis]
+addr = "localhost:6379"
+timeout = "500ms"
+key = "bf"
+[redis.resilience]
+enable = true
+retries = 2
+fail_policy = "open"
+[rotator]
+enable = true
+mode = "truncated-time"
+freq = "3h"
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := NewDefaultFactoryConfig()
+			err := Decode(&cfg, []byte(tt.data), tt.format)
+			assert.NoError(t, err)
+			assert.Equal(t, want, cfg)
+			assert.NoError(t, cfg.Validate())
+		})
+	}
+}
+
+func TestDecode_invalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		wantPath string
+	}{
+		{
+			name:     "unknown field",
+			data:     "redis:\n  adr: localhost:6379\n",
+			wantPath: "redis.adr",
+		},
+		{
+			name:     "duration without unit",
+			data:     "rotator:\n  freq: 3\n",
+			wantPath: "rotator.freq",
+		},
+		{
+			name:     "invalid size",
+			data:     "filter:\n  m: 256MB/s\n",
+			wantPath: "filter.m",
+		},
+		{
+			name:     "size on non-size field",
+			data:     "filter:\n  k: 1KiB\n",
+			wantPath: "filter.k",
+		},
+		{
+			name:     "scalar as table",
+			data:     "filter:\n  bitmap: redis\n",
+			wantPath: "filter.bitmap",
+		},
+		{
+			name:     "invalid bool",
+			data:     "tiered:\n  enable: maybe\n",
+			wantPath: "tiered.enable",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := NewDefaultFactoryConfig()
+			err := Decode(&cfg, []byte(tt.data), FormatYAML)
+			var fe *FieldError
+			if assert.True(t, errors.As(err, &fe), err) {
+				assert.Equal(t, tt.wantPath, fe.Path)
+			}
+		})
+	}
+
+	cfg := NewDefaultFactoryConfig()
+	assert.ErrorIs(t, Decode(&cfg, nil, "ini"), ErrInvalidFormat)
+}
+
+func TestDecodeEnv(t *testing.T) {
+	t.Setenv("BLOOMFILTER_FILTER_M", "1KiB")
+	t.Setenv("BLOOMFILTER_ROTATOR_ENABLE", "true")
+	t.Setenv("BLOOMFILTER_ROTATOR_FREQ", "3h")
+	t.Setenv("BLOOMFILTER_REDIS_RESILIENCE_RETRIES", "3")
+
+	cfg := NewDefaultFactoryConfig()
+	assert.NoError(t, DecodeEnv(&cfg, "bloomfilter"))
+	assert.Equal(t, uint64(1024), cfg.FilterConfig.M)
+	assert.Equal(t, uint64(3), cfg.FilterConfig.K)
+	assert.True(t, cfg.RotatorConfig.Enable)
+	assert.Equal(t, 3*time.Hour, cfg.RotatorConfig.Freq)
+	assert.Equal(t, 3, cfg.RedisConfig.Resilience.Retries)
+
+	t.Setenv("BLOOMFILTER_ROTATOR_FREQ", "3 hours")
+	err := DecodeEnv(&cfg, "BLOOMFILTER")
+	var fe *FieldError
+	if assert.True(t, errors.As(err, &fe), err) {
+		assert.Equal(t, "rotator.freq", fe.Path)
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "bloomfilter.yml")
+	assert.NoError(t, os.WriteFile(path, []byte("filter:\n  k: 5\nrotator:\n  enable: true\n"), 0o600))
+
+	// freq is required by the enabled rotator.
+	_, err := LoadFile(path, "")
+	var fe *FieldError
+	if assert.True(t, errors.As(err, &fe), err) {
+		assert.Equal(t, "rotator.freq", fe.Path)
+	}
+
+	t.Setenv("BF_ROTATOR_FREQ", "1h")
+	cfg, err := LoadFile(path, "BF")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(256<<20), cfg.FilterConfig.M)
+	assert.Equal(t, uint64(5), cfg.FilterConfig.K)
+	assert.Equal(t, time.Hour, cfg.RotatorConfig.Freq)
+
+	_, err = LoadFile(filepath.Join(dir, "bloomfilter.ini"), "")
+	assert.ErrorIs(t, err, ErrInvalidFormat)
+}
+
+func TestFactoryConfig_Validate_path(t *testing.T) {
+	cfg := NewDefaultFactoryConfig()
+	cfg.FilterConfig.BitmapConfig.Type = BitmapTypeRedis
+	cfg.RedisConfig = RedisConfig{
+		Addr:    "localhost:6379",
+		Timeout: time.Second,
+		Key:     "bf",
+		Resilience: ResilienceConfig{
+			Enable:           true,
+			FailureThreshold: 3,
+		},
+	}
+	err := cfg.Validate()
+	assert.EqualError(t, err, "redis.resilience.cooldown: cooldown <= 0")
+
+	cfg.RedisConfig.Resilience.Cooldown = time.Second
+	cfg.RedisConfig.MetaPolicy = "drop"
+	err = cfg.Validate()
+	assert.ErrorIs(t, err, ErrInvalidMetaPolicy)
+	assert.Contains(t, err.Error(), "redis.meta_policy: ")
+}
@@ -14,6 +14,8 @@ var (
 type BitmapFactoryCtxValue struct {
 	IsRotatorEnabled bool
 	IsNextFilter     bool
-	RotatorMode      config.RotatorMode
-	Now              time.Time
+	// IsInitial indicates the filter is generated when rotator starts instead of by rotation.
+	IsInitial   bool
+	RotatorMode config.RotatorMode
+	Now         time.Time
 }
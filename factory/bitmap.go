@@ -2,11 +2,15 @@ package factory
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/go-redis/redis/v8"
 	"github.com/x0rworld/go-bloomfilter/bitmap"
 	"github.com/x0rworld/go-bloomfilter/config"
 	"github.com/x0rworld/go-bloomfilter/core"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -21,7 +25,9 @@ func (imf *InMemoryBitmapFactory) NewBitmap(_ context.Context) (bitmap.Bitmap, e
 }
 
 type RedisBitmapFactory struct {
-	cfg config.FactoryConfig
+	cfg        config.FactoryConfig
+	clientOnce sync.Once
+	client     *redis.Client
 }
 
 // NewBitmap returns bitmap.Redis.
@@ -31,9 +37,19 @@ type RedisBitmapFactory struct {
 //  1. append timestamp of current time to the key and
 //  2. additionally set TTL to redis server via bitmap.RedisOption (TTL would be the 2 times of freq plus 5 minutes)
 //
+// If config.RotatorConfig.Schedule is present, key and TTL refer to the schedule instead of freq, see scheduledKey.
+//
+// Except config.RotatorModeTruncatedTime whose keys are deterministic, keys are recorded in registry (see RedisRegistryKey).
+// When value.IsInitial is true, e.g. the rotator restarts, the alive bitmap recorded in registry is adopted instead of
+// creating a new one. It assumes the key is used by a single rotator.
+//
+// In config.RotatorModeManual, key of next bitmap uses its creation time instead of adding freq,
+// and TTL is only set if freq is configured.
+//
 // Rationale:
 //
 //   - 2 times of freq: Each bitmap of redis would stay 2 times of freq due to rotation (being current & next).
+//     With config.RotatorLookupAll, it stays additional config.RotatorConfig.Retain times of freq as retired filters.
 //   - additional 5 minutes: Basically, it just needs 2 times of freq for rotation.
 //     However, set gracefully additional 5 minutes here is preventing corner case just in case.
 //     For example, the bitmap of redis calls SetBits to operate expired bitset deleted by redis server before the rotation is performed.
@@ -47,44 +63,296 @@ type RedisBitmapFactory struct {
 // 3-1) value.IsNextFilter == false, the key of bitmap would be `go-bloomfilter_1662444000000000000`. (`1662444000000000000` is unix timestamp of `2022-09-06 06:00:00`.)
 //
 // 3-2) value.IsNextFilter == true, the key of bitmap would be `go-bloomfilter_1662454800000000000`. (`1662454800000000000` is unix timestamp of `2022-09-06 09:00:00`.)
+//
+// Each bitmap is accompanied by metadata (see RedisMeta), which is written on creation and validated on open,
+// see config.MetaPolicy for the handling of mismatch.
+// If config.RedisConfig.Preallocate is true, every bitmap including rotated ones is pre-allocated to m bits.
 func (rf *RedisBitmapFactory) NewBitmap(ctx context.Context) (bitmap.Bitmap, error) {
-	client := redis.NewClient(&redis.Options{
-		Addr:         rf.cfg.RedisConfig.Addr,
-		ReadTimeout:  rf.cfg.RedisConfig.Timeout,
-		WriteTimeout: rf.cfg.RedisConfig.Timeout,
+	client := rf.redisClient()
+	bm, err := rf.newRedis(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	if err := rf.ensureMeta(ctx, client, bm); err != nil {
+		return nil, err
+	}
+	return bm, nil
+}
+
+// redisClient returns the client shared by bitmaps of the factory, it's created by config.RedisConfig at the first call
+// unless the factory is created by NewRedisBitmapFactory.
+func (rf *RedisBitmapFactory) redisClient() *redis.Client {
+	rf.clientOnce.Do(func() {
+		if rf.client != nil {
+			return
+		}
+		rf.client = redis.NewClient(&redis.Options{
+			Addr:         rf.cfg.RedisConfig.Addr,
+			ReadTimeout:  rf.cfg.RedisConfig.Timeout,
+			WriteTimeout: rf.cfg.RedisConfig.Timeout,
+		})
 	})
+	return rf.client
+}
+
+// newRedis returns bitmap.Redis whose key and TTL refer to value of ctx, see NewBitmap.
+func (rf *RedisBitmapFactory) newRedis(ctx context.Context, client *redis.Client) (*bitmap.Redis, error) {
+	logger := loggerOf(rf.cfg)
 	val, ok := ctx.Value(core.BitmapFactoryCtxKey).(core.BitmapFactoryCtxValue)
-	if ok && val.IsRotatorEnabled {
-		now := val.Now
-		if val.IsNextFilter {
-			now = now.Add(rf.cfg.RotatorConfig.Freq)
+	if !ok || !val.IsRotatorEnabled {
+		logger.Debug("redis bitmap key", "key", rf.cfg.RedisConfig.Key)
+		return bitmap.NewRedis(ctx, client, rf.cfg.RedisConfig.Key, rf.cfg.FilterConfig.M, rf.redisOptions()...)
+	}
+
+	// keys of truncated-time are deterministic, the others are tracked by registry to be restored after restart.
+	useRegistry := val.RotatorMode != config.RotatorModeTruncatedTime
+	if useRegistry && val.IsInitial {
+		bm, err := rf.restoreBitmap(ctx, client, val)
+		if err != nil {
+			return nil, err
+		}
+		if bm != nil {
+			logger.Info("redis bitmap restored", "key", bm.Key(), "next", val.IsNextFilter)
+			return bm, nil
+		}
+	}
+
+	key, ttl, err := rf.rotatingKey(val)
+	if err != nil {
+		return nil, err
+	}
+	opts := rf.redisOptions()
+	// freq is optional in manual mode, bitmap won't expire without it.
+	if ttl > 0 {
+		opts = append(opts, bitmap.RedisSetExpireTTL(ttl))
+	}
+	logger.Info("redis bitmap key", "key", key, "next", val.IsNextFilter)
+	bm, err := bitmap.NewRedis(ctx, client, key, rf.cfg.FilterConfig.M, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if ttl > 0 {
+		logger.Debug("redis bitmap ttl applied", "key", key, "ttl", ttl)
+	}
+	if useRegistry {
+		if err := rf.register(ctx, client, val, key, ttl); err != nil {
+			return nil, err
+		}
+	}
+	return bm, nil
+}
+
+// rotatingKey returns key and TTL of bitmap for rotator.
+func (rf *RedisBitmapFactory) rotatingKey(val core.BitmapFactoryCtxValue) (string, time.Duration, error) {
+	if rf.cfg.RotatorConfig.Schedule != "" && val.RotatorMode != config.RotatorModeManual {
+		return rf.scheduledKey(val)
+	}
+	now := val.Now
+	// in manual mode, rotation time is unknown in advance so that key of next bitmap refers to its creation time.
+	if val.IsNextFilter && val.RotatorMode != config.RotatorModeManual {
+		now = now.Add(rf.cfg.RotatorConfig.Freq)
+	}
+	if val.RotatorMode == config.RotatorModeTruncatedTime {
+		now = now.Truncate(rf.cfg.RotatorConfig.Freq)
+	}
+	var ttl time.Duration
+	if rf.cfg.RotatorConfig.Freq > 0 {
+		ttl = rf.cfg.RotatorConfig.Freq*time.Duration(rf.cfg.RotatorConfig.Generations()) + RedisGracefulExpireTTL
+	}
+	return fmt.Sprintf("%s_%d", rf.cfg.RedisConfig.Key, now.UnixNano()), ttl, nil
+}
+
+// scheduledKey returns key and TTL of bitmap for rotator driven by config.RotatorConfig.Schedule.
+//
+// The key refers to activation time of schedule instead of freq:
+//   - next bitmap: the next activation time after value.Now.
+//   - current bitmap: the previous activation time at or before value.Now if value.RotatorMode == config.RotatorModeTruncatedTime,
+//     otherwise value.Now.
+//
+// TTL covers the following activations as many as config.RotatorConfig.Generations(), plus RedisGracefulExpireTTL.
+func (rf *RedisBitmapFactory) scheduledKey(val core.BitmapFactoryCtxValue) (string, time.Duration, error) {
+	sched, err := rf.cfg.RotatorConfig.NewSchedule()
+	if err != nil {
+		return "", 0, err
+	}
+	now := val.Now
+	if val.IsNextFilter {
+		now = sched.Next(now)
+	} else if val.RotatorMode == config.RotatorModeTruncatedTime {
+		now = sched.Prev(now)
+	}
+	expireAt := val.Now
+	for i := 0; i < rf.cfg.RotatorConfig.Generations(); i++ {
+		expireAt = sched.Next(expireAt)
+	}
+	return fmt.Sprintf("%s_%d", rf.cfg.RedisConfig.Key, now.UnixNano()), expireAt.Sub(val.Now) + RedisGracefulExpireTTL, nil
+}
+
+// RedisRegistryKey returns key of the registry which records keys of current and next bitmap for rotator,
+// it's a hash with fields `current` and `next`.
+func RedisRegistryKey(key string) string {
+	return key + "_registry"
+}
+
+// shiftRegistryScript records key of the new next bitmap and shifts the previous next one to current after rotation.
+var shiftRegistryScript = redis.NewScript(`
+local prev = redis.call('HGET', KEYS[1], 'next')
+if prev then
+	redis.call('HSET', KEYS[1], 'current', prev)
+end
+redis.call('HSET', KEYS[1], 'next', ARGV[1])
+if tonumber(ARGV[2]) > 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 1
+`)
+
+func registryField(val core.BitmapFactoryCtxValue) string {
+	if val.IsNextFilter {
+		return "next"
+	}
+	return "current"
+}
+
+// restoreBitmap returns bitmap.Redis recorded in registry if it's still alive and its period hasn't passed,
+// otherwise returns nil. The remaining TTL of the restored bitmap is kept.
+func (rf *RedisBitmapFactory) restoreBitmap(ctx context.Context, client *redis.Client, val core.BitmapFactoryCtxValue) (*bitmap.Redis, error) {
+	key, err := client.HGet(ctx, RedisRegistryKey(rf.cfg.RedisConfig.Key), registryField(val)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	stale, err := rf.stale(val, key)
+	if err != nil {
+		return nil, err
+	}
+	if stale {
+		loggerOf(rf.cfg).Info("redis bitmap in registry is stale", "key", key, "next", val.IsNextFilter)
+		return nil, nil
+	}
+	n, err := client.Exists(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, nil
+	}
+	return bitmap.NewRedis(ctx, client, key, rf.cfg.FilterConfig.M, rf.redisOptions()...)
+}
+
+// stale returns true if the period of key recorded in registry has passed at value.Now, e.g. after downtime longer
+// than freq. The time embedded in key is the activation time of the bitmap (see rotatingKey and scheduledKey):
+//   - next bitmap is stale once it's activated.
+//   - current bitmap is stale once the following activation, i.e. its period, has passed.
+//
+// Keys of manual mode are never stale since their rotation time is unknown, as well as keys not created by the factory.
+func (rf *RedisBitmapFactory) stale(val core.BitmapFactoryCtxValue, key string) (bool, error) {
+	if val.RotatorMode == config.RotatorModeManual {
+		return false, nil
+	}
+	ns, err := strconv.ParseInt(strings.TrimPrefix(key, rf.cfg.RedisConfig.Key+"_"), 10, 64)
+	if err != nil {
+		return false, nil
+	}
+	activatedAt := time.Unix(0, ns)
+	if val.IsNextFilter {
+		return !val.Now.Before(activatedAt), nil
+	}
+	end := activatedAt.Add(rf.cfg.RotatorConfig.Freq)
+	if rf.cfg.RotatorConfig.Schedule != "" {
+		sched, err := rf.cfg.RotatorConfig.NewSchedule()
+		if err != nil {
+			return false, err
 		}
-		if val.RotatorMode == config.RotatorModeTruncatedTime {
-			now = now.Truncate(rf.cfg.RotatorConfig.Freq)
+		end = sched.Next(activatedAt)
+	}
+	return !val.Now.Before(end), nil
+}
+
+// redisOptions returns bitmap.RedisOption applied to every bitmap created by the factory.
+func (rf *RedisBitmapFactory) redisOptions() []bitmap.RedisOption {
+	var opts []bitmap.RedisOption
+	if rf.cfg.RedisConfig.Preallocate {
+		opts = append(opts, bitmap.RedisPreallocate())
+	}
+	return opts
+}
+
+// register records key into registry, the initial bitmap is recorded as is while rotation shifts the registry.
+func (rf *RedisBitmapFactory) register(ctx context.Context, client *redis.Client, val core.BitmapFactoryCtxValue, key string, ttl time.Duration) error {
+	registry := RedisRegistryKey(rf.cfg.RedisConfig.Key)
+	if !val.IsInitial {
+		return shiftRegistryScript.Run(ctx, client, []string{registry}, key, ttl.Milliseconds()).Err()
+	}
+	_, err := client.TxPipelined(ctx, func(pl redis.Pipeliner) error {
+		pl.HSet(ctx, registry, registryField(val), key)
+		if ttl > 0 {
+			pl.PExpire(ctx, registry, ttl)
 		}
-		return bitmap.NewRedis(
-			ctx,
-			client,
-			fmt.Sprintf("%s_%d", rf.cfg.RedisConfig.Key, now.UnixNano()),
-			rf.cfg.FilterConfig.M,
-			bitmap.RedisSetExpireTTL(rf.cfg.RotatorConfig.Freq*2+RedisGracefulExpireTTL),
-		)
-	} else {
-		return bitmap.NewRedis(ctx, client, rf.cfg.RedisConfig.Key, rf.cfg.FilterConfig.M)
+		return nil
+	})
+	return err
+}
+
+// newResilientBitmap decorates bm by bitmap.Resilient as config.ResilienceConfig,
+// the fallback of config.FailPolicyFallback is bitmap.InMemory having the same m.
+func newResilientBitmap(cfg config.FactoryConfig, bm bitmap.Bitmap) (bitmap.Bitmap, error) {
+	rc := cfg.RedisConfig.Resilience
+	opts := []bitmap.ResilientOption{
+		bitmap.ResilientTimeout(rc.CallTimeout),
+		bitmap.ResilientRetries(rc.Retries, rc.RetryBackoff),
+		bitmap.ResilientBreaker(rc.FailureThreshold, rc.Cooldown),
 	}
+	switch rc.FailPolicy {
+	case config.FailPolicyOpen:
+		opts = append(opts, bitmap.ResilientFailPolicy(bitmap.FailPolicyOpen, nil))
+	case config.FailPolicyClosed:
+		opts = append(opts, bitmap.ResilientFailPolicy(bitmap.FailPolicyClosed, nil))
+	case config.FailPolicyFallback:
+		opts = append(opts, bitmap.ResilientFailPolicy(bitmap.FailPolicyFallback, bitmap.NewInMemory(cfg.FilterConfig.M)))
+	}
+	return bitmap.NewResilient(bm, opts...)
+}
+
+// NewRedisBitmapFactory returns RedisBitmapFactory whose bitmaps are created by client instead of a client connecting to
+// config.RedisConfig.Addr, the other fields of cfg.RedisConfig such as Key are still used.
+// Addr and Timeout of cfg.RedisConfig are filled by client if they're absent.
+func NewRedisBitmapFactory(cfg config.FactoryConfig, client *redis.Client) (*RedisBitmapFactory, error) {
+	if client == nil {
+		return nil, errors.New("nil redis client")
+	}
+	cfg.FilterConfig.BitmapConfig.Type = config.BitmapTypeRedis
+	if cfg.RedisConfig.Addr == "" {
+		cfg.RedisConfig.Addr = client.Options().Addr
+	}
+	if cfg.RedisConfig.Timeout == 0 {
+		cfg.RedisConfig.Timeout = client.Options().ReadTimeout
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &RedisBitmapFactory{cfg: cfg, client: client}, nil
 }
 
 // NewBitmapFactory does config validation with config.FactoryConfig before returns BitmapFactory depending on cfg.FilterConfig.BitmapConfig.Type.
-// If type of bitmap is not recognized, return bitmap.InMemory by default.
+// Types other than built-in ones are looked up from bitmaps registered by RegisterBitmap,
+// it returns config.ErrInvalidBitmapType if the type is not recognized.
 func NewBitmapFactory(cfg config.FactoryConfig) (BitmapFactory, error) {
 	// validate config
 	if err := cfg.Validate(); err != nil {
 		return nil, err
 	}
 	switch cfg.FilterConfig.BitmapConfig.Type {
+	case config.BitmapTypeInMemory:
+		return &InMemoryBitmapFactory{cfg: cfg}, nil
 	case config.BitmapTypeRedis:
 		return &RedisBitmapFactory{cfg: cfg}, nil
-	default:
-		return &InMemoryBitmapFactory{cfg: cfg}, nil
 	}
+	constructor, ok := registeredBitmap(cfg.FilterConfig.BitmapConfig.Type)
+	if !ok {
+		return nil, config.ErrInvalidBitmapType
+	}
+	return &RegisteredBitmapFactory{cfg: cfg, constructor: constructor}, nil
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
 	"github.com/stretchr/testify/assert"
 	"github.com/x0rworld/go-bloomfilter/bitmap"
 	"github.com/x0rworld/go-bloomfilter/config"
@@ -273,6 +274,121 @@ func TestRedisBitmapFactory_NewBitmap(t *testing.T) {
 				redisKeyTTL: freq*2 + 5*time.Minute,
 			},
 		},
+		{
+			name: "rotator is enabled: lookup = all; validate TTL of retained bf",
+			fields: fields{
+				cfg: config.FactoryConfig{
+					FilterConfig: config.FilterConfig{
+						BitmapConfig: config.BitmapConfig{
+							Type: config.BitmapTypeRedis,
+						},
+						M: 100,
+						K: 3,
+					},
+					RedisConfig: config.RedisConfig{
+						Addr:    mr.Addr(),
+						Timeout: time.Second,
+						Key:     "test-RedisBitmapFactory_NewBitmap-rotatorIsEnabled-lookupIsAll",
+					},
+					RotatorConfig: config.RotatorConfig{
+						Enable: true,
+						Freq:   freq,
+						Mode:   config.RotatorModeDefault,
+						Lookup: config.RotatorLookupAll,
+						Retain: 2,
+					},
+				},
+			},
+			args: args{ctx: func() context.Context {
+				ctx := context.WithValue(context.Background(), core.BitmapFactoryCtxKey, core.BitmapFactoryCtxValue{
+					IsRotatorEnabled: true,
+					IsNextFilter:     false,
+					RotatorMode:      config.RotatorModeDefault,
+					Now:              fakeTimeFunc(),
+				})
+				return ctx
+			}()},
+			wantErr: assert.NoError,
+			expect: expect{
+				redisKey:    fmt.Sprintf("%s_%d", "test-RedisBitmapFactory_NewBitmap-rotatorIsEnabled-lookupIsAll", fakeTimeFunc().UnixNano()),
+				redisKeyTTL: freq*4 + 5*time.Minute,
+			},
+		},
+		{
+			name: "rotator is enabled: type = truncated-time; schedule = daily",
+			fields: fields{
+				cfg: config.FactoryConfig{
+					FilterConfig: config.FilterConfig{
+						BitmapConfig: config.BitmapConfig{
+							Type: config.BitmapTypeRedis,
+						},
+						M: 100,
+						K: 3,
+					},
+					RedisConfig: config.RedisConfig{
+						Addr:    mr.Addr(),
+						Timeout: time.Second,
+						Key:     "test-RedisBitmapFactory_NewBitmap-rotatorIsEnabled-scheduleIsDaily",
+					},
+					RotatorConfig: config.RotatorConfig{
+						Enable:   true,
+						Mode:     config.RotatorModeTruncatedTime,
+						Schedule: "@daily",
+					},
+				},
+			},
+			args: args{ctx: func() context.Context {
+				ctx := context.WithValue(context.Background(), core.BitmapFactoryCtxKey, core.BitmapFactoryCtxValue{
+					IsRotatorEnabled: true,
+					IsNextFilter:     false,
+					RotatorMode:      config.RotatorModeTruncatedTime,
+					Now:              time.Date(2022, 9, 6, 8, 0, 0, 0, time.UTC),
+				})
+				return ctx
+			}()},
+			wantErr: assert.NoError,
+			expect: expect{
+				redisKey:    fmt.Sprintf("%s_%d", "test-RedisBitmapFactory_NewBitmap-rotatorIsEnabled-scheduleIsDaily", time.Date(2022, 9, 6, 0, 0, 0, 0, time.UTC).UnixNano()),
+				redisKeyTTL: 40*time.Hour + 5*time.Minute,
+			},
+		},
+		{
+			name: "rotator is enabled: type = manual; validate next bf",
+			fields: fields{
+				cfg: config.FactoryConfig{
+					FilterConfig: config.FilterConfig{
+						BitmapConfig: config.BitmapConfig{
+							Type: config.BitmapTypeRedis,
+						},
+						M: 100,
+						K: 3,
+					},
+					RedisConfig: config.RedisConfig{
+						Addr:    mr.Addr(),
+						Timeout: time.Second,
+						Key:     "test-RedisBitmapFactory_NewBitmap-rotatorIsEnabled-modeIsManual-validateNextBf",
+					},
+					RotatorConfig: config.RotatorConfig{
+						Enable: true,
+						Mode:   config.RotatorModeManual,
+					},
+				},
+			},
+			args: args{ctx: func() context.Context {
+				ctx := context.WithValue(context.Background(), core.BitmapFactoryCtxKey, core.BitmapFactoryCtxValue{
+					IsRotatorEnabled: true,
+					IsNextFilter:     true,
+					RotatorMode:      config.RotatorModeManual,
+					Now:              fakeTimeFunc(),
+				})
+				return ctx
+			}()},
+			wantErr: assert.NoError,
+			expect: expect{
+				redisKey:    fmt.Sprintf("%s_%d", "test-RedisBitmapFactory_NewBitmap-rotatorIsEnabled-modeIsManual-validateNextBf", fakeTimeFunc().UnixNano()),
+				redisKeyTTL: 0,
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -290,3 +406,144 @@ func TestRedisBitmapFactory_NewBitmap(t *testing.T) {
 		})
 	}
 }
+
+func TestRedisBitmapFactory_NewBitmap_restore(t *testing.T) {
+	mr := miniredis.RunT(t)
+	defer mr.Close()
+
+	freq := 10 * time.Second
+	key := "test-RedisBitmapFactory_NewBitmap_restore"
+	rf := &RedisBitmapFactory{
+		cfg: config.FactoryConfig{
+			FilterConfig: config.FilterConfig{
+				BitmapConfig: config.BitmapConfig{
+					Type: config.BitmapTypeRedis,
+				},
+				M: 100,
+				K: 3,
+			},
+			RedisConfig: config.RedisConfig{
+				Addr:    mr.Addr(),
+				Timeout: time.Second,
+				Key:     key,
+			},
+			RotatorConfig: config.RotatorConfig{
+				Enable: true,
+				Freq:   freq,
+				Mode:   config.RotatorModeDefault,
+			},
+		},
+	}
+	newBitmap := func(isNext, isInitial bool, now time.Time) string {
+		ctx := context.WithValue(context.Background(), core.BitmapFactoryCtxKey, core.BitmapFactoryCtxValue{
+			IsRotatorEnabled: true,
+			IsNextFilter:     isNext,
+			IsInitial:        isInitial,
+			RotatorMode:      config.RotatorModeDefault,
+			Now:              now,
+		})
+		bm, err := rf.NewBitmap(ctx)
+		assert.NoError(t, err)
+		return bm.(*bitmap.Redis).Key()
+	}
+
+	// start up, then perform a rotation
+	start := time.Date(2022, 9, 6, 8, 24, 31, 0, time.UTC)
+	_ = newBitmap(false, true, start)
+	next := newBitmap(true, true, start)
+	rotated := newBitmap(true, false, start.Add(freq))
+	assert.Equal(t, next, mr.HGet(RedisRegistryKey(key), "current"))
+	assert.Equal(t, rotated, mr.HGet(RedisRegistryKey(key), "next"))
+
+	// restart: alive bitmaps are adopted
+	restart := start.Add(freq + time.Second)
+	assert.Equal(t, next, newBitmap(false, true, restart))
+	assert.Equal(t, rotated, newBitmap(true, true, restart))
+
+	// restart after current expired: only the missing one is created
+	mr.Del(next)
+	current := newBitmap(false, true, restart)
+	assert.Equal(t, fmt.Sprintf("%s_%d", key, restart.UnixNano()), current)
+	assert.Equal(t, current, mr.HGet(RedisRegistryKey(key), "current"))
+	assert.Equal(t, rotated, newBitmap(true, true, restart))
+
+	// restart after the period of bitmaps passed: fresh ones are created even if they are still alive
+	restart = restart.Add(5 * freq)
+	assert.True(t, mr.Exists(current))
+	assert.True(t, mr.Exists(rotated))
+	assert.Equal(t, fmt.Sprintf("%s_%d", key, restart.UnixNano()), newBitmap(false, true, restart))
+	assert.Equal(t, fmt.Sprintf("%s_%d", key, restart.Add(freq).UnixNano()), newBitmap(true, true, restart))
+}
+
+func TestRedisBitmapFactory_NewBitmap_preallocate(t *testing.T) {
+	mr := miniredis.RunT(t)
+	defer mr.Close()
+
+	freq := 10 * time.Second
+	rf := &RedisBitmapFactory{
+		cfg: config.FactoryConfig{
+			FilterConfig: config.FilterConfig{
+				BitmapConfig: config.BitmapConfig{
+					Type: config.BitmapTypeRedis,
+				},
+				M: 1000,
+				K: 3,
+			},
+			RedisConfig: config.RedisConfig{
+				Addr:        mr.Addr(),
+				Timeout:     time.Second,
+				Key:         "test-RedisBitmapFactory_NewBitmap_preallocate",
+				Preallocate: true,
+			},
+			RotatorConfig: config.RotatorConfig{
+				Enable: true,
+				Freq:   freq,
+				Mode:   config.RotatorModeDefault,
+			},
+		},
+	}
+	now := time.Date(2022, 9, 6, 8, 24, 31, 0, time.UTC)
+	for _, val := range []core.BitmapFactoryCtxValue{
+		{IsRotatorEnabled: true, IsInitial: true, RotatorMode: config.RotatorModeDefault, Now: now},
+		{IsRotatorEnabled: true, IsNextFilter: true, IsInitial: true, RotatorMode: config.RotatorModeDefault, Now: now},
+		{IsRotatorEnabled: true, IsNextFilter: true, RotatorMode: config.RotatorModeDefault, Now: now.Add(freq)},
+	} {
+		bm, err := rf.NewBitmap(context.WithValue(context.Background(), core.BitmapFactoryCtxKey, val))
+		assert.NoError(t, err)
+		n, err := bm.(*bitmap.Redis).AllocatedBytes()
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(125), n)
+		// TTL is still applied
+		assert.Equal(t, 2*freq+RedisGracefulExpireTTL, mr.TTL(bm.(*bitmap.Redis).Key()))
+	}
+}
+
+func TestNewRedisBitmapFactory(t *testing.T) {
+	mr := miniredis.RunT(t)
+	defer mr.Close()
+
+	cfg := config.FactoryConfig{
+		FilterConfig: config.FilterConfig{M: 1000, K: 3},
+		RedisConfig:  config.RedisConfig{Key: "test-NewRedisBitmapFactory"},
+	}
+	_, err := NewRedisBitmapFactory(cfg, nil)
+	assert.Error(t, err)
+
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	rf, err := NewRedisBitmapFactory(cfg, client)
+	assert.NoError(t, err)
+	assert.Equal(t, mr.Addr(), rf.cfg.RedisConfig.Addr)
+
+	// bitmaps share the given client.
+	for i := 0; i < 2; i++ {
+		_, err = rf.NewBitmap(context.Background())
+		assert.NoError(t, err)
+		assert.Same(t, client, rf.redisClient())
+	}
+	assert.True(t, mr.Exists("test-NewRedisBitmapFactory"))
+
+	cfg.RedisConfig.Key = ""
+	_, err = NewRedisBitmapFactory(cfg, client)
+	assert.Error(t, err)
+}
@@ -2,26 +2,82 @@ package factory
 
 import (
 	"context"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"github.com/x0rworld/go-bloomfilter/bitmap"
 	"github.com/x0rworld/go-bloomfilter/config"
 	"github.com/x0rworld/go-bloomfilter/filter"
 	"github.com/x0rworld/go-bloomfilter/filter/rotator"
+	"github.com/x0rworld/go-bloomfilter/filter/tiered"
+	"github.com/x0rworld/go-bloomfilter/logging"
+	"github.com/x0rworld/go-bloomfilter/metrics"
+	"sync"
+	"time"
 )
 
 type BloomFilterFactory struct {
 	cfg config.FactoryConfig
+	// bitmap factory is created once and shared by filters, e.g. the client of RedisBitmapFactory.
+	bmfOnce sync.Once
+	bmf     BitmapFactory
+	bmfErr  error
 }
 
 // NewFilter returns filters depends on config.FactoryConfig.
+// If metrics is enabled, bitmap is decorated by metrics.Bitmap and filter is decorated by metrics.Filter.
+// If resilience of redis is enabled, bitmap is decorated by bitmap.Resilient, failures are still recorded by metrics.
+// If tiered is enabled, returns tiered.Tiered caching bitmap.Redis by local bitmap.InMemory.
 func (f *BloomFilterFactory) NewFilter(ctx context.Context) (filter.Filter, error) {
-	bmf, err := NewBitmapFactory(f.cfg)
+	logger := loggerOf(f.cfg)
+	bmf, err := f.bitmapFactory()
 	if err != nil {
+		logger.Error("failed to create bitmap factory", "error", err)
 		return nil, err
 	}
 	bm, err := bmf.NewBitmap(ctx)
 	if err != nil {
+		logger.Error("failed to create bitmap", "bitmap", f.cfg.FilterConfig.BitmapConfig.Type, "error", err)
 		return nil, err
 	}
-	return filter.NewBloomFilter(bm, f.cfg.FilterConfig.M, f.cfg.FilterConfig.K), nil
+	logger.Debug("filter created", "bitmap", f.cfg.FilterConfig.BitmapConfig.Type, "m", f.cfg.FilterConfig.M, "k", f.cfg.FilterConfig.K)
+	raw := bm
+	if f.cfg.MetricsConfig.Enable {
+		bm = metrics.NewBitmap(bm, f.cfg.MetricsConfig.Recorder)
+	}
+	if f.cfg.FilterConfig.BitmapConfig.Type == config.BitmapTypeRedis && f.cfg.RedisConfig.Resilience.Enable {
+		bm, err = newResilientBitmap(f.cfg, bm)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var bf filter.Filter
+	if f.cfg.TieredConfig.Enable {
+		rbm, ok := raw.(*bitmap.Redis)
+		if !ok {
+			return nil, fmt.Errorf("tiered filter requires redis bitmap, got %T", raw)
+		}
+		bf, err = tiered.NewTiered(ctx, bm, rbm.Bytes, f.cfg.FilterConfig.M, f.cfg.FilterConfig.K, f.cfg.TieredConfig.SyncInterval)
+		if err != nil {
+			logger.Error("failed to create tiered filter", "key", rbm.Key(), "error", err)
+			return nil, err
+		}
+	} else {
+		bf = filter.NewBloomFilter(bm, f.cfg.FilterConfig.M, f.cfg.FilterConfig.K)
+	}
+
+	// filter is instrumented by RotatorFactory instead if rotator is enabled, otherwise Add would be counted per generation.
+	if !f.cfg.MetricsConfig.Enable || f.cfg.RotatorConfig.Enable {
+		return bf, nil
+	}
+	return metrics.NewFilter(bf, f.cfg.MetricsConfig.Recorder), nil
+}
+
+func (f *BloomFilterFactory) bitmapFactory() (BitmapFactory, error) {
+	f.bmfOnce.Do(func() {
+		f.bmf, f.bmfErr = NewBitmapFactory(f.cfg)
+	})
+	return f.bmf, f.bmfErr
 }
 
 type RotatorFactory struct {
@@ -30,8 +86,38 @@ type RotatorFactory struct {
 }
 
 // NewFilter returns rotator implementing filter that supports doing rotation by goroutine.
+// If metrics is enabled, rotator is decorated by metrics.Filter, use metrics.Filter.Unwrap to get the rotator.
 func (f *RotatorFactory) NewFilter(ctx context.Context) (filter.Filter, error) {
-	return rotator.NewRotator(ctx, f.cfg.RotatorConfig, f.base.NewFilter)
+	logger := loggerOf(f.cfg)
+	r, err := rotator.NewRotator(ctx, f.cfg.RotatorConfig, f.base.NewFilter)
+	if err != nil {
+		logger.Error("failed to create rotator", "error", err)
+		return nil, err
+	}
+	logger.Info("rotator created", "mode", f.cfg.RotatorConfig.Mode, "freq", f.cfg.RotatorConfig.Freq, "schedule", f.cfg.RotatorConfig.Schedule)
+	r.OnRotateDone(func(_ context.Context, d time.Duration, err error) {
+		if err != nil {
+			logger.Error("failed to rotate filter", "duration", d, "error", err)
+			return
+		}
+		logger.Info("filter rotated", "duration", d)
+	})
+	if !f.cfg.MetricsConfig.Enable {
+		return r, nil
+	}
+	rec := f.cfg.MetricsConfig.Recorder
+	r.OnRotateDone(func(_ context.Context, d time.Duration, err error) {
+		rec.ObserveRotation(d, err)
+	})
+	return metrics.NewFilter(r, rec), nil
+}
+
+// loggerOf returns logger of cfg, or logging.NopLogger if it's absent.
+func loggerOf(cfg config.FactoryConfig) logging.Logger {
+	if cfg.Logger == nil {
+		return logging.NopLogger{}
+	}
+	return cfg.Logger
 }
 
 // NewFilterFactory does config validation with config.FactoryConfig before returns FilterFactory.
@@ -51,3 +137,21 @@ func NewFilterFactory(cfg config.FactoryConfig) (FilterFactory, error) {
 	}
 	return factory, nil
 }
+
+// NewFilterFactoryWithRedis is NewFilterFactory whose redis bitmaps are created by client, e.g. to share a client
+// among factories, see NewRedisBitmapFactory.
+func NewFilterFactoryWithRedis(cfg config.FactoryConfig, client *redis.Client) (FilterFactory, error) {
+	rbf, err := NewRedisBitmapFactory(cfg, client)
+	if err != nil {
+		return nil, err
+	}
+	bf := &BloomFilterFactory{cfg: rbf.cfg, bmf: rbf}
+	// bitmap factory is given, so that it's never created by config.
+	bf.bmfOnce.Do(func() {})
+
+	var factory FilterFactory = bf
+	if rbf.cfg.RotatorConfig.Enable {
+		factory = &RotatorFactory{cfg: rbf.cfg, base: factory}
+	}
+	return factory, nil
+}
@@ -2,10 +2,15 @@ package factory
 
 import (
 	"context"
+	"github.com/alicebob/miniredis/v2"
 	"github.com/stretchr/testify/assert"
+	"github.com/x0rworld/go-bloomfilter/bitmap"
 	"github.com/x0rworld/go-bloomfilter/config"
 	"github.com/x0rworld/go-bloomfilter/filter"
 	"github.com/x0rworld/go-bloomfilter/filter/rotator"
+	"github.com/x0rworld/go-bloomfilter/filter/tiered"
+	"github.com/x0rworld/go-bloomfilter/metrics"
+	"sync"
 	"testing"
 	"time"
 )
@@ -122,3 +127,225 @@ func TestRotatorFactory_NewFilter(t *testing.T) {
 	assert.NoError(t, err)
 	assert.IsType(t, &rotator.Rotator{}, f)
 }
+
+func TestNewFilterFactory_metrics(t *testing.T) {
+	rec := metrics.NopRecorder{}
+	// bloomfilter: both filter and bitmap are instrumented
+	cfg := config.FactoryConfig{
+		FilterConfig: config.FilterConfig{
+			BitmapConfig: config.BitmapConfig{
+				Type: config.BitmapTypeInMemory,
+			},
+			M: 100,
+			K: 3,
+		},
+		MetricsConfig: config.MetricsConfig{
+			Enable:   true,
+			Recorder: rec,
+		},
+	}
+	ff, err := NewFilterFactory(cfg)
+	assert.NoError(t, err)
+	f, err := ff.NewFilter(context.Background())
+	assert.NoError(t, err)
+	assert.IsType(t, &metrics.Filter{}, f)
+	bf := f.(*metrics.Filter).Unwrap()
+	assert.IsType(t, &filter.BloomFilter{}, bf)
+	assert.IsType(t, &metrics.Bitmap{}, bf.(*filter.BloomFilter).BitMap)
+
+	// rotator: rotator is instrumented instead of filters of each generation
+	cfg.RotatorConfig = config.RotatorConfig{
+		Enable: true,
+		Freq:   3 * time.Second,
+	}
+	ff, err = NewFilterFactory(cfg)
+	assert.NoError(t, err)
+	f, err = ff.NewFilter(context.Background())
+	assert.NoError(t, err)
+	assert.IsType(t, &metrics.Filter{}, f)
+	assert.IsType(t, &rotator.Rotator{}, f.(*metrics.Filter).Unwrap())
+
+	// invalid: recorder is absent
+	cfg.MetricsConfig.Recorder = nil
+	ff, err = NewFilterFactory(cfg)
+	assert.Error(t, err)
+	assert.Nil(t, ff)
+}
+
+// recordLogger keeps messages of events for assertions.
+type recordLogger struct {
+	mu   sync.Mutex
+	msgs []string
+}
+
+func (l *recordLogger) record(msg string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.msgs = append(l.msgs, msg)
+}
+
+func (l *recordLogger) Debug(msg string, _ ...any) { l.record(msg) }
+
+func (l *recordLogger) Info(msg string, _ ...any) { l.record(msg) }
+
+func (l *recordLogger) Warn(msg string, _ ...any) { l.record(msg) }
+
+func (l *recordLogger) Error(msg string, _ ...any) { l.record(msg) }
+
+func TestNewFilterFactory_logger(t *testing.T) {
+	mr := miniredis.RunT(t)
+	defer mr.Close()
+
+	logger := &recordLogger{}
+	ff, err := NewFilterFactory(config.FactoryConfig{
+		FilterConfig: config.FilterConfig{
+			BitmapConfig: config.BitmapConfig{
+				Type: config.BitmapTypeRedis,
+			},
+			M: 100,
+			K: 3,
+		},
+		RedisConfig: config.RedisConfig{
+			Addr:    mr.Addr(),
+			Timeout: time.Second,
+			Key:     "test-NewFilterFactory_logger",
+		},
+		RotatorConfig: config.RotatorConfig{
+			Enable: true,
+			Mode:   config.RotatorModeManual,
+			Freq:   time.Minute,
+		},
+		Logger: logger,
+	})
+	assert.NoError(t, err)
+	f, err := ff.NewFilter(context.Background())
+	assert.NoError(t, err)
+	err = f.(*rotator.Rotator).Rotate(context.Background())
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{
+		// current
+		"redis bitmap key",
+		"redis bitmap ttl applied",
+		"filter created",
+		// next
+		"redis bitmap key",
+		"redis bitmap ttl applied",
+		"filter created",
+		"rotator created",
+		// rotation
+		"redis bitmap key",
+		"redis bitmap ttl applied",
+		"filter created",
+		"filter rotated",
+	}, logger.msgs)
+}
+
+func TestBloomFilterFactory_NewFilter_tiered(t *testing.T) {
+	mr := miniredis.RunT(t)
+	defer mr.Close()
+
+	ff := &BloomFilterFactory{
+		cfg: config.FactoryConfig{
+			FilterConfig: config.FilterConfig{
+				BitmapConfig: config.BitmapConfig{
+					Type: config.BitmapTypeRedis,
+				},
+				M: 100,
+				K: 3,
+			},
+			RedisConfig: config.RedisConfig{
+				Addr:    mr.Addr(),
+				Timeout: time.Second,
+				Key:     "test-BloomFilterFactory_NewFilter_tiered",
+			},
+			TieredConfig: config.TieredConfig{
+				Enable:       true,
+				SyncInterval: time.Minute,
+			},
+		},
+	}
+	f, err := ff.NewFilter(context.Background())
+	assert.NoError(t, err)
+	assert.IsType(t, &tiered.Tiered{}, f)
+	defer f.(*tiered.Tiered).Close()
+
+	err = f.Add("hello")
+	assert.NoError(t, err)
+	exist, err := f.Exist("hello")
+	assert.NoError(t, err)
+	assert.Equal(t, true, exist)
+}
+
+func TestBloomFilterFactory_NewFilter_resilience(t *testing.T) {
+	mr := miniredis.RunT(t)
+	defer mr.Close()
+
+	ff := &BloomFilterFactory{
+		cfg: config.FactoryConfig{
+			FilterConfig: config.FilterConfig{
+				BitmapConfig: config.BitmapConfig{
+					Type: config.BitmapTypeRedis,
+				},
+				M: 100,
+				K: 3,
+			},
+			RedisConfig: config.RedisConfig{
+				Addr:    mr.Addr(),
+				Timeout: time.Second,
+				Key:     "test-BloomFilterFactory_NewFilter_resilience",
+				Resilience: config.ResilienceConfig{
+					Enable:           true,
+					FailureThreshold: 1,
+					Cooldown:         time.Minute,
+					FailPolicy:       config.FailPolicyFallback,
+				},
+			},
+		},
+	}
+	f, err := ff.NewFilter(context.Background())
+	assert.NoError(t, err)
+	assert.IsType(t, &bitmap.Resilient{}, f.(*filter.BloomFilter).BitMap)
+
+	err = f.Add("hello")
+	assert.NoError(t, err)
+
+	// redis is down, the local fallback answers
+	mr.Close()
+	exist, err := f.Exist("hello")
+	assert.NoError(t, err)
+	assert.Equal(t, true, exist)
+	exist, err = f.Exist("world")
+	assert.NoError(t, err)
+	assert.Equal(t, false, exist)
+}
+
+func TestBloomFilterFactory_NewFilter_sharedBitmapFactory(t *testing.T) {
+	mr := miniredis.RunT(t)
+	defer mr.Close()
+
+	ff := &BloomFilterFactory{
+		cfg: config.FactoryConfig{
+			FilterConfig: config.FilterConfig{
+				BitmapConfig: config.BitmapConfig{
+					Type: config.BitmapTypeRedis,
+				},
+				M: 100,
+				K: 3,
+			},
+			RedisConfig: config.RedisConfig{
+				Addr:    mr.Addr(),
+				Timeout: time.Second,
+				Key:     "test-BloomFilterFactory_NewFilter_sharedBitmapFactory",
+			},
+		},
+	}
+	_, err := ff.NewFilter(context.Background())
+	assert.NoError(t, err)
+	bmf := ff.bmf
+	client := bmf.(*RedisBitmapFactory).client
+	_, err = ff.NewFilter(context.Background())
+	assert.NoError(t, err)
+	assert.Same(t, bmf, ff.bmf)
+	assert.Same(t, client, bmf.(*RedisBitmapFactory).client)
+}
@@ -0,0 +1,209 @@
+package factory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"github.com/x0rworld/go-bloomfilter/bitmap"
+	"github.com/x0rworld/go-bloomfilter/config"
+	"github.com/x0rworld/go-bloomfilter/core"
+	"github.com/x0rworld/go-bloomfilter/filter"
+	"strconv"
+	"time"
+)
+
+// RedisMetaVersion is the version of metadata format written by RedisBitmapFactory.
+const RedisMetaVersion = 1
+
+// ErrMetaMismatch is matched by MetaMismatchError with errors.Is.
+var ErrMetaMismatch = errors.New("redis bitmap metadata mismatch")
+
+// ErrMetaNotFound is returned by ReadRedisMeta if the bitmap has no metadata, e.g. it's written by older version.
+var ErrMetaNotFound = errors.New("redis bitmap metadata not found")
+
+// MetaMismatchError is returned if the existing redis bitmap was written with a different Field than config.
+type MetaMismatchError struct {
+	Key   string
+	Field string
+	Want  any
+	Got   any
+}
+
+func (e *MetaMismatchError) Error() string {
+	return fmt.Sprintf("redis bitmap %s was written with %s %v, but %v is configured", e.Key, e.Field, e.Got, e.Want)
+}
+
+func (e *MetaMismatchError) Is(target error) bool {
+	return target == ErrMetaMismatch
+}
+
+// RedisMeta is metadata of redis bitmap, it's stored as a hash in RedisMetaKey with the same TTL as the bitmap.
+type RedisMeta struct {
+	M            uint64
+	K            uint64
+	HashStrategy string
+	Version      int
+	CreatedAt    time.Time
+}
+
+// RedisMetaKey returns key of metadata of the bitmap in key.
+func RedisMetaKey(key string) string {
+	return key + "_meta"
+}
+
+// ReadRedisMeta returns metadata of the redis bitmap in key, it returns ErrMetaNotFound if it's absent.
+func ReadRedisMeta(ctx context.Context, client *redis.Client, key string) (RedisMeta, error) {
+	res, err := client.HGetAll(ctx, RedisMetaKey(key)).Result()
+	if err != nil {
+		return RedisMeta{}, err
+	}
+	if len(res) == 0 {
+		return RedisMeta{}, fmt.Errorf("%w: %s", ErrMetaNotFound, key)
+	}
+	kvs := make([]string, 0, len(res)*2)
+	for k, v := range res {
+		kvs = append(kvs, k, v)
+	}
+	meta, err := parseRedisMeta(kvs)
+	if err != nil {
+		return RedisMeta{}, fmt.Errorf("invalid metadata of redis bitmap %s: %w", key, err)
+	}
+	return meta, nil
+}
+
+// ReadRedisFilterMeta returns metadata of the current bitmap of the filter configured by cfg, i.e. the key itself,
+// or the current generation recorded in registry (see RedisRegistryKey) or named by the time if rotator is enabled.
+// It returns ErrMetaNotFound if the filter doesn't exist in redis.
+func ReadRedisFilterMeta(ctx context.Context, client *redis.Client, cfg config.FactoryConfig) (RedisMeta, error) {
+	key := cfg.RedisConfig.Key
+	if cfg.RotatorConfig.Enable {
+		if cfg.RotatorConfig.Mode == config.RotatorModeTruncatedTime {
+			rf := &RedisBitmapFactory{cfg: cfg}
+			var err error
+			key, _, err = rf.rotatingKey(core.BitmapFactoryCtxValue{
+				IsRotatorEnabled: true,
+				RotatorMode:      cfg.RotatorConfig.Mode,
+				Now:              time.Now(),
+			})
+			if err != nil {
+				return RedisMeta{}, err
+			}
+		} else {
+			current, err := client.HGet(ctx, RedisRegistryKey(key), "current").Result()
+			if err == redis.Nil {
+				return RedisMeta{}, fmt.Errorf("%w: %s", ErrMetaNotFound, RedisRegistryKey(key))
+			}
+			if err != nil {
+				return RedisMeta{}, err
+			}
+			key = current
+		}
+	}
+	return ReadRedisMeta(ctx, client, key)
+}
+
+// writeMetaScript writes metadata into KEYS[1] unless it exists, the TTL follows the bitmap in KEYS[2].
+// It returns the existing metadata, or an empty array if it's written.
+var writeMetaScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return redis.call('HGETALL', KEYS[1])
+end
+redis.call('HSET', KEYS[1], unpack(ARGV))
+local ttl = redis.call('PTTL', KEYS[2])
+if ttl > 0 then
+	redis.call('PEXPIRE', KEYS[1], ttl)
+end
+return {}
+`)
+
+// ensureMeta writes metadata of bm if it's absent, e.g. bm is newly created or written by older version,
+// otherwise validates it against config and handles mismatch by config.MetaPolicy.
+func (rf *RedisBitmapFactory) ensureMeta(ctx context.Context, client *redis.Client, bm *bitmap.Redis) error {
+	meta := RedisMeta{
+		M:            rf.cfg.FilterConfig.M,
+		K:            rf.cfg.FilterConfig.K,
+		HashStrategy: filter.HashStrategyBloom,
+		Version:      RedisMetaVersion,
+		CreatedAt:    time.Now(),
+	}
+	keys := []string{RedisMetaKey(bm.Key()), bm.Key()}
+	res, err := writeMetaScript.Run(ctx, client, keys, meta.args()...).StringSlice()
+	if err != nil {
+		return err
+	}
+	if len(res) == 0 {
+		return nil
+	}
+	existing, err := parseRedisMeta(res)
+	if err != nil {
+		return fmt.Errorf("invalid metadata of redis bitmap %s: %w", bm.Key(), err)
+	}
+	mismatch := existing.mismatch(bm.Key(), meta)
+	if mismatch == nil {
+		return nil
+	}
+	if rf.cfg.RedisConfig.MetaPolicy != config.MetaPolicyReset {
+		return mismatch
+	}
+	loggerOf(rf.cfg).Warn("redis bitmap reset by metadata mismatch", "key", bm.Key(), "error", mismatch)
+	if err := bm.Reset(); err != nil {
+		return err
+	}
+	if err := client.Del(ctx, keys[0]).Err(); err != nil {
+		return err
+	}
+	return writeMetaScript.Run(ctx, client, keys, meta.args()...).Err()
+}
+
+func (m RedisMeta) args() []any {
+	return []any{
+		"m", m.M,
+		"k", m.K,
+		"hash", m.HashStrategy,
+		"version", m.Version,
+		"created_at", m.CreatedAt.UnixNano(),
+	}
+}
+
+// mismatch returns MetaMismatchError if m differs from want, CreatedAt is not compared.
+func (m RedisMeta) mismatch(key string, want RedisMeta) error {
+	switch {
+	case m.Version > want.Version:
+		return &MetaMismatchError{Key: key, Field: "version", Want: want.Version, Got: m.Version}
+	case m.M != want.M:
+		return &MetaMismatchError{Key: key, Field: "m", Want: want.M, Got: m.M}
+	case m.K != want.K:
+		return &MetaMismatchError{Key: key, Field: "k", Want: want.K, Got: m.K}
+	case m.HashStrategy != want.HashStrategy:
+		return &MetaMismatchError{Key: key, Field: "hash strategy", Want: want.HashStrategy, Got: m.HashStrategy}
+	}
+	return nil
+}
+
+// parseRedisMeta parses the reply of HGETALL.
+func parseRedisMeta(kvs []string) (RedisMeta, error) {
+	var meta RedisMeta
+	for i := 0; i+1 < len(kvs); i += 2 {
+		v := kvs[i+1]
+		var err error
+		switch kvs[i] {
+		case "m":
+			meta.M, err = strconv.ParseUint(v, 10, 64)
+		case "k":
+			meta.K, err = strconv.ParseUint(v, 10, 64)
+		case "hash":
+			meta.HashStrategy = v
+		case "version":
+			meta.Version, err = strconv.Atoi(v)
+		case "created_at":
+			var ns int64
+			ns, err = strconv.ParseInt(v, 10, 64)
+			meta.CreatedAt = time.Unix(0, ns)
+		}
+		if err != nil {
+			return RedisMeta{}, fmt.Errorf("field %s: %w", kvs[i], err)
+		}
+	}
+	return meta, nil
+}
@@ -0,0 +1,183 @@
+package factory
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/x0rworld/go-bloomfilter/bitmap"
+	"github.com/x0rworld/go-bloomfilter/config"
+	"github.com/x0rworld/go-bloomfilter/core"
+	"github.com/x0rworld/go-bloomfilter/filter"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func genMetaConfig(mr *miniredis.Miniredis, key string, k uint64, policy config.MetaPolicy) config.FactoryConfig {
+	return config.FactoryConfig{
+		FilterConfig: config.FilterConfig{
+			BitmapConfig: config.BitmapConfig{
+				Type: config.BitmapTypeRedis,
+			},
+			M: 100,
+			K: k,
+		},
+		RedisConfig: config.RedisConfig{
+			Addr:       mr.Addr(),
+			Timeout:    time.Second,
+			Key:        key,
+			MetaPolicy: policy,
+		},
+	}
+}
+
+func TestRedisBitmapFactory_NewBitmap_meta(t *testing.T) {
+	mr := miniredis.RunT(t)
+	defer mr.Close()
+
+	key := "test-RedisBitmapFactory_NewBitmap_meta"
+	rf := &RedisBitmapFactory{cfg: genMetaConfig(mr, key, 3, "")}
+	bm, err := rf.NewBitmap(context.Background())
+	assert.NoError(t, err)
+	err = bm.SetBits([]uint64{1})
+	assert.NoError(t, err)
+
+	metaKey := RedisMetaKey(key)
+	assert.Equal(t, "100", mr.HGet(metaKey, "m"))
+	assert.Equal(t, "3", mr.HGet(metaKey, "k"))
+	assert.Equal(t, filter.HashStrategyBloom, mr.HGet(metaKey, "hash"))
+	assert.Equal(t, strconv.Itoa(RedisMetaVersion), mr.HGet(metaKey, "version"))
+	assert.NotEmpty(t, mr.HGet(metaKey, "created_at"))
+
+	// the same config reopens the bitmap
+	_, err = rf.NewBitmap(context.Background())
+	assert.NoError(t, err)
+
+	// k is changed
+	rf = &RedisBitmapFactory{cfg: genMetaConfig(mr, key, 4, config.MetaPolicyReject)}
+	_, err = rf.NewBitmap(context.Background())
+	assert.ErrorIs(t, err, ErrMetaMismatch)
+	var mme *MetaMismatchError
+	assert.ErrorAs(t, err, &mme)
+	assert.Equal(t, "k", mme.Field)
+	assert.Equal(t, uint64(3), mme.Got)
+
+	// migrate by reset, pre-allocation is kept
+	cfg := genMetaConfig(mr, key, 4, config.MetaPolicyReset)
+	cfg.RedisConfig.Preallocate = true
+	rf = &RedisBitmapFactory{cfg: cfg}
+	bm, err = rf.NewBitmap(context.Background())
+	assert.NoError(t, err)
+	n, err := bm.(*bitmap.Redis).CountBits()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), n)
+	n, err = bm.(*bitmap.Redis).AllocatedBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(13), n)
+	assert.Equal(t, "4", mr.HGet(metaKey, "k"))
+}
+
+func TestRedisBitmapFactory_NewBitmap_metaTTL(t *testing.T) {
+	mr := miniredis.RunT(t)
+	defer mr.Close()
+
+	cfg := genMetaConfig(mr, "test-RedisBitmapFactory_NewBitmap_metaTTL", 3, "")
+	cfg.RotatorConfig = config.RotatorConfig{
+		Enable: true,
+		Mode:   config.RotatorModeTruncatedTime,
+		Freq:   time.Hour,
+	}
+	rf := &RedisBitmapFactory{cfg: cfg}
+	ctx := context.WithValue(context.Background(), core.BitmapFactoryCtxKey, core.BitmapFactoryCtxValue{
+		IsRotatorEnabled: true,
+		RotatorMode:      config.RotatorModeTruncatedTime,
+		Now:              time.Date(2022, 9, 6, 8, 0, 0, 0, time.UTC),
+	})
+	bm, err := rf.NewBitmap(ctx)
+	assert.NoError(t, err)
+
+	// metadata expires with the bitmap
+	key := bm.(*bitmap.Redis).Key()
+	assert.Equal(t, mr.TTL(key), mr.TTL(RedisMetaKey(key)))
+}
+
+func TestRedisBitmapFactory_NewBitmap_metaAbsent(t *testing.T) {
+	mr := miniredis.RunT(t)
+	defer mr.Close()
+
+	// bitmap written without metadata is adopted
+	key := "test-RedisBitmapFactory_NewBitmap_metaAbsent"
+	// 0b01000000: bit 1 is set
+	err := mr.Set(key, "\x40")
+	assert.NoError(t, err)
+	rf := &RedisBitmapFactory{cfg: genMetaConfig(mr, key, 3, "")}
+	bm, err := rf.NewBitmap(context.Background())
+	assert.NoError(t, err)
+	n, err := bm.(*bitmap.Redis).CountBits()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), n)
+	assert.Equal(t, "3", mr.HGet(RedisMetaKey(key), "k"))
+}
+
+func TestReadRedisMeta(t *testing.T) {
+	mr := miniredis.RunT(t)
+	defer mr.Close()
+
+	key := "test-ReadRedisMeta"
+	rf := &RedisBitmapFactory{cfg: genMetaConfig(mr, key, 3, "")}
+	_, err := rf.NewBitmap(context.Background())
+	assert.NoError(t, err)
+
+	client := rf.redisClient()
+	meta, err := ReadRedisMeta(context.Background(), client, key)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(100), meta.M)
+	assert.Equal(t, uint64(3), meta.K)
+	assert.Equal(t, filter.HashStrategyBloom, meta.HashStrategy)
+	assert.Equal(t, RedisMetaVersion, meta.Version)
+	assert.False(t, meta.CreatedAt.IsZero())
+
+	_, err = ReadRedisMeta(context.Background(), client, "unknown")
+	assert.ErrorIs(t, err, ErrMetaNotFound)
+
+	mr.HSet(RedisMetaKey(key), "m", "x")
+	_, err = ReadRedisMeta(context.Background(), client, key)
+	assert.Error(t, err)
+}
+
+func TestReadRedisFilterMeta(t *testing.T) {
+	mr := miniredis.RunT(t)
+	defer mr.Close()
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	tests := []struct {
+		name    string
+		rotator config.RotatorConfig
+	}{
+		{name: "static"},
+		{name: "default", rotator: config.RotatorConfig{Enable: true, Mode: config.RotatorModeDefault, Freq: time.Hour}},
+		{name: "truncated", rotator: config.RotatorConfig{Enable: true, Mode: config.RotatorModeTruncatedTime, Freq: time.Hour}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := genMetaConfig(mr, "test-ReadRedisFilterMeta-"+tt.name, 3, "")
+			cfg.RotatorConfig = tt.rotator
+			_, err := ReadRedisFilterMeta(context.Background(), client, cfg)
+			assert.ErrorIs(t, err, ErrMetaNotFound)
+
+			ff, err := NewFilterFactory(cfg)
+			assert.NoError(t, err)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			_, err = ff.NewFilter(ctx)
+			assert.NoError(t, err)
+
+			meta, err := ReadRedisFilterMeta(context.Background(), client, cfg)
+			assert.NoError(t, err)
+			assert.Equal(t, uint64(100), meta.M)
+			assert.Equal(t, uint64(3), meta.K)
+		})
+	}
+}
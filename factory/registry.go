@@ -0,0 +1,48 @@
+package factory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/x0rworld/go-bloomfilter/bitmap"
+	"github.com/x0rworld/go-bloomfilter/config"
+	"github.com/x0rworld/go-bloomfilter/internal/registry"
+)
+
+// BitmapConstructor returns bitmap of the registered type by cfg.
+// If rotator is enabled, ctx carries core.BitmapFactoryCtxValue which describes the generation of filter.
+type BitmapConstructor func(ctx context.Context, cfg config.FactoryConfig) (bitmap.Bitmap, error)
+
+// RegisterBitmap registers constructor of custom bitmap backend as typ, so that typ is valid as
+// config.BitmapConfig.Type and NewBitmapFactory returns BitmapFactory calling constructor.
+// It returns error if typ is built-in or already registered, or constructor is nil.
+func RegisterBitmap(typ config.BitmapType, constructor BitmapConstructor) error {
+	if constructor == nil {
+		return errors.New("nil bitmap constructor")
+	}
+	if typ == "" {
+		return fmt.Errorf("%w: empty", config.ErrInvalidBitmapType)
+	}
+	if typ == config.BitmapTypeInMemory || typ == config.BitmapTypeRedis || !registry.RegisterBitmap(string(typ), constructor) {
+		return fmt.Errorf("bitmap type %q is already registered", typ)
+	}
+	return nil
+}
+
+func registeredBitmap(typ config.BitmapType) (BitmapConstructor, bool) {
+	constructor, ok := registry.Bitmap(string(typ))
+	if !ok {
+		return nil, false
+	}
+	return constructor.(BitmapConstructor), true
+}
+
+// RegisteredBitmapFactory generates bitmap by BitmapConstructor registered by RegisterBitmap.
+type RegisteredBitmapFactory struct {
+	cfg         config.FactoryConfig
+	constructor BitmapConstructor
+}
+
+func (rbf *RegisteredBitmapFactory) NewBitmap(ctx context.Context) (bitmap.Bitmap, error) {
+	return rbf.constructor(ctx, rbf.cfg)
+}
@@ -0,0 +1,60 @@
+package factory
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/x0rworld/go-bloomfilter/bitmap"
+	"github.com/x0rworld/go-bloomfilter/config"
+	"github.com/x0rworld/go-bloomfilter/filter"
+	"testing"
+)
+
+// customBitmap is bitmap.Bitmap registered from outside of the package.
+type customBitmap struct {
+	*bitmap.InMemory
+}
+
+func TestRegisterBitmap(t *testing.T) {
+	typ := config.BitmapType("test-RegisterBitmap")
+	cfg := config.FactoryConfig{
+		FilterConfig: config.FilterConfig{
+			BitmapConfig: config.BitmapConfig{
+				Type: typ,
+			},
+			M: 100,
+			K: 3,
+		},
+	}
+
+	// unknown type
+	_, err := NewBitmapFactory(cfg)
+	assert.ErrorIs(t, err, config.ErrInvalidBitmapType)
+	assert.ErrorIs(t, typ.Validate(), config.ErrInvalidBitmapType)
+
+	err = RegisterBitmap(typ, func(_ context.Context, cfg config.FactoryConfig) (bitmap.Bitmap, error) {
+		return &customBitmap{InMemory: bitmap.NewInMemory(cfg.FilterConfig.M)}, nil
+	})
+	assert.NoError(t, err)
+	// config refers to the same registry
+	assert.NoError(t, typ.Validate())
+	bmf, err := NewBitmapFactory(cfg)
+	assert.NoError(t, err)
+	assert.IsType(t, &RegisteredBitmapFactory{}, bmf)
+	bm, err := bmf.NewBitmap(context.Background())
+	assert.NoError(t, err)
+	assert.IsType(t, &customBitmap{}, bm)
+
+	// filter is built on the registered bitmap
+	ff, err := NewFilterFactory(cfg)
+	assert.NoError(t, err)
+	f, err := ff.NewFilter(context.Background())
+	assert.NoError(t, err)
+	assert.IsType(t, &customBitmap{}, f.(*filter.BloomFilter).BitMap)
+
+	// duplicated, built-in or invalid registration
+	constructor := func(_ context.Context, _ config.FactoryConfig) (bitmap.Bitmap, error) { return nil, nil }
+	assert.Error(t, RegisterBitmap(typ, constructor))
+	assert.Error(t, RegisterBitmap(config.BitmapTypeRedis, constructor))
+	assert.Error(t, RegisterBitmap("", constructor))
+	assert.Error(t, RegisterBitmap("test-RegisterBitmap-nil", nil))
+}
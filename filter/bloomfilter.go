@@ -1,10 +1,26 @@
 package filter
 
 import (
-	"github.com/bits-and-blooms/bloom/v3"
+	"context"
 	"github.com/x0rworld/go-bloomfilter/bitmap"
+	"github.com/x0rworld/go-bloomfilter/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// HashStrategyBloom locates bits by Locations of github.com/bits-and-blooms/bloom/v3.
+const HashStrategyBloom = "bloom-v3"
+
+var (
+	// ErrUnsupported is returned if the operation is not supported by the underlying bitmap.
+	ErrUnsupported = bitmap.ErrUnsupported
+	// ErrIncompatible is matched by IncompatibleError with errors.Is.
+	ErrIncompatible = bitmap.ErrIncompatible
+)
+
+// IncompatibleError is returned if filters are combined but differ in m, k, hash strategy or type of bitmap.
+type IncompatibleError = bitmap.IncompatibleError
+
 // locationFunc returns hash locations based on data and k.
 type locationFunc func(data []byte, k uint) []uint64
 
@@ -13,33 +29,230 @@ type BloomFilter struct {
 	// m is the number of bit in bloom filter.
 	m uint64
 	// k is the number of hash function.
-	k        uint64
+	k uint64
+	// location overrides the inline hashing equivalent to bloom.Locations if present.
 	location locationFunc
+	// hash names the strategy of location, filters are only combinable with the same one.
+	hash string
 }
 
+// Exist checks data by CheckBits of bitmap, so that bitmap keeps its own context, see bitmap.ContextBitmap.
 func (b *BloomFilter) Exist(data string) (bool, error) {
-	locs := b.location([]byte(data), uint(b.k))
-	exist, err := b.BitMap.CheckBits(locs)
+	return b.exist(context.Background(), data, false)
+}
+
+// ExistContext checks data with ctx, which is passed to bitmap if it's bitmap.ContextBitmap.
+//
+// If bitmap is bitmap.InMemory, data is checked without allocation and tracing since it performs no I/O.
+func (b *BloomFilter) ExistContext(ctx context.Context, data string) (bool, error) {
+	return b.exist(ctx, data, true)
+}
+
+// exist checks data with ctx if perCall is true, otherwise by CheckBits of bitmap, ctx is only used for tracing.
+func (b *BloomFilter) exist(ctx context.Context, data string, perCall bool) (exist bool, err error) {
+	if im, ok := b.BitMap.(*bitmap.InMemory); ok && b.location == nil {
+		return b.existInMemory(im, data), nil
+	}
+	ctx, span := b.startSpan(ctx, "BloomFilter.Exist")
+	defer func() { tracing.End(span, err) }()
+
+	locs := b.locations(data)
+	if perCall {
+		exist, err = bitmap.CheckBitsContext(ctx, b.BitMap, locs)
+	} else {
+		exist, err = b.BitMap.CheckBits(locs)
+	}
 	if err != nil {
 		return false, err
 	}
 	return exist, nil
 }
 
+// Add adds data by SetBits of bitmap, so that bitmap keeps its own context, see bitmap.ContextBitmap.
 func (b *BloomFilter) Add(data string) error {
-	locs := b.location([]byte(data), uint(b.k))
-	err := b.BitMap.SetBits(locs)
+	return b.add(context.Background(), data, false)
+}
+
+// AddContext adds data with ctx, which is passed to bitmap if it's bitmap.ContextBitmap.
+//
+// If bitmap is bitmap.InMemory, data is added without allocation and tracing since it performs no I/O.
+func (b *BloomFilter) AddContext(ctx context.Context, data string) error {
+	return b.add(ctx, data, true)
+}
+
+// add adds data with ctx if perCall is true, otherwise by SetBits of bitmap, ctx is only used for tracing.
+func (b *BloomFilter) add(ctx context.Context, data string, perCall bool) (err error) {
+	if im, ok := b.BitMap.(*bitmap.InMemory); ok && b.location == nil {
+		b.addInMemory(im, data)
+		return nil
+	}
+	ctx, span := b.startSpan(ctx, "BloomFilter.Add")
+	defer func() { tracing.End(span, err) }()
+
+	locs := b.locations(data)
+	if perCall {
+		err = bitmap.SetBitsContext(ctx, b.BitMap, locs)
+	} else {
+		err = b.BitMap.SetBits(locs)
+	}
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// existInMemory hashes data inline and returns false on the first unset bit.
+func (b *BloomFilter) existInMemory(im *bitmap.InMemory, data string) bool {
+	h := baseHashes(data)
+	for i := uint64(0); i < b.k; i++ {
+		if !im.CheckBit(location(h, i)) {
+			return false
+		}
+	}
+	return true
+}
+
+func (b *BloomFilter) addInMemory(im *bitmap.InMemory, data string) {
+	h := baseHashes(data)
+	for i := uint64(0); i < b.k; i++ {
+		im.SetBit(location(h, i))
+	}
+}
+
+// locations returns k locations of data.
+func (b *BloomFilter) locations(data string) []uint64 {
+	if b.location != nil {
+		return b.location([]byte(data), uint(b.k))
+	}
+	h := baseHashes(data)
+	locs := make([]uint64, b.k)
+	for i := range locs {
+		locs[i] = location(h, uint64(i))
+	}
+	return locs
+}
+
+func (b *BloomFilter) startSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(ctx, name, trace.WithAttributes(
+		attribute.Int64(tracing.AttrFilterM, int64(b.m)),
+		attribute.Int64(tracing.AttrFilterK, int64(b.k)),
+	))
+}
+
+// FillRatio returns the ratio of set bits to m, it returns ErrUnsupported if bitmap is not bitmap.Counter.
+func (b *BloomFilter) FillRatio() (float64, error) {
+	c, ok := b.BitMap.(bitmap.Counter)
+	if !ok {
+		return 0, ErrUnsupported
+	}
+	n, err := c.CountBits()
+	if err != nil {
+		return 0, err
+	}
+	return float64(n) / float64(b.m), nil
+}
+
+// Locations returns locations of data in bitmap, which are shared by filters of the same k and hash strategy
+// regardless of m, see SameLocations.
+func (b *BloomFilter) Locations(data string) []uint64 {
+	return b.locations(data)
+}
+
+// SameLocations returns true if locations computed by b are valid for o.
+func (b *BloomFilter) SameLocations(o *BloomFilter) bool {
+	return b.k == o.k && b.hash == o.hash
+}
+
+// ExistLocations checks locs returned by Locations with ctx.
+func (b *BloomFilter) ExistLocations(ctx context.Context, locs []uint64) (bool, error) {
+	return bitmap.CheckBitsContext(ctx, b.BitMap, locs)
+}
+
+// AddLocations adds locs returned by Locations with ctx.
+func (b *BloomFilter) AddLocations(ctx context.Context, locs []uint64) error {
+	return bitmap.SetBitsContext(ctx, b.BitMap, locs)
+}
+
+// AddLocationsAll adds locs to all of filters, which must share the same locations (see SameLocations).
+// Bits are set by bitmap.SetBitsAll, so that bitmap.Redis on the same server are set by a single pipeline.
+func AddLocationsAll(ctx context.Context, locs []uint64, filters ...*BloomFilter) error {
+	bms := make([]bitmap.Bitmap, 0, len(filters))
+	for _, f := range filters {
+		bms = append(bms, f.BitMap)
+	}
+	return bitmap.SetBitsAll(ctx, locs, bms...)
+}
+
+// Reset clears all bits of bitmap, it returns ErrUnsupported if bitmap is not bitmap.Resetter.
+func (b *BloomFilter) Reset() error {
+	rs, ok := b.BitMap.(bitmap.Resetter)
+	if !ok {
+		return ErrUnsupported
+	}
+	return rs.Reset()
+}
+
+// Snapshot returns bits of bitmap, it returns ErrUnsupported if bitmap is not bitmap.Exporter.
+func (b *BloomFilter) Snapshot() ([]byte, error) {
+	e, ok := b.BitMap.(bitmap.Exporter)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	return e.Bytes()
+}
+
+// Merge unions others into b in place.
+func (b *BloomFilter) Merge(others ...*BloomFilter) error {
+	return b.Union(append([]*BloomFilter{b}, others...)...)
+}
+
+// Union overwrites b with the union of srcs, b itself could be one of srcs.
+// Filters must have the same m, k and hash strategy, and their bitmaps must be combinable by bitmap.Combiner of b,
+// e.g. bitmap.InMemory with bitmap.InMemory, otherwise IncompatibleError is returned.
+// It returns ErrUnsupported if bitmap of b is not bitmap.Combiner, such as decorated by metrics.
+func (b *BloomFilter) Union(srcs ...*BloomFilter) error {
+	c, bms, err := b.combinable(srcs)
+	if err != nil {
+		return err
+	}
+	return c.Union(bms...)
+}
+
+// Intersect overwrites b with the intersection of srcs, see Union for the requirements of srcs.
+// Note that the intersection may have more false positives than a filter built from the intersected data.
+func (b *BloomFilter) Intersect(srcs ...*BloomFilter) error {
+	c, bms, err := b.combinable(srcs)
+	if err != nil {
+		return err
+	}
+	return c.Intersect(bms...)
+}
+
+func (b *BloomFilter) combinable(srcs []*BloomFilter) (bitmap.Combiner, []bitmap.Bitmap, error) {
+	c, ok := b.BitMap.(bitmap.Combiner)
+	if !ok {
+		return nil, nil, ErrUnsupported
+	}
+	bms := make([]bitmap.Bitmap, 0, len(srcs))
+	for _, src := range srcs {
+		switch {
+		case src.m != b.m:
+			return nil, nil, &IncompatibleError{Field: "m", Want: b.m, Got: src.m}
+		case src.k != b.k:
+			return nil, nil, &IncompatibleError{Field: "k", Want: b.k, Got: src.k}
+		case src.hash != b.hash:
+			return nil, nil, &IncompatibleError{Field: "hash strategy", Want: b.hash, Got: src.hash}
+		}
+		bms = append(bms, src.BitMap)
+	}
+	return c, bms, nil
+}
+
 func NewBloomFilter(bitmap bitmap.Bitmap, m, k uint64) *BloomFilter {
 	return &BloomFilter{
-		BitMap:   bitmap,
-		m:        m,
-		k:        k,
-		location: bloom.Locations,
+		BitMap: bitmap,
+		m:      m,
+		k:      k,
+		hash:   HashStrategyBloom,
 	}
 }
@@ -1,10 +1,14 @@
 package filter
 
 import (
+	"context"
 	"errors"
+	"github.com/bits-and-blooms/bloom/v3"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	"github.com/x0rworld/go-bloomfilter/bitmap"
 	"github.com/x0rworld/go-bloomfilter/mock"
+	"math/rand"
 	"testing"
 )
 
@@ -75,3 +79,223 @@ func TestBloomFilter_Add(t *testing.T) {
 	err = bf.Add(dataHello)
 	assert.NoError(t, err)
 }
+
+func TestBloomFilter_ExistContext(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// ctx is passed to bitmap.ContextBitmap
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "value")
+	bMap := &contextBitmap{MockBitmap: mock.NewMockBitmap(ctrl), MockContextBitmap: mock.NewMockContextBitmap(ctrl)}
+	bf := NewBloomFilter(bMap, 100, 3)
+	bf.location = stubLocation
+
+	withValue := gomock.AssignableToTypeOf(ctx)
+	bMap.MockContextBitmap.EXPECT().SetBitsContext(withValue, locationHello).DoAndReturn(func(ctx context.Context, _ []uint64) error {
+		assert.Equal(t, "value", ctx.Value(ctxKey{}))
+		return nil
+	})
+	err := bf.AddContext(ctx, dataHello)
+	assert.NoError(t, err)
+	bMap.MockContextBitmap.EXPECT().CheckBitsContext(withValue, locationHello).Return(true, nil)
+	exist, err := ExistContext(ctx, bf, dataHello)
+	assert.NoError(t, err)
+	assert.Equal(t, true, exist)
+
+	// bitmap keeps its own context without the per-call one
+	bMap.MockBitmap.EXPECT().SetBits(locationHello).Return(nil)
+	assert.NoError(t, bf.Add(dataHello))
+	bMap.MockBitmap.EXPECT().CheckBits(locationHello).Return(true, nil)
+	exist, err = bf.Exist(dataHello)
+	assert.NoError(t, err)
+	assert.Equal(t, true, exist)
+}
+
+// contextBitmap is bitmap.Bitmap as well as bitmap.ContextBitmap.
+type contextBitmap struct {
+	*mock.MockBitmap
+	*mock.MockContextBitmap
+}
+
+func TestBloomFilter_FillRatio(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// bitmap is bitmap.Counter
+	bf := NewBloomFilter(bitmap.NewInMemory(100), 100, 3)
+	bf.location = stubLocation
+	err := bf.Add(dataHello)
+	assert.NoError(t, err)
+	ratio, err := bf.FillRatio()
+	assert.NoError(t, err)
+	assert.Equal(t, 0.01, ratio)
+
+	// bitmap is not bitmap.Counter
+	bf = NewBloomFilter(mock.NewMockBitmap(ctrl), 100, 3)
+	_, err = bf.FillRatio()
+	assert.ErrorIs(t, err, ErrUnsupported)
+}
+
+func TestBloomFilter_Merge(t *testing.T) {
+	a := NewBloomFilter(bitmap.NewInMemory(1000), 1000, 3)
+	b := NewBloomFilter(bitmap.NewInMemory(1000), 1000, 3)
+	_ = a.Add("a")
+	_ = b.Add("b")
+
+	err := a.Merge(b)
+	assert.NoError(t, err)
+	for _, data := range []string{"a", "b"} {
+		exist, err := a.Exist(data)
+		assert.NoError(t, err)
+		assert.True(t, exist)
+	}
+
+	c := NewBloomFilter(bitmap.NewInMemory(1000), 1000, 3)
+	err = c.Intersect(a, b)
+	assert.NoError(t, err)
+	exist, err := c.Exist("b")
+	assert.NoError(t, err)
+	assert.True(t, exist)
+}
+
+func TestBloomFilter_Union_incompatible(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	bf := NewBloomFilter(bitmap.NewInMemory(1000), 1000, 3)
+	hashed := NewBloomFilter(bitmap.NewInMemory(1000), 1000, 3)
+	hashed.hash = "other"
+	tests := []struct {
+		name      string
+		src       *BloomFilter
+		wantField string
+	}{
+		{
+			name:      "m",
+			src:       NewBloomFilter(bitmap.NewInMemory(100), 100, 3),
+			wantField: "m",
+		},
+		{
+			name:      "k",
+			src:       NewBloomFilter(bitmap.NewInMemory(1000), 1000, 2),
+			wantField: "k",
+		},
+		{
+			name:      "hash strategy",
+			src:       hashed,
+			wantField: "hash strategy",
+		},
+		{
+			name:      "bitmap",
+			src:       NewBloomFilter(mock.NewMockBitmap(ctrl), 1000, 3),
+			wantField: "type",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := bf.Union(tt.src)
+			assert.ErrorIs(t, err, ErrIncompatible)
+			var ie *IncompatibleError
+			assert.ErrorAs(t, err, &ie)
+			assert.Equal(t, tt.wantField, ie.Field)
+		})
+	}
+
+	// bitmap is not bitmap.Combiner
+	err := NewBloomFilter(mock.NewMockBitmap(ctrl), 1000, 3).Merge(bf)
+	assert.ErrorIs(t, err, ErrUnsupported)
+}
+
+func TestBloomFilter_Reset(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	bf := NewBloomFilter(bitmap.NewInMemory(100), 100, 3)
+	err := bf.Add(dataHello)
+	assert.NoError(t, err)
+	err = bf.Reset()
+	assert.NoError(t, err)
+	exist, err := bf.Exist(dataHello)
+	assert.NoError(t, err)
+	assert.Equal(t, false, exist)
+
+	// bitmap is not bitmap.Resetter
+	bf = NewBloomFilter(mock.NewMockBitmap(ctrl), 100, 3)
+	err = bf.Reset()
+	assert.ErrorIs(t, err, ErrUnsupported)
+}
+
+func TestAddLocationsAll(t *testing.T) {
+	hashed := 0
+	countLocation := func(data []byte, k uint) []uint64 {
+		hashed++
+		return stubLocation(data, k)
+	}
+	a := NewBloomFilter(bitmap.NewInMemory(100), 100, 3)
+	b := NewBloomFilter(bitmap.NewInMemory(200), 200, 3)
+	a.location, b.location = countLocation, countLocation
+	assert.True(t, a.SameLocations(b))
+	assert.False(t, a.SameLocations(NewBloomFilter(bitmap.NewInMemory(100), 100, 2)))
+
+	locs := a.Locations(dataHello)
+	err := AddLocationsAll(context.Background(), locs, a, b)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, hashed)
+	for _, bf := range []*BloomFilter{a, b} {
+		exist, err := bf.ExistLocations(context.Background(), locs)
+		assert.NoError(t, err)
+		assert.True(t, exist)
+		exist, err = bf.Exist(dataHello)
+		assert.NoError(t, err)
+		assert.True(t, exist)
+	}
+}
+
+func TestBloomFilter_locations(t *testing.T) {
+	// inline hashing is equivalent to bloom.Locations
+	rnd := rand.New(rand.NewSource(1))
+	bf := NewBloomFilter(bitmap.NewInMemory(100), 100, 7)
+	for n := 0; n <= 100; n++ {
+		data := make([]byte, n)
+		rnd.Read(data)
+		assert.Equal(t, bloom.Locations(data, 7), bf.Locations(string(data)), "length: %d", n)
+	}
+}
+
+func TestBloomFilter_inMemoryAllocs(t *testing.T) {
+	bf := NewBloomFilter(bitmap.NewInMemory(1000), 1000, 5)
+	allocs := testing.AllocsPerRun(100, func() {
+		_ = bf.Add(dataHello)
+		exist, _ := bf.Exist(dataHello)
+		if !exist {
+			t.Fatal("data doesn't exist")
+		}
+	})
+	assert.Equal(t, float64(0), allocs)
+}
+
+func BenchmarkBloomFilter_Exist(b *testing.B) {
+	bf := NewBloomFilter(bitmap.NewInMemory(1<<20), 1<<20, 5)
+	_ = bf.Add(dataHello)
+	if allocs := testing.AllocsPerRun(100, func() { _, _ = bf.Exist(dataHello) }); allocs != 0 {
+		b.Fatalf("Exist allocates %v times, want 0", allocs)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = bf.Exist(dataHello)
+	}
+}
+
+func BenchmarkBloomFilter_Add(b *testing.B) {
+	bf := NewBloomFilter(bitmap.NewInMemory(1<<20), 1<<20, 5)
+	if allocs := testing.AllocsPerRun(100, func() { _ = bf.Add(dataHello) }); allocs != 0 {
+		b.Fatalf("Add allocates %v times, want 0", allocs)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = bf.Add(dataHello)
+	}
+}
@@ -1,9 +1,57 @@
 // Package filter manipulates bitmap to check and add the element.
 package filter
 
+import (
+	"context"
+)
+
 type Filter interface {
 	// Exist returns whether the data is in bitmap.Bitmap
 	Exist(data string) (bool, error)
 	// Add adds data into bitmap.Bitmap
 	Add(data string) error
 }
+
+// FillRatioReporter is implemented by Filter which is able to report the ratio of set bits of its bitmap.
+type FillRatioReporter interface {
+	FillRatio() (float64, error)
+}
+
+// Resetter is implemented by Filter which is able to remove all data.
+type Resetter interface {
+	// Reset removes all data, i.e. clears all bits of its bitmap.
+	Reset() error
+}
+
+// Snapshotter is implemented by Filter which is able to export bits of its bitmap.
+type Snapshotter interface {
+	// Snapshot returns bits in the format of bitmap.Exporter.
+	Snapshot() ([]byte, error)
+}
+
+// ContextFilter is implemented by Filter which performs operations with the per-call context.
+//
+// Exist and Add without the per-call context are performed with the context the filter is built with, if any.
+// Decorators keep it by calling Exist and Add of the decorated filter.
+type ContextFilter interface {
+	// ExistContext is the same with Exist but performed with ctx.
+	ExistContext(ctx context.Context, data string) (bool, error)
+	// AddContext is the same with Add but performed with ctx.
+	AddContext(ctx context.Context, data string) error
+}
+
+// ExistContext calls f.ExistContext if f is ContextFilter, otherwise f.Exist.
+func ExistContext(ctx context.Context, f Filter, data string) (bool, error) {
+	if cf, ok := f.(ContextFilter); ok {
+		return cf.ExistContext(ctx, data)
+	}
+	return f.Exist(data)
+}
+
+// AddContext calls f.AddContext if f is ContextFilter, otherwise f.Add.
+func AddContext(ctx context.Context, f Filter, data string) error {
+	if cf, ok := f.(ContextFilter); ok {
+		return cf.AddContext(ctx, data)
+	}
+	return f.Add(data)
+}
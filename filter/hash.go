@@ -0,0 +1,118 @@
+package filter
+
+import (
+	"math/bits"
+)
+
+const (
+	murmurC1 = 0x87c37b91114253d5
+	murmurC2 = 0x4cf5ad432745937f
+)
+
+// baseHashes returns the same base hashes as Locations of github.com/bits-and-blooms/bloom/v3 without allocation,
+// which are murmur3 128-bit hashes of data and of data followed by byte 1.
+func baseHashes(data string) [4]uint64 {
+	h1, h2 := sum128(data, false)
+	h3, h4 := sum128(data, true)
+	return [4]uint64{h1, h2, h3, h4}
+}
+
+// location returns the ith location by the base hashes, the same as Locations of github.com/bits-and-blooms/bloom/v3.
+func location(h [4]uint64, i uint64) uint64 {
+	return h[i%2] + i*h[2+(((i+(i%2))%4)/2)]
+}
+
+// sum128 returns murmur3 128-bit hash of data, followed by byte 1 if pad is true.
+func sum128(data string, pad bool) (h1, h2 uint64) {
+	length := len(data)
+	if pad {
+		length++
+	}
+	nblocks := length / 16
+	for i := 0; i < nblocks; i++ {
+		h1, h2 = bmix(h1, h2, word(data, i*16), word(data, i*16+8))
+	}
+
+	var k1, k2 uint64
+	tail := nblocks * 16
+	for i := length - 1; i >= tail; i-- {
+		if i-tail >= 8 {
+			k2 |= uint64(byteAt(data, i)) << (8 * uint(i-tail-8))
+		} else {
+			k1 |= uint64(byteAt(data, i)) << (8 * uint(i-tail))
+		}
+	}
+	if length-tail > 8 {
+		k2 *= murmurC2
+		k2 = bits.RotateLeft64(k2, 33)
+		k2 *= murmurC1
+		h2 ^= k2
+	}
+	if length-tail > 0 {
+		k1 *= murmurC1
+		k1 = bits.RotateLeft64(k1, 31)
+		k1 *= murmurC2
+		h1 ^= k1
+	}
+
+	h1 ^= uint64(length)
+	h2 ^= uint64(length)
+	h1 += h2
+	h2 += h1
+	h1 = fmix64(h1)
+	h2 = fmix64(h2)
+	h1 += h2
+	h2 += h1
+	return h1, h2
+}
+
+func bmix(h1, h2, k1, k2 uint64) (uint64, uint64) {
+	k1 *= murmurC1
+	k1 = bits.RotateLeft64(k1, 31)
+	k1 *= murmurC2
+	h1 ^= k1
+
+	h1 = bits.RotateLeft64(h1, 27)
+	h1 += h2
+	h1 = h1*5 + 0x52dce729
+
+	k2 *= murmurC2
+	k2 = bits.RotateLeft64(k2, 33)
+	k2 *= murmurC1
+	h2 ^= k2
+
+	h2 = bits.RotateLeft64(h2, 31)
+	h2 += h1
+	h2 = h2*5 + 0x38495ab5
+	return h1, h2
+}
+
+func fmix64(k uint64) uint64 {
+	k ^= k >> 33
+	k *= 0xff51afd7ed558ccd
+	k ^= k >> 33
+	k *= 0xc4ceb9fe1a85ec53
+	k ^= k >> 33
+	return k
+}
+
+// word returns the little-endian word at i of data padded by byte 1.
+func word(data string, i int) uint64 {
+	if i+8 <= len(data) {
+		return uint64(data[i]) | uint64(data[i+1])<<8 | uint64(data[i+2])<<16 | uint64(data[i+3])<<24 |
+			uint64(data[i+4])<<32 | uint64(data[i+5])<<40 | uint64(data[i+6])<<48 | uint64(data[i+7])<<56
+	}
+	var w uint64
+	for j := 0; j < 8; j++ {
+		w |= uint64(byteAt(data, i+j)) << (8 * uint(j))
+	}
+	return w
+}
+
+// byteAt returns the byte at i of data padded by byte 1.
+func byteAt(data string, i int) byte {
+	if i < len(data) {
+		return data[i]
+	}
+	return 1
+}
@@ -6,6 +6,12 @@ import (
 	"github.com/x0rworld/go-bloomfilter/config"
 	"github.com/x0rworld/go-bloomfilter/core"
 	"github.com/x0rworld/go-bloomfilter/filter"
+	"github.com/x0rworld/go-bloomfilter/schedule"
+	"github.com/x0rworld/go-bloomfilter/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"io"
+	"sync"
 	"sync/atomic"
 	"time"
 )
@@ -14,9 +20,20 @@ import (
 // It's the same signature with factory.FilterFactory.NewFilter.
 type NewFilterFunc func(ctx context.Context) (filter.Filter, error)
 
+// OnRotateFunc is called after rotation is performed.
+// retired is the filter which is no longer used by Rotator, current is the filter serving Exist after rotation.
+type OnRotateFunc func(ctx context.Context, retired, current filter.Filter)
+
+// OnRotateDoneFunc is called after each rotation attempt whether it succeeds or not, d is the duration of rotation.
+type OnRotateDoneFunc func(ctx context.Context, d time.Duration, err error)
+
 type filterPair struct {
-	current filter.Filter
-	next    filter.Filter
+	// generation is increased by each rotation.
+	generation uint64
+	current    filter.Filter
+	next       filter.Filter
+	// retired is ordered from the newest to the oldest, only kept for config.RotatorLookupAll.
+	retired []filter.Filter
 }
 
 type Rotator struct {
@@ -25,55 +42,247 @@ type Rotator struct {
 	newFilter NewFilterFunc
 	// type: *filterPair
 	pair atomic.Value
+
+	// mu serializes rotations and guards hooks, hooks are called without it.
+	mu        sync.Mutex
+	hooks     []OnRotateFunc
+	doneHooks []OnRotateDoneFunc
 }
 
-func (r *Rotator) handleRotating(freq time.Duration) {
+func (r *Rotator) handleRotating(sched schedule.Schedule) {
 	for {
 		current := time.Now()
-		next := current.Add(freq).Truncate(freq)
+		next := sched.Next(current)
+		if next.IsZero() {
+			return
+		}
 		timer := time.NewTimer(next.Sub(current))
 		select {
 		case <-timer.C:
-			r.rotate()
+			_ = r.Rotate(r.ctx)
 		case <-r.ctx.Done():
+			timer.Stop()
 			return
 		}
 	}
 }
 
-func (r *Rotator) rotate() error {
-	newFilter, err := r.genFilter(true)
+// Rotate performs rotation immediately: next filter becomes current one and a new next filter is generated.
+// The filter retired by rotation is passed to hooks registered by OnRotate.
+// The filter no longer referred by Rotator is closed after hooks if it implements io.Closer.
+//
+// ctx is only used for the call itself and is passed to hooks, the new filter is bound to the context of Rotator
+// since it outlives the call.
+//
+// Hooks are called after rotation is completed without holding the lock of Rotator, so that they are able to call
+// Rotate, Reset or register hooks, while hooks of concurrent rotations may be called in any order.
+func (r *Rotator) Rotate(ctx context.Context) (err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "Rotator.Rotate")
+	defer func() { tracing.End(span, err) }()
+
+	r.mu.Lock()
+	// hooks registered afterwards are appended beyond the length, so that the slices are safe to use without mu.
+	hooks, doneHooks := r.hooks, r.doneHooks
+	start := time.Now()
+	newFilter, err := r.genRotatedFilter(ctx)
+	d := time.Since(start)
 	if err != nil {
+		r.mu.Unlock()
+		for _, hook := range doneHooks {
+			hook(ctx, d, err)
+		}
 		return err
 	}
 
 	oldPair := r.pair.Load().(*filterPair)
 	newPair := &filterPair{
-		current: oldPair.next,
-		next:    newFilter,
+		generation: oldPair.generation + 1,
+		current:    oldPair.next,
+		next:       newFilter,
+	}
+	span.SetAttributes(attribute.Int64(tracing.AttrRotatorGeneration, int64(newPair.generation)))
+	// dropped is the filter no longer referred by Rotator.
+	dropped := oldPair.current
+	if r.cfg.Lookup == config.RotatorLookupAll && r.cfg.Retain > 0 {
+		retired := append([]filter.Filter{oldPair.current}, oldPair.retired...)
+		dropped = nil
+		if len(retired) > r.cfg.Retain {
+			dropped = retired[r.cfg.Retain]
+			retired = retired[:r.cfg.Retain]
+		}
+		newPair.retired = retired
 	}
 	r.pair.Store(newPair)
-	return err
+	r.mu.Unlock()
+
+	for _, hook := range doneHooks {
+		hook(ctx, d, nil)
+	}
+	for _, hook := range hooks {
+		hook(ctx, oldPair.current, newPair.current)
+	}
+	// release resources of dropped filter such as goroutines of tiered.Tiered.
+	if c, ok := dropped.(io.Closer); ok {
+		_ = c.Close()
+	}
+	return nil
+}
+
+func (r *Rotator) genRotatedFilter(ctx context.Context) (filter.Filter, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.genFilter(true, false)
+}
+
+// OnRotateDone registers hook which will be called after each rotation attempt in order of registration.
+func (r *Rotator) OnRotateDone(hook OnRotateDoneFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.doneHooks = append(r.doneHooks, hook)
 }
 
+// OnRotate registers hook which will be called after each rotation in order of registration.
+func (r *Rotator) OnRotate(hook OnRotateFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hooks = append(r.hooks, hook)
+}
+
+// Exist checks filters depending on config.RotatorLookup, see config.RotatorLookupCurrent,
+// config.RotatorLookupAny and config.RotatorLookupAll for the guarantee of each one.
 func (r *Rotator) Exist(data string) (bool, error) {
-	return r.pair.Load().(*filterPair).current.Exist(data)
+	return r.ExistContext(r.ctx, data)
+}
+
+// ExistContext is the same with Exist but checks filters with ctx.
+func (r *Rotator) ExistContext(ctx context.Context, data string) (exist bool, err error) {
+	p := r.pair.Load().(*filterPair)
+	ctx, span := r.startSpan(ctx, "Rotator.Exist", p)
+	defer func() { tracing.End(span, err) }()
+
+	switch r.cfg.Lookup {
+	case config.RotatorLookupAny:
+		return existAny(ctx, data, p.current, p.next)
+	case config.RotatorLookupAll:
+		return existAny(ctx, data, append([]filter.Filter{p.current, p.next}, p.retired...)...)
+	default:
+		return filter.ExistContext(ctx, p.current, data)
+	}
+}
+
+// bloomFilters returns filters as filter.BloomFilter if all of them share the same locations,
+// so that data is hashed once for all of them.
+func bloomFilters(filters ...filter.Filter) ([]*filter.BloomFilter, bool) {
+	bfs := make([]*filter.BloomFilter, 0, len(filters))
+	for _, f := range filters {
+		bf, ok := f.(*filter.BloomFilter)
+		if !ok || (len(bfs) > 0 && !bfs[0].SameLocations(bf)) {
+			return nil, false
+		}
+		bfs = append(bfs, bf)
+	}
+	return bfs, true
 }
 
 func (r *Rotator) Add(data string) error {
+	return r.AddContext(r.ctx, data)
+}
+
+// AddContext is the same with Add but adds data into filters with ctx.
+func (r *Rotator) AddContext(ctx context.Context, data string) (err error) {
 	p := r.pair.Load().(*filterPair)
-	err := p.current.Add(data)
+	ctx, span := r.startSpan(ctx, "Rotator.Add", p)
+	defer func() { tracing.End(span, err) }()
+
+	// hash once and set bits of both filters at once, e.g. a single pipeline for redis.
+	if bfs, ok := bloomFilters(p.current, p.next); ok {
+		return filter.AddLocationsAll(ctx, bfs[0].Locations(data), bfs...)
+	}
+	err = filter.AddContext(ctx, p.current, data)
 	if err != nil {
 		return err
 	}
-	return p.next.Add(data)
+	return filter.AddContext(ctx, p.next, data)
+}
+
+func (r *Rotator) startSpan(ctx context.Context, name string, p *filterPair) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(ctx, name, trace.WithAttributes(
+		attribute.Int64(tracing.AttrRotatorGeneration, int64(p.generation)),
+		attribute.String(tracing.AttrRotatorLookup, string(r.cfg.Lookup)),
+	))
+}
+
+// FillRatio returns fill ratio of current filter, it returns filter.ErrUnsupported if current filter doesn't support it.
+func (r *Rotator) FillRatio() (float64, error) {
+	fr, ok := r.pair.Load().(*filterPair).current.(filter.FillRatioReporter)
+	if !ok {
+		return 0, filter.ErrUnsupported
+	}
+	return fr.FillRatio()
+}
+
+// Reset removes all data from current, next and the retained filters,
+// it returns filter.ErrUnsupported if any of them doesn't support it.
+func (r *Rotator) Reset() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p := r.pair.Load().(*filterPair)
+	for _, f := range append([]filter.Filter{p.current, p.next}, p.retired...) {
+		rs, ok := f.(filter.Resetter)
+		if !ok {
+			return filter.ErrUnsupported
+		}
+		if err := rs.Reset(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Snapshot returns bits of current filter, it returns filter.ErrUnsupported if current filter doesn't support it.
+func (r *Rotator) Snapshot() ([]byte, error) {
+	s, ok := r.pair.Load().(*filterPair).current.(filter.Snapshotter)
+	if !ok {
+		return nil, filter.ErrUnsupported
+	}
+	return s.Snapshot()
 }
 
-func (r *Rotator) genFilter(isNext bool) (filter.Filter, error) {
+// existAny returns true once data exists in any of filters.
+func existAny(ctx context.Context, data string, filters ...filter.Filter) (bool, error) {
+	if bfs, ok := bloomFilters(filters...); ok {
+		locs := bfs[0].Locations(data)
+		for _, bf := range bfs {
+			exist, err := bf.ExistLocations(ctx, locs)
+			if err != nil {
+				return false, err
+			}
+			if exist {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	for _, f := range filters {
+		exist, err := filter.ExistContext(ctx, f, data)
+		if err != nil {
+			return false, err
+		}
+		if exist {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *Rotator) genFilter(isNext, isInitial bool) (filter.Filter, error) {
 	// currently, only RedisBitmapFactory.NewBitmap() refers the value.
 	val := core.BitmapFactoryCtxValue{
 		IsRotatorEnabled: r.cfg.Enable,
 		IsNextFilter:     isNext,
+		IsInitial:        isInitial,
 		RotatorMode:      r.cfg.Mode,
 		Now:              time.Now(),
 	}
@@ -86,11 +295,11 @@ func (r *Rotator) genFilter(isNext bool) (filter.Filter, error) {
 }
 
 func (r *Rotator) genFilterPair() (*filterPair, error) {
-	current, err := r.genFilter(false)
+	current, err := r.genFilter(false, true)
 	if err != nil {
 		return nil, err
 	}
-	next, err := r.genFilter(true)
+	next, err := r.genFilter(true, true)
 	if err != nil {
 		return nil, err
 	}
@@ -101,6 +310,8 @@ func (r *Rotator) genFilterPair() (*filterPair, error) {
 }
 
 // NewRotator returns *Rotator that rotates filter by period, all rotating filters will be generated by newFilter.
+// The period is cfg.Schedule if present, otherwise cfg.Freq.
+// If cfg.Mode is config.RotatorModeManual, no rotation is performed until Rotate is called.
 func NewRotator(ctx context.Context, cfg config.RotatorConfig, newFilter NewFilterFunc) (*Rotator, error) {
 	r := &Rotator{
 		ctx:       ctx,
@@ -108,13 +319,24 @@ func NewRotator(ctx context.Context, cfg config.RotatorConfig, newFilter NewFilt
 		newFilter: newFilter,
 	}
 
+	var sched schedule.Schedule
+	if cfg.Mode != config.RotatorModeManual {
+		var err error
+		sched, err = cfg.NewSchedule()
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	p, err := r.genFilterPair()
 	if err != nil {
 		return nil, err
 	}
 	r.pair.Store(p)
 
-	go r.handleRotating(cfg.Freq)
+	if sched != nil {
+		go r.handleRotating(sched)
+	}
 
 	return r, nil
 }
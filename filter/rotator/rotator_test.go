@@ -2,6 +2,9 @@ package rotator
 
 import (
 	"context"
+	"fmt"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
 	"github.com/stretchr/testify/assert"
 	"github.com/x0rworld/go-bloomfilter/bitmap"
 	"github.com/x0rworld/go-bloomfilter/config"
@@ -25,7 +28,8 @@ func TestRotator_doRotation(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, true, exist)
 
-	_ = rotator.rotate()
+	err = rotator.Rotate(context.Background())
+	assert.NoError(t, err)
 
 	// validate data in current and next filter
 	cExist, err := rotator.pair.Load().(*filterPair).current.Exist(data)
@@ -36,6 +40,37 @@ func TestRotator_doRotation(t *testing.T) {
 	assert.Equal(t, false, nExist)
 }
 
+func TestRotator_Rotate(t *testing.T) {
+	rotator := genRotator(t, config.RotatorConfig{
+		Enable: true,
+		Mode:   config.RotatorModeManual,
+	})
+	var retired, current []filter.Filter
+	rotator.OnRotate(func(_ context.Context, r, c filter.Filter) {
+		retired = append(retired, r)
+		current = append(current, c)
+	})
+	oldPair := rotator.pair.Load().(*filterPair)
+
+	err := rotator.Rotate(context.Background())
+	assert.NoError(t, err)
+	newPair := rotator.pair.Load().(*filterPair)
+	assert.Same(t, oldPair.next, newPair.current)
+	assert.NotSame(t, oldPair.next, newPair.next)
+	// hook receives the retired and current filter
+	assert.Len(t, retired, 1)
+	assert.Same(t, oldPair.current, retired[0])
+	assert.Same(t, newPair.current, current[0])
+
+	// canceled context prevents rotation
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = rotator.Rotate(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Same(t, newPair, rotator.pair.Load().(*filterPair))
+	assert.Len(t, retired, 1)
+}
+
 func TestRotator_Exist(t *testing.T) {
 	data := "hello"
 	// scenario 1: current & next don't have data, expect to get non-existing
@@ -71,6 +106,156 @@ func TestRotator_Exist(t *testing.T) {
 	assert.Equal(t, true, exist)
 }
 
+func TestRotator_OnRotateDone(t *testing.T) {
+	rotator := genRotator(t, config.RotatorConfig{
+		Enable: true,
+		Mode:   config.RotatorModeManual,
+	})
+	var errs []error
+	rotator.OnRotateDone(func(_ context.Context, _ time.Duration, err error) {
+		errs = append(errs, err)
+	})
+	err := rotator.Rotate(context.Background())
+	assert.NoError(t, err)
+
+	// failed rotation is observed as well
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = rotator.Rotate(ctx)
+	assert.Error(t, err)
+	assert.Equal(t, []error{nil, context.Canceled}, errs)
+}
+
+func TestRotator_OnRotate_reentrant(t *testing.T) {
+	rotator := genRotator(t, config.RotatorConfig{
+		Enable: true,
+		Mode:   config.RotatorModeManual,
+	})
+	// hooks are able to call Rotator
+	rotations := 0
+	rotator.OnRotate(func(ctx context.Context, _, _ filter.Filter) {
+		rotations++
+		if rotations == 1 {
+			rotator.OnRotateDone(func(context.Context, time.Duration, error) {})
+			assert.NoError(t, rotator.Reset())
+			assert.NoError(t, rotator.Rotate(ctx))
+		}
+	})
+	done := make(chan error, 1)
+	go func() {
+		done <- rotator.Rotate(context.Background())
+	}()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Rotate is deadlocked by hooks")
+	}
+	assert.Equal(t, 2, rotations)
+	assert.Equal(t, uint64(2), rotator.pair.Load().(*filterPair).generation)
+}
+
+func TestRotator_FillRatio(t *testing.T) {
+	rotator := genRotator(t, genDefaultRotatorConfig())
+	err := rotator.Add("hello")
+	assert.NoError(t, err)
+	ratio, err := rotator.FillRatio()
+	assert.NoError(t, err)
+	assert.Greater(t, ratio, float64(0))
+}
+
+func TestRotator_Reset(t *testing.T) {
+	cfg := genDefaultRotatorConfig()
+	cfg.Lookup = config.RotatorLookupAll
+	cfg.Retain = 1
+	rotator := genRotator(t, cfg)
+	err := rotator.Add("hello")
+	assert.NoError(t, err)
+	err = rotator.Rotate(context.Background())
+	assert.NoError(t, err)
+
+	err = rotator.Reset()
+	assert.NoError(t, err)
+	exist, err := rotator.Exist("hello")
+	assert.NoError(t, err)
+	assert.Equal(t, false, exist)
+}
+
+func TestRotator_ExistLookup(t *testing.T) {
+	data := "hello"
+	tests := []struct {
+		name   string
+		lookup config.RotatorLookup
+		retain int
+		// addTo adds data into filters of the rotator before checking.
+		addTo     func(p *filterPair) error
+		rotations int
+		want      bool
+	}{
+		{
+			name:   "current: data only in next",
+			lookup: config.RotatorLookupCurrent,
+			addTo:  func(p *filterPair) error { return p.next.Add(data) },
+			want:   false,
+		},
+		{
+			name:   "any: data only in next",
+			lookup: config.RotatorLookupAny,
+			addTo:  func(p *filterPair) error { return p.next.Add(data) },
+			want:   true,
+		},
+		{
+			name:   "any: data only in current",
+			lookup: config.RotatorLookupAny,
+			addTo:  func(p *filterPair) error { return p.current.Add(data) },
+			want:   true,
+		},
+		{
+			name:      "any: data only in current is rotated out",
+			lookup:    config.RotatorLookupAny,
+			addTo:     func(p *filterPair) error { return p.current.Add(data) },
+			rotations: 1,
+			want:      false,
+		},
+		{
+			name:      "all: data is kept by retained filter",
+			lookup:    config.RotatorLookupAll,
+			retain:    2,
+			addTo:     func(p *filterPair) error { return p.current.Add(data) },
+			rotations: 2,
+			want:      true,
+		},
+		{
+			name:      "all: data is rotated out after retained filters",
+			lookup:    config.RotatorLookupAll,
+			retain:    2,
+			addTo:     func(p *filterPair) error { return p.current.Add(data) },
+			rotations: 3,
+			want:      false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rotator := genRotator(t, config.RotatorConfig{
+				Enable: true,
+				Mode:   config.RotatorModeManual,
+				Lookup: tt.lookup,
+				Retain: tt.retain,
+			})
+			err := tt.addTo(rotator.pair.Load().(*filterPair))
+			assert.NoError(t, err)
+			for i := 0; i < tt.rotations; i++ {
+				err = rotator.Rotate(context.Background())
+				assert.NoError(t, err)
+			}
+			exist, err := rotator.Exist(data)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, exist)
+			assert.LessOrEqual(t, len(rotator.pair.Load().(*filterPair).retired), tt.retain)
+		})
+	}
+}
+
 func TestRotator_Add(t *testing.T) {
 	rotator := genRotator(t, genDefaultRotatorConfig())
 	data := "hello"
@@ -83,6 +268,108 @@ func TestRotator_Add(t *testing.T) {
 	assert.Equal(t, true, cExist && nExist)
 }
 
+// pipelineCounter is redis.Hook counting the processed pipelines.
+type pipelineCounter struct {
+	pipelines int
+}
+
+func (p *pipelineCounter) BeforeProcess(ctx context.Context, _ redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+func (p *pipelineCounter) AfterProcess(_ context.Context, _ redis.Cmder) error {
+	return nil
+}
+
+func (p *pipelineCounter) BeforeProcessPipeline(ctx context.Context, _ []redis.Cmder) (context.Context, error) {
+	p.pipelines++
+	return ctx, nil
+}
+
+func (p *pipelineCounter) AfterProcessPipeline(_ context.Context, _ []redis.Cmder) error {
+	return nil
+}
+
+func TestRotator_Add_redis(t *testing.T) {
+	mr := miniredis.RunT(t)
+	counter := &pipelineCounter{}
+	n := 0
+	newRedisFilter := func(ctx context.Context) (filter.Filter, error) {
+		n++
+		client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		client.AddHook(counter)
+		bm, err := bitmap.NewRedis(ctx, client, fmt.Sprintf("test-Rotator_Add_redis-%d", n), 100)
+		if err != nil {
+			return nil, err
+		}
+		return filter.NewBloomFilter(bm, 100, 3), nil
+	}
+	rotator, err := NewRotator(context.Background(), config.RotatorConfig{
+		Enable: true,
+		Mode:   config.RotatorModeManual,
+		Lookup: config.RotatorLookupAny,
+	}, newRedisFilter)
+	assert.NoError(t, err)
+
+	err = rotator.Add("hello")
+	assert.NoError(t, err)
+	// bits of current and next filter are set by a single pipeline
+	assert.Equal(t, 1, counter.pipelines)
+	p := rotator.pair.Load().(*filterPair)
+	for _, f := range []filter.Filter{p.current, p.next} {
+		exist, err := f.Exist("hello")
+		assert.NoError(t, err)
+		assert.Equal(t, true, exist)
+	}
+	exist, err := rotator.Exist("world")
+	assert.NoError(t, err)
+	assert.Equal(t, false, exist)
+}
+
+func TestNewRotator_invalidSchedule(t *testing.T) {
+	r, err := NewRotator(context.Background(), config.RotatorConfig{
+		Enable:   true,
+		Schedule: "invalid",
+	}, newFilter)
+	assert.Error(t, err)
+	assert.Nil(t, r)
+}
+
+// closableFilter records whether it's closed.
+type closableFilter struct {
+	filter.Filter
+	closed bool
+}
+
+func (c *closableFilter) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestRotator_Rotate_closeDropped(t *testing.T) {
+	r, err := NewRotator(context.Background(), config.RotatorConfig{
+		Enable: true,
+		Mode:   config.RotatorModeManual,
+		Lookup: config.RotatorLookupAll,
+		Retain: 1,
+	}, func(ctx context.Context) (filter.Filter, error) {
+		f, _ := newFilter(ctx)
+		return &closableFilter{Filter: f}, nil
+	})
+	assert.NoError(t, err)
+	first := r.pair.Load().(*filterPair).current.(*closableFilter)
+
+	// first filter is retained
+	err = r.Rotate(context.Background())
+	assert.NoError(t, err)
+	assert.False(t, first.closed)
+
+	// first filter is dropped
+	err = r.Rotate(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, first.closed)
+}
+
 func genDefaultRotatorConfig() config.RotatorConfig {
 	return config.RotatorConfig{
 		Enable: true,
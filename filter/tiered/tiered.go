@@ -0,0 +1,163 @@
+// Package tiered provides filter which caches remote bitmap such as bitmap.Redis by local bitmap.InMemory.
+package tiered
+
+import (
+	"context"
+	"github.com/x0rworld/go-bloomfilter/bitmap"
+	"github.com/x0rworld/go-bloomfilter/filter"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// FetchFunc returns the raw value of the remote bitmap to re-sync the local tier, see bitmap.Redis.Bytes.
+type FetchFunc func() ([]byte, error)
+
+// Tiered is a read-through filter with two tiers:
+//   - Add writes to both of the remote and local tier.
+//   - Exist answers positives from the local tier, and checks the remote tier only if the local one misses.
+//
+// The local tier is periodically replaced by the remote one fetched by FetchFunc,
+// so that data added by others is cached as well.
+type Tiered struct {
+	// ctx is used by calls without context, it's not canceled by Close.
+	ctx context.Context
+	// cancel stops handleSyncing.
+	cancel context.CancelFunc
+	remote *filter.BloomFilter
+	fetch  FetchFunc
+	m      uint64
+	k      uint64
+	// type: *filter.BloomFilter
+	local atomic.Value
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+func (t *Tiered) Exist(data string) (bool, error) {
+	return t.ExistContext(t.ctx, data)
+}
+
+func (t *Tiered) ExistContext(ctx context.Context, data string) (bool, error) {
+	exist, err := t.loadLocal().ExistContext(ctx, data)
+	if err != nil {
+		return false, err
+	}
+	if exist {
+		return true, nil
+	}
+	return t.remote.ExistContext(ctx, data)
+}
+
+func (t *Tiered) Add(data string) error {
+	return t.AddContext(t.ctx, data)
+}
+
+func (t *Tiered) AddContext(ctx context.Context, data string) error {
+	if err := t.remote.AddContext(ctx, data); err != nil {
+		return err
+	}
+	return t.loadLocal().AddContext(ctx, data)
+}
+
+// FillRatio returns fill ratio of the remote tier.
+func (t *Tiered) FillRatio() (float64, error) {
+	return t.remote.FillRatio()
+}
+
+// Reset clears the remote tier and replaces the local tier by an empty one.
+func (t *Tiered) Reset() error {
+	if err := t.remote.Reset(); err != nil {
+		return err
+	}
+	t.local.Store(t.newLocal(bitmap.NewInMemory(t.m)))
+	return nil
+}
+
+// Snapshot returns bits of the remote tier.
+func (t *Tiered) Snapshot() ([]byte, error) {
+	return t.remote.Snapshot()
+}
+
+// Sync replaces the local tier by the remote one immediately.
+func (t *Tiered) Sync() error {
+	data, err := t.fetch()
+	if err != nil {
+		return err
+	}
+	t.local.Store(t.newLocal(bitmap.NewInMemoryFromRedis(t.m, data)))
+	return nil
+}
+
+// Close stops re-syncing the local tier, the filter is still available after closing.
+func (t *Tiered) Close() error {
+	t.closeOnce.Do(func() {
+		t.cancel()
+		<-t.done
+	})
+	return nil
+}
+
+func (t *Tiered) loadLocal() *filter.BloomFilter {
+	return t.local.Load().(*filter.BloomFilter)
+}
+
+// newLocal returns the local tier of bm, which is guarded by lockedBitmap since bitmap.InMemory is not safe for concurrent use.
+func (t *Tiered) newLocal(bm bitmap.Bitmap) *filter.BloomFilter {
+	return filter.NewBloomFilter(&lockedBitmap{bm: bm}, t.m, t.k)
+}
+
+func (t *Tiered) handleSyncing(ctx context.Context, interval time.Duration) {
+	defer close(t.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			// it's fine to skip a failed sync, the local tier is still valid and re-synced next time.
+			_ = t.Sync()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// NewTiered returns Tiered whose remote tier is remote and local tier is bitmap.InMemory, both have m bits and k hashes.
+// The local tier is synced by fetch once before returning and every interval until ctx is done or Close is called.
+func NewTiered(ctx context.Context, remote bitmap.Bitmap, fetch FetchFunc, m, k uint64, interval time.Duration) (*Tiered, error) {
+	syncCtx, cancel := context.WithCancel(ctx)
+	t := &Tiered{
+		ctx:    ctx,
+		cancel: cancel,
+		remote: filter.NewBloomFilter(remote, m, k),
+		fetch:  fetch,
+		m:      m,
+		k:      k,
+		done:   make(chan struct{}),
+	}
+	if err := t.Sync(); err != nil {
+		cancel()
+		return nil, err
+	}
+	go t.handleSyncing(syncCtx, interval)
+	return t, nil
+}
+
+// lockedBitmap serializes access to bm.
+type lockedBitmap struct {
+	mu sync.RWMutex
+	bm bitmap.Bitmap
+}
+
+func (l *lockedBitmap) CheckBits(locs []uint64) (bool, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.bm.CheckBits(locs)
+}
+
+func (l *lockedBitmap) SetBits(locs []uint64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.bm.SetBits(locs)
+}
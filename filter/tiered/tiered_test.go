@@ -0,0 +1,147 @@
+package tiered
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/x0rworld/go-bloomfilter/bitmap"
+	"github.com/x0rworld/go-bloomfilter/filter"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func genTiered(t *testing.T, mr *miniredis.Miniredis, key string) *Tiered {
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	rbm, err := bitmap.NewRedis(context.Background(), client, key, 100)
+	assert.NoError(t, err)
+	tf, err := NewTiered(context.Background(), rbm, rbm.Bytes, 100, 3, time.Hour)
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = tf.Close() })
+	return tf
+}
+
+func TestTiered_Add(t *testing.T) {
+	mr := miniredis.RunT(t)
+	tf := genTiered(t, mr, "test-Tiered_Add")
+	data := "hello"
+	err := tf.Add(data)
+	assert.NoError(t, err)
+
+	// data is written to both tiers
+	exist, err := tf.loadLocal().Exist(data)
+	assert.NoError(t, err)
+	assert.Equal(t, true, exist)
+	exist, err = tf.remote.Exist(data)
+	assert.NoError(t, err)
+	assert.Equal(t, true, exist)
+
+	// positive is answered by the local tier even if redis is unavailable
+	mr.Close()
+	exist, err = tf.Exist(data)
+	assert.NoError(t, err)
+	assert.Equal(t, true, exist)
+}
+
+func TestTiered_Sync(t *testing.T) {
+	mr := miniredis.RunT(t)
+	key := "test-Tiered_Sync"
+	tf := genTiered(t, mr, key)
+	other := genTiered(t, mr, key)
+	data := "hello"
+
+	// data added by others is checked via the remote tier
+	err := other.Add(data)
+	assert.NoError(t, err)
+	exist, err := tf.loadLocal().Exist(data)
+	assert.NoError(t, err)
+	assert.Equal(t, false, exist)
+	exist, err = tf.Exist(data)
+	assert.NoError(t, err)
+	assert.Equal(t, true, exist)
+
+	// data is cached by the local tier after sync
+	err = tf.Sync()
+	assert.NoError(t, err)
+	exist, err = tf.loadLocal().Exist(data)
+	assert.NoError(t, err)
+	assert.Equal(t, true, exist)
+}
+
+func TestTiered_handleSyncing(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	key := "test-Tiered_handleSyncing"
+	rbm, err := bitmap.NewRedis(context.Background(), client, key, 100)
+	assert.NoError(t, err)
+	tf, err := NewTiered(context.Background(), rbm, rbm.Bytes, 100, 3, 10*time.Millisecond)
+	assert.NoError(t, err)
+	defer tf.Close()
+
+	err = filter.NewBloomFilter(rbm, 100, 3).Add("hello")
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		exist, _ := tf.loadLocal().Exist("hello")
+		return exist
+	}, time.Second, 10*time.Millisecond)
+
+	// closing stops syncing and it's idempotent
+	assert.NoError(t, tf.Close())
+	assert.NoError(t, tf.Close())
+}
+
+func TestTiered_Reset(t *testing.T) {
+	mr := miniredis.RunT(t)
+	tf := genTiered(t, mr, "test-Tiered_Reset")
+	data := "hello"
+	err := tf.Add(data)
+	assert.NoError(t, err)
+
+	err = tf.Reset()
+	assert.NoError(t, err)
+	exist, err := tf.loadLocal().Exist(data)
+	assert.NoError(t, err)
+	assert.Equal(t, false, exist)
+	exist, err = tf.Exist(data)
+	assert.NoError(t, err)
+	assert.Equal(t, false, exist)
+}
+
+func TestTiered_Close(t *testing.T) {
+	mr := miniredis.RunT(t)
+	tf := genTiered(t, mr, "test-Tiered_Close")
+	assert.NoError(t, tf.Add("hello"))
+	assert.NoError(t, tf.Close())
+
+	// the filter is still available after closing
+	assert.NoError(t, tf.Add("world"))
+	exist, err := tf.Exist("world")
+	assert.NoError(t, err)
+	assert.True(t, exist)
+	exist, err = tf.Exist("foo")
+	assert.NoError(t, err)
+	assert.False(t, exist)
+}
+
+func TestTiered_Add_concurrent(t *testing.T) {
+	mr := miniredis.RunT(t)
+	tf := genTiered(t, mr, "test-Tiered_Add_concurrent")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				data := strconv.Itoa(i*20 + j)
+				assert.NoError(t, tf.Add(data))
+				exist, err := tf.loadLocal().Exist(data)
+				assert.NoError(t, err)
+				assert.True(t, exist)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
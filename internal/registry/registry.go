@@ -0,0 +1,31 @@
+// Package registry holds custom bitmap backends registered by factory.RegisterBitmap, so that config validates
+// bitmap types by the same registry as factory looks up their constructors.
+package registry
+
+import (
+	"sync"
+)
+
+var (
+	mu      sync.RWMutex
+	bitmaps = map[string]any{}
+)
+
+// RegisterBitmap records constructor of bitmap typ, it returns false if typ is already registered.
+func RegisterBitmap(typ string, constructor any) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := bitmaps[typ]; ok {
+		return false
+	}
+	bitmaps[typ] = constructor
+	return true
+}
+
+// Bitmap returns constructor of bitmap typ registered by RegisterBitmap.
+func Bitmap(typ string) (any, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	constructor, ok := bitmaps[typ]
+	return constructor, ok
+}
@@ -0,0 +1,21 @@
+// Package logging defines Logger receiving events of this library such as filter creation and rotation.
+package logging
+
+// Logger is compatible with *slog.Logger, args are alternating keys and values.
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// NopLogger discards all events, it's the default Logger.
+type NopLogger struct{}
+
+func (NopLogger) Debug(string, ...any) {}
+
+func (NopLogger) Info(string, ...any) {}
+
+func (NopLogger) Warn(string, ...any) {}
+
+func (NopLogger) Error(string, ...any) {}
@@ -0,0 +1,507 @@
+// Package manager provides registry of named filters, e.g. a filter per customer of multi-tenant services.
+package manager
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"github.com/x0rworld/go-bloomfilter/config"
+	"github.com/x0rworld/go-bloomfilter/factory"
+	"github.com/x0rworld/go-bloomfilter/filter"
+	"io"
+	"sync"
+	"time"
+)
+
+var (
+	ErrManagerClosed = errors.New("manager is closed")
+	// ErrOverBudget is returned if local bitmaps of a single filter are larger than the memory budget.
+	ErrOverBudget = errors.New("filter is larger than memory budget")
+	// ErrFilterExists is returned by Create if the filter is cached.
+	ErrFilterExists = errors.New("filter exists")
+	// ErrFilterNotFound is returned by Lookup if the filter is neither cached nor existing in redis.
+	ErrFilterNotFound = errors.New("filter not found")
+)
+
+// KeyFunc returns redis key of the filter named name.
+type KeyFunc func(name string) string
+
+// ConfigFunc returns config of the filter named name, cfg is the template whose redis key is derived by KeyFunc.
+// It's used to customize filters by name, e.g. M and K per customer.
+type ConfigFunc func(name string, cfg config.FactoryConfig) (config.FactoryConfig, error)
+
+type ManagerOption func(*Manager)
+
+// Manager creates filters by name lazily from the template config and caches them until they're removed,
+// evicted or the manager is closed.
+//
+// Filters of redis share a client per address, and their keys are derived from the name by KeyFunc.
+// In-memory filters are evicted in least recently used order once local bitmaps exceed the memory budget,
+// see ManagerMemoryBudget. Evicted filters lose their data and are re-created empty by the next Get.
+// Filters already returned remain usable after eviction, but rotation of them stops.
+type Manager struct {
+	ctx        context.Context
+	cancel     context.CancelFunc
+	template   config.FactoryConfig
+	keyFunc    KeyFunc
+	configFunc ConfigFunc
+	budget     uint64
+
+	mu      sync.Mutex
+	entries map[string]*entry
+	// lru orders entries by the last Get, the front is the most recently used.
+	lru     *list.List
+	used    uint64
+	clients map[redisAddr]*redis.Client
+	closed  bool
+}
+
+type entry struct {
+	name   string
+	elem   *list.Element
+	cfg    config.FactoryConfig
+	size   uint64
+	ready  chan struct{}
+	f      filter.Filter
+	err    error
+	cancel context.CancelFunc
+}
+
+type redisAddr struct {
+	addr    string
+	timeout time.Duration
+}
+
+// Get returns the filter named name, it's created by the template config at the first call.
+// The redis filter existing in redis keeps M and K of its metadata instead, e.g. it's created by Create
+// before the manager restarts or evicts it. Concurrent calls for the same name share a single creation.
+func (m *Manager) Get(name string) (filter.Filter, error) {
+	if name == "" {
+		return nil, errors.New("empty name")
+	}
+	if e, ok, err := m.lookup(name); ok || err != nil {
+		return wait(e, err)
+	}
+	cfg, err := m.config(name)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.FilterConfig.BitmapConfig.Type == config.BitmapTypeRedis {
+		existing, err := m.existingConfig(cfg)
+		if err == nil {
+			cfg = existing
+		} else if !errors.Is(err, factory.ErrMetaNotFound) {
+			return nil, err
+		}
+	}
+	return m.create(name, cfg, false)
+}
+
+// Lookup returns the filter named name without creating it by the template config, i.e. the cached filter or
+// the redis filter existing in redis, whose M and K refer to its metadata (see factory.ReadRedisFilterMeta).
+// It returns ErrFilterNotFound otherwise, e.g. for read-only requests of unknown names.
+func (m *Manager) Lookup(name string) (filter.Filter, error) {
+	if name == "" {
+		return nil, errors.New("empty name")
+	}
+	if e, ok, err := m.lookup(name); ok || err != nil {
+		return wait(e, err)
+	}
+	cfg, err := m.config(name)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.FilterConfig.BitmapConfig.Type != config.BitmapTypeRedis {
+		return nil, fmt.Errorf("%w: %v", ErrFilterNotFound, name)
+	}
+	cfg, err = m.existingConfig(cfg)
+	if errors.Is(err, factory.ErrMetaNotFound) {
+		return nil, fmt.Errorf("%w: %v", ErrFilterNotFound, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return m.create(name, cfg, false)
+}
+
+// Create creates the filter named name by config customized by fn after ManagerConfigFunc,
+// e.g. to reserve a filter of M and K different from the template.
+// It returns ErrFilterExists if the filter is cached or exists in redis.
+func (m *Manager) Create(name string, fn ConfigFunc) (filter.Filter, error) {
+	if fn == nil {
+		return nil, errors.New("nil config func")
+	}
+	if name == "" {
+		return nil, errors.New("empty name")
+	}
+	if _, ok, err := m.lookup(name); err != nil {
+		return nil, err
+	} else if ok {
+		return nil, fmt.Errorf("%w: %v", ErrFilterExists, name)
+	}
+	cfg, err := m.config(name)
+	if err != nil {
+		return nil, err
+	}
+	if cfg, err = fn(name, cfg); err != nil {
+		return nil, err
+	}
+	if cfg.FilterConfig.BitmapConfig.Type == config.BitmapTypeRedis {
+		_, err := m.existingConfig(cfg)
+		if err == nil {
+			return nil, fmt.Errorf("%w: %v", ErrFilterExists, name)
+		}
+		if !errors.Is(err, factory.ErrMetaNotFound) {
+			return nil, err
+		}
+	}
+	return m.create(name, cfg, true)
+}
+
+// Config returns config of the cached filter named name.
+func (m *Manager) Config(name string) (config.FactoryConfig, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.entries[name]
+	if !ok {
+		return config.FactoryConfig{}, false
+	}
+	return e.cfg, true
+}
+
+// create creates the filter named name by cfg unless it's created by another caller meanwhile,
+// which is returned, or ErrFilterExists if exclusive is true.
+func (m *Manager) create(name string, cfg config.FactoryConfig, exclusive bool) (filter.Filter, error) {
+	size := memorySize(cfg)
+
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil, ErrManagerClosed
+	}
+	// created by another caller meanwhile.
+	if e, ok := m.entries[name]; ok {
+		m.lru.MoveToFront(e.elem)
+		m.mu.Unlock()
+		if exclusive {
+			return nil, fmt.Errorf("%w: %v", ErrFilterExists, name)
+		}
+		return wait(e, nil)
+	}
+	if m.budget > 0 && size > m.budget {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("%w: %v bytes of %v", ErrOverBudget, size, name)
+	}
+	evicted := m.evict(size)
+	e := &entry{name: name, cfg: cfg, size: size, ready: make(chan struct{})}
+	e.elem = m.lru.PushFront(e)
+	m.entries[name] = e
+	m.used += size
+	m.mu.Unlock()
+
+	for _, ev := range evicted {
+		closeEntry(ev)
+	}
+
+	e.f, e.cancel, e.err = m.newFilter(cfg)
+
+	m.mu.Lock()
+	if e.err != nil {
+		m.remove(e)
+	} else if m.closed {
+		// Close missed the entry being created.
+		closeEntry(e)
+		e.f, e.err = nil, ErrManagerClosed
+	}
+	close(e.ready)
+	m.mu.Unlock()
+	return e.f, e.err
+}
+
+// existingConfig returns cfg whose M and K refer to metadata of the filter existing in redis,
+// it returns factory.ErrMetaNotFound if the filter doesn't exist.
+func (m *Manager) existingConfig(cfg config.FactoryConfig) (config.FactoryConfig, error) {
+	client, err := m.redisClient(cfg.RedisConfig)
+	if err != nil {
+		return cfg, err
+	}
+	meta, err := factory.ReadRedisFilterMeta(m.ctx, client, cfg)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.FilterConfig.M, cfg.FilterConfig.K = meta.M, meta.K
+	return cfg, nil
+}
+
+func (m *Manager) lookup(name string) (*entry, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil, false, ErrManagerClosed
+	}
+	e, ok := m.entries[name]
+	if ok {
+		m.lru.MoveToFront(e.elem)
+	}
+	return e, ok, nil
+}
+
+func wait(e *entry, err error) (filter.Filter, error) {
+	if err != nil {
+		return nil, err
+	}
+	<-e.ready
+	if e.err != nil {
+		return nil, e.err
+	}
+	return e.f, nil
+}
+
+// Remove closes the filter named name and drops it from the cache, the bitmap of redis is kept.
+func (m *Manager) Remove(name string) {
+	m.mu.Lock()
+	e, ok := m.entries[name]
+	if ok {
+		m.remove(e)
+	}
+	m.mu.Unlock()
+	if ok {
+		<-e.ready
+		closeEntry(e)
+	}
+}
+
+// Len returns the number of cached filters.
+func (m *Manager) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.entries)
+}
+
+// MemoryUsage returns the bytes of local bitmaps of cached filters.
+func (m *Manager) MemoryUsage() uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.used
+}
+
+// Close closes all filters and redis clients created by the manager, subsequent Get returns ErrManagerClosed.
+func (m *Manager) Close() error {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil
+	}
+	m.closed = true
+	var entries []*entry
+	for _, e := range m.entries {
+		entries = append(entries, e)
+	}
+	m.entries = map[string]*entry{}
+	m.lru.Init()
+	m.used = 0
+	clients := m.clients
+	m.clients = nil
+	m.mu.Unlock()
+
+	for _, e := range entries {
+		select {
+		case <-e.ready:
+			closeEntry(e)
+		default:
+			// it's closed by Get once it's created.
+		}
+	}
+	m.cancel()
+	var err error
+	for _, c := range clients {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// evict drops the least recently used in-memory filters until size fits the budget, it's called with mu held.
+// Filters being created are never evicted, so that the budget may be exceeded temporarily.
+// Redis filters are never evicted since closing them cancels their context, which fails callers still holding them.
+func (m *Manager) evict(size uint64) []*entry {
+	if m.budget == 0 {
+		return nil
+	}
+	var evicted []*entry
+	for el := m.lru.Back(); el != nil && m.used+size > m.budget; {
+		e := el.Value.(*entry)
+		el = el.Prev()
+		if e.size == 0 || e.cfg.FilterConfig.BitmapConfig.Type != config.BitmapTypeInMemory {
+			continue
+		}
+		select {
+		case <-e.ready:
+		default:
+			continue
+		}
+		m.remove(e)
+		evicted = append(evicted, e)
+	}
+	return evicted
+}
+
+// remove drops e from the cache, it's called with mu held.
+func (m *Manager) remove(e *entry) {
+	if m.entries[e.name] != e {
+		return
+	}
+	delete(m.entries, e.name)
+	m.lru.Remove(e.elem)
+	m.used -= e.size
+}
+
+// config returns config of the filter named name.
+func (m *Manager) config(name string) (config.FactoryConfig, error) {
+	cfg := m.template
+	if cfg.FilterConfig.BitmapConfig.Type == config.BitmapTypeRedis {
+		cfg.RedisConfig.Key = m.keyFunc(name)
+	}
+	if m.configFunc == nil {
+		return cfg, nil
+	}
+	return m.configFunc(name, cfg)
+}
+
+func (m *Manager) newFilter(cfg config.FactoryConfig) (filter.Filter, context.CancelFunc, error) {
+	var ff factory.FilterFactory
+	var err error
+	if cfg.FilterConfig.BitmapConfig.Type == config.BitmapTypeRedis {
+		client, cerr := m.redisClient(cfg.RedisConfig)
+		if cerr != nil {
+			return nil, nil, cerr
+		}
+		ff, err = factory.NewFilterFactoryWithRedis(cfg, client)
+	} else {
+		ff, err = factory.NewFilterFactory(cfg)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	// filter such as rotator lives until it's closed instead of the caller of Get.
+	ctx, cancel := context.WithCancel(m.ctx)
+	f, err := ff.NewFilter(ctx)
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+	return f, cancel, nil
+}
+
+// redisClient returns the client shared by filters of the same redis address.
+func (m *Manager) redisClient(cfg config.RedisConfig) (*redis.Client, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil, ErrManagerClosed
+	}
+	addr := redisAddr{addr: cfg.Addr, timeout: cfg.Timeout}
+	if c, ok := m.clients[addr]; ok {
+		return c, nil
+	}
+	c := redis.NewClient(&redis.Options{
+		Addr:         cfg.Addr,
+		ReadTimeout:  cfg.Timeout,
+		WriteTimeout: cfg.Timeout,
+	})
+	m.clients[addr] = c
+	return c, nil
+}
+
+// closeEntry stops goroutines of the filter such as rotation and re-syncing of tiered.
+func closeEntry(e *entry) {
+	if e.err != nil || e.f == nil {
+		return
+	}
+	f := e.f
+	for f != nil {
+		if c, ok := f.(io.Closer); ok {
+			_ = c.Close()
+			break
+		}
+		u, ok := f.(interface{ Unwrap() filter.Filter })
+		if !ok {
+			break
+		}
+		f = u.Unwrap()
+	}
+	e.cancel()
+}
+
+// memorySize returns the bytes of local bitmaps of the filter by cfg, i.e. bitmaps of the in-memory filter,
+// or the local copy of tiered and the fallback of config.FailPolicyFallback of the redis filter.
+func memorySize(cfg config.FactoryConfig) uint64 {
+	var copies uint64
+	switch cfg.FilterConfig.BitmapConfig.Type {
+	case config.BitmapTypeInMemory:
+		copies = 1
+	case config.BitmapTypeRedis:
+		if cfg.TieredConfig.Enable {
+			copies++
+		}
+		if cfg.RedisConfig.Resilience.Enable && cfg.RedisConfig.Resilience.FailPolicy == config.FailPolicyFallback {
+			copies++
+		}
+	}
+	// bitmap.InMemory is backed by 64-bit words.
+	size := (cfg.FilterConfig.M + 63) / 64 * 8 * copies
+	if cfg.RotatorConfig.Enable {
+		size *= uint64(cfg.RotatorConfig.Generations())
+	}
+	return size
+}
+
+// ManagerKeyFunc derives redis key of filters by fn, it's the key of the template followed by `:` and name by default.
+func ManagerKeyFunc(fn KeyFunc) ManagerOption {
+	return func(m *Manager) {
+		m.keyFunc = fn
+	}
+}
+
+// ManagerConfigFunc customizes config of filters by fn, see ConfigFunc.
+func ManagerConfigFunc(fn ConfigFunc) ManagerOption {
+	return func(m *Manager) {
+		m.configFunc = fn
+	}
+}
+
+// ManagerMemoryBudget bounds local bitmaps of filters by bytes, 0 means unbounded.
+// Local bitmaps are those of in-memory filters, and the local copy of tiered and the fallback of redis filters.
+// Only in-memory filters are evicted to fit the budget, while redis filters still count towards it.
+func ManagerMemoryBudget(bytes uint64) ManagerOption {
+	return func(m *Manager) {
+		m.budget = bytes
+	}
+}
+
+// NewManager returns Manager which creates filters by template, filters live until ctx is done or they're closed.
+// Template is validated as is, i.e. the redis key of template is required as the base of derived keys.
+func NewManager(ctx context.Context, template config.FactoryConfig, opts ...ManagerOption) (*Manager, error) {
+	if err := template.Validate(); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	m := &Manager{
+		ctx:      ctx,
+		cancel:   cancel,
+		template: template,
+		keyFunc: func(name string) string {
+			return template.RedisConfig.Key + ":" + name
+		},
+		entries: map[string]*entry{},
+		lru:     list.New(),
+		clients: map[redisAddr]*redis.Client{},
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m, nil
+}
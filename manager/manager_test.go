@@ -0,0 +1,417 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/x0rworld/go-bloomfilter/config"
+	"github.com/x0rworld/go-bloomfilter/filter"
+	"github.com/x0rworld/go-bloomfilter/filter/rotator"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func inMemoryTemplate(m uint64) config.FactoryConfig {
+	return config.FactoryConfig{
+		FilterConfig: config.FilterConfig{
+			BitmapConfig: config.BitmapConfig{
+				Type: config.BitmapTypeInMemory,
+			},
+			M: m,
+			K: 3,
+		},
+	}
+}
+
+func TestManager_Get(t *testing.T) {
+	m, err := NewManager(context.Background(), inMemoryTemplate(1024))
+	assert.NoError(t, err)
+	defer m.Close()
+
+	_, err = m.Get("")
+	assert.Error(t, err)
+
+	a, err := m.Get("a")
+	assert.NoError(t, err)
+	assert.NoError(t, a.Add("hello"))
+
+	// cached
+	got, err := m.Get("a")
+	assert.NoError(t, err)
+	assert.Same(t, a, got)
+	exist, err := got.Exist("hello")
+	assert.NoError(t, err)
+	assert.True(t, exist)
+
+	b, err := m.Get("b")
+	assert.NoError(t, err)
+	exist, err = b.Exist("hello")
+	assert.NoError(t, err)
+	assert.False(t, exist)
+	assert.Equal(t, 2, m.Len())
+	assert.Equal(t, uint64(2*128), m.MemoryUsage())
+}
+
+func TestManager_Get_concurrent(t *testing.T) {
+	var created int
+	var mu sync.Mutex
+	m, err := NewManager(context.Background(), inMemoryTemplate(1024),
+		ManagerConfigFunc(func(name string, cfg config.FactoryConfig) (config.FactoryConfig, error) {
+			mu.Lock()
+			created++
+			mu.Unlock()
+			return cfg, nil
+		}),
+	)
+	assert.NoError(t, err)
+	defer m.Close()
+
+	var wg sync.WaitGroup
+	filters := make([]filter.Filter, 10)
+	for i := range filters {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			f, err := m.Get("a")
+			assert.NoError(t, err)
+			filters[i] = f
+		}(i)
+	}
+	wg.Wait()
+	for _, f := range filters {
+		assert.Same(t, filters[0], f)
+	}
+	assert.Equal(t, 1, m.Len())
+	assert.LessOrEqual(t, created, len(filters))
+}
+
+func TestManager_Get_redis(t *testing.T) {
+	mr := miniredis.RunT(t)
+	defer mr.Close()
+
+	template := config.FactoryConfig{
+		FilterConfig: config.FilterConfig{
+			BitmapConfig: config.BitmapConfig{
+				Type: config.BitmapTypeRedis,
+			},
+			M: 1000,
+			K: 3,
+		},
+		RedisConfig: config.RedisConfig{
+			Addr:        mr.Addr(),
+			Timeout:     time.Second,
+			Key:         "test-Manager",
+			Preallocate: true,
+		},
+	}
+	errBlocked := errors.New("blocked")
+	m, err := NewManager(context.Background(), template,
+		ManagerConfigFunc(func(name string, cfg config.FactoryConfig) (config.FactoryConfig, error) {
+			switch name {
+			case "large":
+				cfg.FilterConfig.M = 8000
+			case "blocked":
+				return cfg, errBlocked
+			}
+			return cfg, nil
+		}),
+	)
+	assert.NoError(t, err)
+
+	for _, name := range []string{"a", "large"} {
+		f, err := m.Get(name)
+		assert.NoError(t, err)
+		assert.NoError(t, f.Add("hello"))
+		assert.True(t, mr.Exists("test-Manager:"+name))
+	}
+	// bitmaps are pre-allocated by M of each filter.
+	for name, size := range map[string]int{"a": 125, "large": 1000} {
+		v, err := mr.Get("test-Manager:" + name)
+		assert.NoError(t, err)
+		assert.Equal(t, size, len(v))
+	}
+	assert.Equal(t, 1, len(m.clients))
+	// redis filters are not accounted in memory usage.
+	assert.Equal(t, uint64(0), m.MemoryUsage())
+
+	_, err = m.Get("blocked")
+	assert.ErrorIs(t, err, errBlocked)
+	assert.Equal(t, 2, m.Len())
+
+	assert.NoError(t, m.Close())
+	_, err = m.Get("a")
+	assert.ErrorIs(t, err, ErrManagerClosed)
+	assert.Equal(t, 0, m.Len())
+	// bitmaps are kept after closing.
+	assert.True(t, mr.Exists("test-Manager:a"))
+}
+
+func TestManager_Get_evict(t *testing.T) {
+	// each filter takes 128 bytes, the budget fits 2 of them.
+	m, err := NewManager(context.Background(), inMemoryTemplate(1024), ManagerMemoryBudget(300))
+	assert.NoError(t, err)
+	defer m.Close()
+
+	a, err := m.Get("a")
+	assert.NoError(t, err)
+	assert.NoError(t, a.Add("hello"))
+	_, err = m.Get("b")
+	assert.NoError(t, err)
+	// a is used more recently than b.
+	_, err = m.Get("a")
+	assert.NoError(t, err)
+
+	_, err = m.Get("c")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, m.Len())
+	assert.Equal(t, uint64(256), m.MemoryUsage())
+	_, ok := m.entries["b"]
+	assert.False(t, ok)
+
+	got, err := m.Get("a")
+	assert.NoError(t, err)
+	assert.Same(t, a, got)
+
+	// a single filter larger than budget
+	m, err = NewManager(context.Background(), inMemoryTemplate(4096), ManagerMemoryBudget(300))
+	assert.NoError(t, err)
+	defer m.Close()
+	_, err = m.Get("a")
+	assert.ErrorIs(t, err, ErrOverBudget)
+	assert.Equal(t, 0, m.Len())
+}
+
+func TestManager_Get_evictInUse(t *testing.T) {
+	mr := miniredis.RunT(t)
+	defer mr.Close()
+
+	template := inMemoryTemplate(1024)
+	template.FilterConfig.BitmapConfig.Type = config.BitmapTypeRedis
+	template.RedisConfig = config.RedisConfig{Addr: mr.Addr(), Timeout: time.Second, Key: "test-Manager"}
+	template.TieredConfig = config.TieredConfig{Enable: true, SyncInterval: time.Hour}
+	// each filter takes 128 bytes, the budget fits 2 of them.
+	m, err := NewManager(context.Background(), template, ManagerMemoryBudget(300), ManagerConfigFunc(
+		func(name string, cfg config.FactoryConfig) (config.FactoryConfig, error) {
+			if strings.HasPrefix(name, "mem") {
+				return inMemoryTemplate(1024), nil
+			}
+			return cfg, nil
+		}))
+	assert.NoError(t, err)
+	defer m.Close()
+
+	r, err := m.Get("redis")
+	assert.NoError(t, err)
+	a, err := m.Get("mem-a")
+	assert.NoError(t, err)
+	_, err = m.Get("mem-b")
+	assert.NoError(t, err)
+
+	// the least recently used redis filter is skipped, a is evicted instead
+	_, ok := m.entries["mem-a"]
+	assert.False(t, ok)
+	got, err := m.Get("redis")
+	assert.NoError(t, err)
+	assert.Same(t, r, got)
+
+	// filters held by callers are still usable
+	for _, f := range []filter.Filter{r, a} {
+		assert.NoError(t, f.Add("hello"))
+		exist, err := f.Exist("hello")
+		assert.NoError(t, err)
+		assert.True(t, exist)
+	}
+}
+
+func TestMemorySize(t *testing.T) {
+	redisTemplate := func(tiered bool, policy config.FailPolicy) config.FactoryConfig {
+		cfg := inMemoryTemplate(1024)
+		cfg.FilterConfig.BitmapConfig.Type = config.BitmapTypeRedis
+		cfg.TieredConfig.Enable = tiered
+		cfg.RedisConfig.Resilience = config.ResilienceConfig{Enable: policy != "", FailPolicy: policy}
+		return cfg
+	}
+	rotating := redisTemplate(true, config.FailPolicyFallback)
+	rotating.RotatorConfig = config.RotatorConfig{Enable: true, Mode: config.RotatorModeManual}
+
+	tests := []struct {
+		name string
+		cfg  config.FactoryConfig
+		want uint64
+	}{
+		{name: "in-memory", cfg: inMemoryTemplate(1024), want: 128},
+		{name: "redis", cfg: redisTemplate(false, config.FailPolicyOpen), want: 0},
+		{name: "tiered", cfg: redisTemplate(true, ""), want: 128},
+		{name: "fallback", cfg: redisTemplate(false, config.FailPolicyFallback), want: 128},
+		{name: "tiered with fallback", cfg: redisTemplate(true, config.FailPolicyFallback), want: 256},
+		{name: "rotating", cfg: rotating, want: 512},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, memorySize(tt.cfg))
+		})
+	}
+}
+
+func TestManager_Remove(t *testing.T) {
+	template := inMemoryTemplate(1024)
+	template.RotatorConfig = config.RotatorConfig{
+		Enable: true,
+		Mode:   config.RotatorModeManual,
+	}
+	m, err := NewManager(context.Background(), template)
+	assert.NoError(t, err)
+	defer m.Close()
+
+	a, err := m.Get("a")
+	assert.NoError(t, err)
+	assert.IsType(t, &rotator.Rotator{}, a)
+	assert.NoError(t, a.Add("hello"))
+	// current, next filters
+	assert.Equal(t, uint64(2*128), m.MemoryUsage())
+
+	m.Remove("a")
+	m.Remove("unknown")
+	assert.Equal(t, 0, m.Len())
+	assert.Equal(t, uint64(0), m.MemoryUsage())
+
+	got, err := m.Get("a")
+	assert.NoError(t, err)
+	assert.NotSame(t, a, got)
+	exist, err := got.Exist("hello")
+	assert.NoError(t, err)
+	assert.False(t, exist)
+}
+
+func TestManager_Create(t *testing.T) {
+	m, err := NewManager(context.Background(), inMemoryTemplate(1024))
+	assert.NoError(t, err)
+	defer m.Close()
+
+	f, err := m.Create("a", func(_ string, cfg config.FactoryConfig) (config.FactoryConfig, error) {
+		cfg.FilterConfig.M = 2048
+		return cfg, nil
+	})
+	assert.NoError(t, err)
+	got, err := m.Get("a")
+	assert.NoError(t, err)
+	assert.Same(t, f, got)
+	cfg, ok := m.Config("a")
+	assert.True(t, ok)
+	assert.Equal(t, uint64(2048), cfg.FilterConfig.M)
+	assert.Equal(t, uint64(256), m.MemoryUsage())
+
+	_, err = m.Create("a", func(_ string, cfg config.FactoryConfig) (config.FactoryConfig, error) {
+		return cfg, nil
+	})
+	assert.ErrorIs(t, err, ErrFilterExists)
+	_, ok = m.Config("b")
+	assert.False(t, ok)
+	_, err = m.Create("b", nil)
+	assert.Error(t, err)
+}
+
+func TestManager_Lookup(t *testing.T) {
+	m, err := NewManager(context.Background(), inMemoryTemplate(1024))
+	assert.NoError(t, err)
+	defer m.Close()
+
+	_, err = m.Lookup("a")
+	assert.ErrorIs(t, err, ErrFilterNotFound)
+	assert.Equal(t, 0, m.Len())
+	a, err := m.Get("a")
+	assert.NoError(t, err)
+	got, err := m.Lookup("a")
+	assert.NoError(t, err)
+	assert.Same(t, a, got)
+}
+
+func TestManager_Lookup_redis(t *testing.T) {
+	mr := miniredis.RunT(t)
+	defer mr.Close()
+
+	template := config.FactoryConfig{
+		FilterConfig: config.FilterConfig{
+			BitmapConfig: config.BitmapConfig{
+				Type: config.BitmapTypeRedis,
+			},
+			M: 1000,
+			K: 3,
+		},
+		RedisConfig: config.RedisConfig{
+			Addr:    mr.Addr(),
+			Timeout: time.Second,
+			Key:     "test-Manager_Lookup",
+		},
+	}
+	m, err := NewManager(context.Background(), template)
+	assert.NoError(t, err)
+	_, err = m.Create("a", func(_ string, cfg config.FactoryConfig) (config.FactoryConfig, error) {
+		cfg.FilterConfig.M = 2000
+		return cfg, nil
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, m.Close())
+
+	// the filter existing in redis is found after restart, by M of its metadata
+	m, err = NewManager(context.Background(), template)
+	assert.NoError(t, err)
+	defer m.Close()
+	_, err = m.Lookup("b")
+	assert.ErrorIs(t, err, ErrFilterNotFound)
+	assert.False(t, mr.Exists("test-Manager_Lookup:b"))
+	_, err = m.Lookup("a")
+	assert.NoError(t, err)
+	cfg, ok := m.Config("a")
+	assert.True(t, ok)
+	assert.Equal(t, uint64(2000), cfg.FilterConfig.M)
+}
+
+func TestManager_Get_existing(t *testing.T) {
+	mr := miniredis.RunT(t)
+	defer mr.Close()
+
+	template := config.FactoryConfig{
+		FilterConfig: config.FilterConfig{
+			BitmapConfig: config.BitmapConfig{
+				Type: config.BitmapTypeRedis,
+			},
+			M: 1000,
+			K: 3,
+		},
+		RedisConfig: config.RedisConfig{
+			Addr:    mr.Addr(),
+			Timeout: time.Second,
+			Key:     "test-Manager_Get_existing",
+		},
+	}
+	reserve := func(_ string, cfg config.FactoryConfig) (config.FactoryConfig, error) {
+		cfg.FilterConfig.M, cfg.FilterConfig.K = 2000, 5
+		return cfg, nil
+	}
+	m, err := NewManager(context.Background(), template)
+	assert.NoError(t, err)
+	defer m.Close()
+	f, err := m.Create("a", reserve)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Add("hello"))
+
+	// the filter is re-created by M and K of its metadata instead of the template, e.g. after eviction or restart
+	m.Remove("a")
+	f, err = m.Get("a")
+	assert.NoError(t, err)
+	exist, err := f.Exist("hello")
+	assert.NoError(t, err)
+	assert.True(t, exist)
+	cfg, ok := m.Config("a")
+	assert.True(t, ok)
+	assert.Equal(t, uint64(2000), cfg.FilterConfig.M)
+	assert.Equal(t, uint64(5), cfg.FilterConfig.K)
+
+	m.Remove("a")
+	_, err = m.Create("a", reserve)
+	assert.ErrorIs(t, err, ErrFilterExists)
+}
@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"context"
+	"github.com/x0rworld/go-bloomfilter/bitmap"
+	"github.com/x0rworld/go-bloomfilter/filter"
+	"time"
+)
+
+// Bitmap decorates bitmap.Bitmap to record its operations into Recorder.
+type Bitmap struct {
+	bm  bitmap.Bitmap
+	rec Recorder
+}
+
+func (b *Bitmap) CheckBits(locs []uint64) (bool, error) {
+	start := time.Now()
+	exist, err := b.bm.CheckBits(locs)
+	b.rec.ObserveBitmap(OperationCheckBits, time.Since(start), err)
+	return exist, err
+}
+
+func (b *Bitmap) CheckBitsContext(ctx context.Context, locs []uint64) (bool, error) {
+	start := time.Now()
+	exist, err := bitmap.CheckBitsContext(ctx, b.bm, locs)
+	b.rec.ObserveBitmap(OperationCheckBits, time.Since(start), err)
+	return exist, err
+}
+
+func (b *Bitmap) SetBits(locs []uint64) error {
+	start := time.Now()
+	err := b.bm.SetBits(locs)
+	b.rec.ObserveBitmap(OperationSetBits, time.Since(start), err)
+	return err
+}
+
+func (b *Bitmap) SetBitsContext(ctx context.Context, locs []uint64) error {
+	start := time.Now()
+	err := bitmap.SetBitsContext(ctx, b.bm, locs)
+	b.rec.ObserveBitmap(OperationSetBits, time.Since(start), err)
+	return err
+}
+
+// CountBits delegates to the decorated bitmap, it returns filter.ErrUnsupported if the bitmap is not bitmap.Counter.
+func (b *Bitmap) CountBits() (uint64, error) {
+	c, ok := b.bm.(bitmap.Counter)
+	if !ok {
+		return 0, filter.ErrUnsupported
+	}
+	return c.CountBits()
+}
+
+// Reset delegates to the decorated bitmap, it returns filter.ErrUnsupported if the bitmap is not bitmap.Resetter.
+func (b *Bitmap) Reset() error {
+	rs, ok := b.bm.(bitmap.Resetter)
+	if !ok {
+		return filter.ErrUnsupported
+	}
+	return rs.Reset()
+}
+
+// Bytes delegates to the decorated bitmap, it returns filter.ErrUnsupported if the bitmap is not bitmap.Exporter.
+func (b *Bitmap) Bytes() ([]byte, error) {
+	e, ok := b.bm.(bitmap.Exporter)
+	if !ok {
+		return nil, filter.ErrUnsupported
+	}
+	return e.Bytes()
+}
+
+// Unwrap returns the decorated bitmap.
+func (b *Bitmap) Unwrap() bitmap.Bitmap {
+	return b.bm
+}
+
+// NewBitmap returns Bitmap which decorates bm with rec.
+func NewBitmap(bm bitmap.Bitmap, rec Recorder) *Bitmap {
+	return &Bitmap{
+		bm:  bm,
+		rec: rec,
+	}
+}
@@ -0,0 +1,35 @@
+package metrics
+
+import (
+	"errors"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/x0rworld/go-bloomfilter/filter"
+	"github.com/x0rworld/go-bloomfilter/mock"
+	"testing"
+)
+
+func TestBitmap(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	errPipeline := errors.New("pipeline error")
+	bMap := mock.NewMockBitmap(ctrl)
+	bMap.EXPECT().SetBits([]uint64{1}).Return(nil)
+	bMap.EXPECT().CheckBits([]uint64{1}).Return(false, errPipeline)
+
+	rec := &fakeRecorder{}
+	bm := NewBitmap(bMap, rec)
+	err := bm.SetBits([]uint64{1})
+	assert.NoError(t, err)
+	_, err = bm.CheckBits([]uint64{1})
+	assert.ErrorIs(t, err, errPipeline)
+	assert.Equal(t, []call{
+		{op: OperationSetBits},
+		{op: OperationCheckBits, err: errPipeline},
+	}, rec.bitmap)
+
+	// mock.MockBitmap is not bitmap.Counter
+	_, err = bm.CountBits()
+	assert.ErrorIs(t, err, filter.ErrUnsupported)
+}
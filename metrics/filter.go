@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"context"
+	"github.com/x0rworld/go-bloomfilter/filter"
+	"sync"
+	"time"
+)
+
+// Filter decorates filter.Filter to record its operations into Recorder.
+// If the decorated filter is filter.FillRatioReporter, fill ratio is sampled after Add by FillRatioInterval.
+type Filter struct {
+	f   filter.Filter
+	rec Recorder
+
+	mu            sync.Mutex
+	lastFillRatio time.Time
+}
+
+// Exist calls Exist of the decorated filter, so that it keeps its own context, see filter.ContextFilter.
+func (f *Filter) Exist(data string) (bool, error) {
+	return f.observeExist(func() (bool, error) {
+		return f.f.Exist(data)
+	})
+}
+
+func (f *Filter) ExistContext(ctx context.Context, data string) (bool, error) {
+	return f.observeExist(func() (bool, error) {
+		return filter.ExistContext(ctx, f.f, data)
+	})
+}
+
+// Add calls Add of the decorated filter, so that it keeps its own context, see filter.ContextFilter.
+func (f *Filter) Add(data string) error {
+	return f.observeAdd(func() error {
+		return f.f.Add(data)
+	})
+}
+
+func (f *Filter) AddContext(ctx context.Context, data string) error {
+	return f.observeAdd(func() error {
+		return filter.AddContext(ctx, f.f, data)
+	})
+}
+
+func (f *Filter) observeExist(exist func() (bool, error)) (bool, error) {
+	start := time.Now()
+	ok, err := exist()
+	f.rec.ObserveFilter(OperationExist, time.Since(start), ok, err)
+	return ok, err
+}
+
+func (f *Filter) observeAdd(add func() error) error {
+	start := time.Now()
+	err := add()
+	f.rec.ObserveFilter(OperationAdd, time.Since(start), false, err)
+	if err == nil {
+		f.sampleFillRatio()
+	}
+	return err
+}
+
+// Reset delegates to the decorated filter, it returns filter.ErrUnsupported if the filter is not filter.Resetter.
+func (f *Filter) Reset() error {
+	rs, ok := f.f.(filter.Resetter)
+	if !ok {
+		return filter.ErrUnsupported
+	}
+	return rs.Reset()
+}
+
+// Snapshot delegates to the decorated filter, it returns filter.ErrUnsupported if the filter is not filter.Snapshotter.
+func (f *Filter) Snapshot() ([]byte, error) {
+	s, ok := f.f.(filter.Snapshotter)
+	if !ok {
+		return nil, filter.ErrUnsupported
+	}
+	return s.Snapshot()
+}
+
+// Unwrap returns the decorated filter.
+func (f *Filter) Unwrap() filter.Filter {
+	return f.f
+}
+
+func (f *Filter) sampleFillRatio() {
+	fr, ok := f.f.(filter.FillRatioReporter)
+	if !ok {
+		return
+	}
+	f.mu.Lock()
+	if time.Since(f.lastFillRatio) < FillRatioInterval {
+		f.mu.Unlock()
+		return
+	}
+	f.lastFillRatio = time.Now()
+	f.mu.Unlock()
+
+	ratio, err := fr.FillRatio()
+	if err != nil {
+		return
+	}
+	f.rec.SetFillRatio(ratio)
+}
+
+// NewFilter returns Filter which decorates f with rec.
+func NewFilter(f filter.Filter, rec Recorder) *Filter {
+	return &Filter{
+		f:   f,
+		rec: rec,
+	}
+}
@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/x0rworld/go-bloomfilter/bitmap"
+	"github.com/x0rworld/go-bloomfilter/filter"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	rec := &fakeRecorder{}
+	f := NewFilter(filter.NewBloomFilter(bitmap.NewInMemory(100), 100, 3), rec)
+
+	exist, err := f.Exist("hello")
+	assert.NoError(t, err)
+	assert.False(t, exist)
+	err = f.Add("hello")
+	assert.NoError(t, err)
+	exist, err = f.Exist("hello")
+	assert.NoError(t, err)
+	assert.True(t, exist)
+
+	assert.Equal(t, []call{
+		{op: OperationExist, hit: false},
+		{op: OperationAdd},
+		{op: OperationExist, hit: true},
+	}, rec.filter)
+	// fill ratio is sampled on first Add, then throttled by FillRatioInterval
+	err = f.Add("world")
+	assert.NoError(t, err)
+	assert.Len(t, rec.fillRatios, 1)
+	assert.Equal(t, 0.03, rec.fillRatios[0])
+}
+
+func TestFilter_ctx(t *testing.T) {
+	m := miniredis.RunT(t)
+	defer m.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client := redis.NewClient(&redis.Options{Addr: m.Addr()})
+	bm, err := bitmap.NewRedis(ctx, client, "test-Filter_ctx", 100)
+	assert.NoError(t, err)
+	cancel()
+
+	// the decorated filter keeps its own context without the per-call one
+	rec := &fakeRecorder{}
+	f := NewFilter(filter.NewBloomFilter(bm, 100, 3), rec)
+	_, err = f.Exist("hello")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.ErrorIs(t, f.Add("hello"), context.Canceled)
+	exist, err := f.ExistContext(context.Background(), "hello")
+	assert.NoError(t, err)
+	assert.False(t, exist)
+	assert.NoError(t, f.AddContext(context.Background(), "hello"))
+	assert.Len(t, rec.filter, 4)
+}
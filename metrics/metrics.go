@@ -0,0 +1,40 @@
+// Package metrics instruments filter and bitmap, and records operational metrics into Recorder.
+package metrics
+
+import (
+	"time"
+)
+
+const (
+	OperationAdd       Operation = "add"
+	OperationExist     Operation = "exist"
+	OperationSetBits   Operation = "set_bits"
+	OperationCheckBits Operation = "check_bits"
+)
+
+// FillRatioInterval is the minimum interval to sample fill ratio by Filter, since counting bits might be expensive.
+var FillRatioInterval = time.Minute
+
+type Operation string
+
+type Recorder interface {
+	// ObserveFilter records filter operation, hit is the result of OperationExist.
+	ObserveFilter(op Operation, d time.Duration, hit bool, err error)
+	// ObserveBitmap records bitmap operation such as a pipeline of bitmap.Redis.
+	ObserveBitmap(op Operation, d time.Duration, err error)
+	// ObserveRotation records rotation performed by rotator.Rotator.
+	ObserveRotation(d time.Duration, err error)
+	// SetFillRatio records the ratio of set bits of filter.
+	SetFillRatio(ratio float64)
+}
+
+// NopRecorder discards all metrics.
+type NopRecorder struct{}
+
+func (NopRecorder) ObserveFilter(Operation, time.Duration, bool, error) {}
+
+func (NopRecorder) ObserveBitmap(Operation, time.Duration, error) {}
+
+func (NopRecorder) ObserveRotation(time.Duration, error) {}
+
+func (NopRecorder) SetFillRatio(float64) {}
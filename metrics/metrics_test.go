@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+)
+
+type call struct {
+	op  Operation
+	hit bool
+	err error
+}
+
+// fakeRecorder keeps all observations for assertions.
+type fakeRecorder struct {
+	mu         sync.Mutex
+	filter     []call
+	bitmap     []call
+	rotations  []error
+	fillRatios []float64
+}
+
+func (r *fakeRecorder) ObserveFilter(op Operation, _ time.Duration, hit bool, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.filter = append(r.filter, call{op: op, hit: hit, err: err})
+}
+
+func (r *fakeRecorder) ObserveBitmap(op Operation, _ time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.bitmap = append(r.bitmap, call{op: op, err: err})
+}
+
+func (r *fakeRecorder) ObserveRotation(_ time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rotations = append(r.rotations, err)
+}
+
+func (r *fakeRecorder) SetFillRatio(ratio float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fillRatios = append(r.fillRatios, ratio)
+}
@@ -0,0 +1,134 @@
+// Package prometheus provides metrics.Recorder backed by github.com/prometheus/client_golang.
+package prometheus
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/x0rworld/go-bloomfilter/metrics"
+	"time"
+)
+
+const (
+	resultOK    = "ok"
+	resultHit   = "hit"
+	resultMiss  = "miss"
+	resultError = "error"
+)
+
+// Recorder records metrics into prometheus collectors:
+//   - <namespace>_filter_calls_total{op, result}: result is `hit` or `miss` for exist, `ok` for add, or `error`.
+//     The positive-hit ratio is `hit / (hit + miss)`.
+//   - <namespace>_filter_call_duration_seconds{op}
+//   - <namespace>_bitmap_calls_total{op, result}: result is `ok` or `error`, such as errors of redis pipeline.
+//   - <namespace>_bitmap_call_duration_seconds{op}
+//   - <namespace>_rotations_total{result}: result is `ok` or `error`.
+//   - <namespace>_rotation_duration_seconds
+//   - <namespace>_fill_ratio
+type Recorder struct {
+	filterCalls      *prometheus.CounterVec
+	filterDuration   *prometheus.HistogramVec
+	bitmapCalls      *prometheus.CounterVec
+	bitmapDuration   *prometheus.HistogramVec
+	rotations        *prometheus.CounterVec
+	rotationDuration prometheus.Histogram
+	fillRatio        prometheus.Gauge
+}
+
+func (r *Recorder) ObserveFilter(op metrics.Operation, d time.Duration, hit bool, err error) {
+	result := resultOK
+	switch {
+	case err != nil:
+		result = resultError
+	case op == metrics.OperationExist && hit:
+		result = resultHit
+	case op == metrics.OperationExist:
+		result = resultMiss
+	}
+	r.filterCalls.WithLabelValues(string(op), result).Inc()
+	r.filterDuration.WithLabelValues(string(op)).Observe(d.Seconds())
+}
+
+func (r *Recorder) ObserveBitmap(op metrics.Operation, d time.Duration, err error) {
+	r.bitmapCalls.WithLabelValues(string(op), errResult(err)).Inc()
+	r.bitmapDuration.WithLabelValues(string(op)).Observe(d.Seconds())
+}
+
+func (r *Recorder) ObserveRotation(d time.Duration, err error) {
+	r.rotations.WithLabelValues(errResult(err)).Inc()
+	r.rotationDuration.Observe(d.Seconds())
+}
+
+func (r *Recorder) SetFillRatio(ratio float64) {
+	r.fillRatio.Set(ratio)
+}
+
+func errResult(err error) string {
+	if err != nil {
+		return resultError
+	}
+	return resultOK
+}
+
+// NewRecorder returns Recorder whose collectors are registered to reg with namespace and constLabels,
+// constLabels could be used to distinguish filters such as `{"filter": "users"}`.
+func NewRecorder(reg prometheus.Registerer, namespace string, constLabels prometheus.Labels) (*Recorder, error) {
+	r := &Recorder{
+		filterCalls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Name:        "filter_calls_total",
+			Help:        "Total number of filter calls.",
+			ConstLabels: constLabels,
+		}, []string{"op", "result"}),
+		filterDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Name:        "filter_call_duration_seconds",
+			Help:        "Latency of filter calls.",
+			ConstLabels: constLabels,
+			Buckets:     prometheus.ExponentialBuckets(0.00001, 4, 10),
+		}, []string{"op"}),
+		bitmapCalls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Name:        "bitmap_calls_total",
+			Help:        "Total number of bitmap calls.",
+			ConstLabels: constLabels,
+		}, []string{"op", "result"}),
+		bitmapDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Name:        "bitmap_call_duration_seconds",
+			Help:        "Latency of bitmap calls.",
+			ConstLabels: constLabels,
+			Buckets:     prometheus.ExponentialBuckets(0.00001, 4, 10),
+		}, []string{"op"}),
+		rotations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Name:        "rotations_total",
+			Help:        "Total number of rotations.",
+			ConstLabels: constLabels,
+		}, []string{"result"}),
+		rotationDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Name:        "rotation_duration_seconds",
+			Help:        "Duration of rotations.",
+			ConstLabels: constLabels,
+		}),
+		fillRatio: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Name:        "fill_ratio",
+			Help:        "Ratio of set bits of filter.",
+			ConstLabels: constLabels,
+		}),
+	}
+	for _, c := range []prometheus.Collector{
+		r.filterCalls,
+		r.filterDuration,
+		r.bitmapCalls,
+		r.bitmapDuration,
+		r.rotations,
+		r.rotationDuration,
+		r.fillRatio,
+	} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
@@ -0,0 +1,40 @@
+package prometheus
+
+import (
+	"errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/x0rworld/go-bloomfilter/metrics"
+	"testing"
+	"time"
+)
+
+func TestRecorder(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	r, err := NewRecorder(reg, "bloomfilter", prometheus.Labels{"filter": "test"})
+	assert.NoError(t, err)
+
+	errInternal := errors.New("internal error")
+	r.ObserveFilter(metrics.OperationExist, time.Millisecond, true, nil)
+	r.ObserveFilter(metrics.OperationExist, time.Millisecond, false, nil)
+	r.ObserveFilter(metrics.OperationAdd, time.Millisecond, false, errInternal)
+	r.ObserveBitmap(metrics.OperationSetBits, time.Millisecond, errInternal)
+	r.ObserveRotation(time.Second, nil)
+	r.SetFillRatio(0.5)
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(r.filterCalls.WithLabelValues("exist", "hit")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(r.filterCalls.WithLabelValues("exist", "miss")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(r.filterCalls.WithLabelValues("add", "error")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(r.bitmapCalls.WithLabelValues("set_bits", "error")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(r.rotations.WithLabelValues("ok")))
+	assert.Equal(t, 0.5, testutil.ToFloat64(r.fillRatio))
+
+	n, err := testutil.GatherAndCount(reg)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, n)
+
+	// registering the same collectors again fails
+	_, err = NewRecorder(reg, "bloomfilter", prometheus.Labels{"filter": "test"})
+	assert.Error(t, err)
+}
@@ -5,9 +5,11 @@
 package mock
 
 import (
+	context "context"
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
+	bitmap "github.com/x0rworld/go-bloomfilter/bitmap"
 )
 
 // MockBitmap is a mock of Bitmap interface.
@@ -61,3 +63,227 @@ func (mr *MockBitmapMockRecorder) SetBits(locs interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetBits", reflect.TypeOf((*MockBitmap)(nil).SetBits), locs)
 }
+
+// MockCounter is a mock of Counter interface.
+type MockCounter struct {
+	ctrl     *gomock.Controller
+	recorder *MockCounterMockRecorder
+}
+
+// MockCounterMockRecorder is the mock recorder for MockCounter.
+type MockCounterMockRecorder struct {
+	mock *MockCounter
+}
+
+// NewMockCounter creates a new mock instance.
+func NewMockCounter(ctrl *gomock.Controller) *MockCounter {
+	mock := &MockCounter{ctrl: ctrl}
+	mock.recorder = &MockCounterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCounter) EXPECT() *MockCounterMockRecorder {
+	return m.recorder
+}
+
+// CountBits mocks base method.
+func (m *MockCounter) CountBits() (uint64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountBits")
+	ret0, _ := ret[0].(uint64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountBits indicates an expected call of CountBits.
+func (mr *MockCounterMockRecorder) CountBits() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountBits", reflect.TypeOf((*MockCounter)(nil).CountBits))
+}
+
+// MockResetter is a mock of Resetter interface.
+type MockResetter struct {
+	ctrl     *gomock.Controller
+	recorder *MockResetterMockRecorder
+}
+
+// MockResetterMockRecorder is the mock recorder for MockResetter.
+type MockResetterMockRecorder struct {
+	mock *MockResetter
+}
+
+// NewMockResetter creates a new mock instance.
+func NewMockResetter(ctrl *gomock.Controller) *MockResetter {
+	mock := &MockResetter{ctrl: ctrl}
+	mock.recorder = &MockResetterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockResetter) EXPECT() *MockResetterMockRecorder {
+	return m.recorder
+}
+
+// Reset mocks base method.
+func (m *MockResetter) Reset() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Reset")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Reset indicates an expected call of Reset.
+func (mr *MockResetterMockRecorder) Reset() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reset", reflect.TypeOf((*MockResetter)(nil).Reset))
+}
+
+// MockExporter is a mock of Exporter interface.
+type MockExporter struct {
+	ctrl     *gomock.Controller
+	recorder *MockExporterMockRecorder
+}
+
+// MockExporterMockRecorder is the mock recorder for MockExporter.
+type MockExporterMockRecorder struct {
+	mock *MockExporter
+}
+
+// NewMockExporter creates a new mock instance.
+func NewMockExporter(ctrl *gomock.Controller) *MockExporter {
+	mock := &MockExporter{ctrl: ctrl}
+	mock.recorder = &MockExporterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockExporter) EXPECT() *MockExporterMockRecorder {
+	return m.recorder
+}
+
+// Bytes mocks base method.
+func (m *MockExporter) Bytes() ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Bytes")
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Bytes indicates an expected call of Bytes.
+func (mr *MockExporterMockRecorder) Bytes() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Bytes", reflect.TypeOf((*MockExporter)(nil).Bytes))
+}
+
+// MockCombiner is a mock of Combiner interface.
+type MockCombiner struct {
+	ctrl     *gomock.Controller
+	recorder *MockCombinerMockRecorder
+}
+
+// MockCombinerMockRecorder is the mock recorder for MockCombiner.
+type MockCombinerMockRecorder struct {
+	mock *MockCombiner
+}
+
+// NewMockCombiner creates a new mock instance.
+func NewMockCombiner(ctrl *gomock.Controller) *MockCombiner {
+	mock := &MockCombiner{ctrl: ctrl}
+	mock.recorder = &MockCombinerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCombiner) EXPECT() *MockCombinerMockRecorder {
+	return m.recorder
+}
+
+// Intersect mocks base method.
+func (m *MockCombiner) Intersect(srcs ...bitmap.Bitmap) error {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{}
+	for _, a := range srcs {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Intersect", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Intersect indicates an expected call of Intersect.
+func (mr *MockCombinerMockRecorder) Intersect(srcs ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Intersect", reflect.TypeOf((*MockCombiner)(nil).Intersect), srcs...)
+}
+
+// Union mocks base method.
+func (m *MockCombiner) Union(srcs ...bitmap.Bitmap) error {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{}
+	for _, a := range srcs {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Union", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Union indicates an expected call of Union.
+func (mr *MockCombinerMockRecorder) Union(srcs ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Union", reflect.TypeOf((*MockCombiner)(nil).Union), srcs...)
+}
+
+// MockContextBitmap is a mock of ContextBitmap interface.
+type MockContextBitmap struct {
+	ctrl     *gomock.Controller
+	recorder *MockContextBitmapMockRecorder
+}
+
+// MockContextBitmapMockRecorder is the mock recorder for MockContextBitmap.
+type MockContextBitmapMockRecorder struct {
+	mock *MockContextBitmap
+}
+
+// NewMockContextBitmap creates a new mock instance.
+func NewMockContextBitmap(ctrl *gomock.Controller) *MockContextBitmap {
+	mock := &MockContextBitmap{ctrl: ctrl}
+	mock.recorder = &MockContextBitmapMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockContextBitmap) EXPECT() *MockContextBitmapMockRecorder {
+	return m.recorder
+}
+
+// CheckBitsContext mocks base method.
+func (m *MockContextBitmap) CheckBitsContext(ctx context.Context, locs []uint64) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckBitsContext", ctx, locs)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CheckBitsContext indicates an expected call of CheckBitsContext.
+func (mr *MockContextBitmapMockRecorder) CheckBitsContext(ctx, locs interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckBitsContext", reflect.TypeOf((*MockContextBitmap)(nil).CheckBitsContext), ctx, locs)
+}
+
+// SetBitsContext mocks base method.
+func (m *MockContextBitmap) SetBitsContext(ctx context.Context, locs []uint64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetBitsContext", ctx, locs)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetBitsContext indicates an expected call of SetBitsContext.
+func (mr *MockContextBitmapMockRecorder) SetBitsContext(ctx, locs interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetBitsContext", reflect.TypeOf((*MockContextBitmap)(nil).SetBitsContext), ctx, locs)
+}
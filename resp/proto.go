@@ -0,0 +1,130 @@
+package resp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxBulkBytes limits a single argument of a command.
+	maxBulkBytes = 16 << 20
+	// maxArgs limits the number of arguments of a command.
+	maxArgs = 1 << 20
+)
+
+// errProtocol is returned by reader if the client doesn't speak RESP, the connection is closed after replying it.
+var errProtocol = errors.New("protocol error")
+
+// reader reads commands as arrays of bulk strings, or inline commands separated by spaces.
+type reader struct {
+	r *bufio.Reader
+}
+
+func (r *reader) readCommand() ([][]byte, error) {
+	line, err := r.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, nil
+	}
+	if line[0] != '*' {
+		return bytes.Fields(line), nil
+	}
+	n, err := parseLen(line[1:], maxArgs)
+	if err != nil {
+		return nil, err
+	}
+	var args [][]byte
+	for i := 0; i < n; i++ {
+		line, err := r.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 || line[0] != '$' {
+			return nil, fmt.Errorf("%w: expected '$', got %q", errProtocol, line)
+		}
+		size, err := parseLen(line[1:], maxBulkBytes)
+		if err != nil {
+			return nil, err
+		}
+		arg := make([]byte, size+2)
+		if _, err := io.ReadFull(r.r, arg); err != nil {
+			return nil, err
+		}
+		if !bytes.HasSuffix(arg, []byte("\r\n")) {
+			return nil, fmt.Errorf("%w: bulk string is not terminated by CRLF", errProtocol)
+		}
+		args = append(args, arg[:size])
+	}
+	return args, nil
+}
+
+// readLine returns a line without the trailing CRLF.
+func (r *reader) readLine() ([]byte, error) {
+	line, err := r.r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		return nil, fmt.Errorf("%w: too long line", errProtocol)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(line, "\r\n"), nil
+}
+
+func parseLen(b []byte, max int) (int, error) {
+	n, err := strconv.Atoi(string(b))
+	if err != nil || n < 0 || n > max {
+		return 0, fmt.Errorf("%w: invalid length %q", errProtocol, b)
+	}
+	return n, nil
+}
+
+// writer writes replies of RESP2.
+type writer struct {
+	w *bufio.Writer
+}
+
+func (w *writer) writeString(s string) {
+	w.w.WriteString("+" + s + "\r\n")
+}
+
+func (w *writer) writeError(err error) {
+	w.w.WriteString("-" + errorString(err) + "\r\n")
+}
+
+func (w *writer) writeInt(n int64) {
+	w.w.WriteString(":" + strconv.FormatInt(n, 10) + "\r\n")
+}
+
+func (w *writer) writeBool(b bool) {
+	if b {
+		w.writeInt(1)
+	} else {
+		w.writeInt(0)
+	}
+}
+
+func (w *writer) writeBulk(b []byte) {
+	w.w.WriteString("$" + strconv.Itoa(len(b)) + "\r\n")
+	w.w.Write(b)
+	w.w.WriteString("\r\n")
+}
+
+func (w *writer) writeNil() {
+	w.w.WriteString("$-1\r\n")
+}
+
+func (w *writer) writeArrayLen(n int) {
+	w.w.WriteString("*" + strconv.Itoa(n) + "\r\n")
+}
+
+// errorString prefixes ERR to the message, newlines are not allowed in RESP errors.
+func errorString(err error) string {
+	return "ERR " + strings.NewReplacer("\r", " ", "\n", " ").Replace(err.Error())
+}
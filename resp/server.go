@@ -0,0 +1,399 @@
+// Package resp serves filters of manager.Manager by RESP, the protocol of redis, speaking the commands of RedisBloom:
+//
+//	BF.RESERVE key error_rate capacity [NONSCALING]
+//	BF.ADD key item
+//	BF.MADD key item [item ...]
+//	BF.EXISTS key item
+//	BF.MEXISTS key item [item ...]
+//	BF.INFO key [CAPACITY | SIZE | FILTERS | ITEMS | EXPANSION]
+//
+// The key is the name of the filter in manager.Manager, filters are created by the template config on the first add
+// unless they're reserved by BF.RESERVE. BF.EXISTS and BF.MEXISTS reply 0 and BF.INFO replies an error for missing keys
+// without creating them, see manager.Manager.Lookup. Filters never scale, and they're rotated if the config enables rotator.
+package resp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/bits-and-blooms/bloom/v3"
+	"github.com/x0rworld/go-bloomfilter/config"
+	"github.com/x0rworld/go-bloomfilter/filter"
+	"github.com/x0rworld/go-bloomfilter/manager"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrServerClosed is returned by Server.Serve after Server.Close.
+var ErrServerClosed = errors.New("resp: server closed")
+
+// command is executed by Server, args exclude the name of the command.
+type command struct {
+	// minArgs and maxArgs bound the number of args, maxArgs < 0 means unbounded.
+	minArgs int
+	maxArgs int
+	exec    func(s *Server, w *writer, args [][]byte) error
+}
+
+var commands = map[string]command{
+	"PING":       {minArgs: 0, maxArgs: 1, exec: (*Server).ping},
+	"QUIT":       {minArgs: 0, maxArgs: 0, exec: (*Server).quit},
+	"BF.RESERVE": {minArgs: 3, maxArgs: 4, exec: (*Server).reserve},
+	"BF.ADD":     {minArgs: 2, maxArgs: 2, exec: (*Server).add},
+	"BF.MADD":    {minArgs: 2, maxArgs: -1, exec: (*Server).madd},
+	"BF.EXISTS":  {minArgs: 2, maxArgs: 2, exec: (*Server).exists},
+	"BF.MEXISTS": {minArgs: 2, maxArgs: -1, exec: (*Server).mexists},
+	"BF.INFO":    {minArgs: 1, maxArgs: 2, exec: (*Server).info},
+}
+
+// Server serves connections accepted by Serve until Close.
+type Server struct {
+	manager *manager.Manager
+	ctx     context.Context
+	cancel  context.CancelFunc
+
+	mu        sync.Mutex
+	closed    bool
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	wg        sync.WaitGroup
+}
+
+// Serve accepts connections on lis and serves each of them by a goroutine, it returns ErrServerClosed after Close.
+func (s *Server) Serve(lis net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		_ = lis.Close()
+		return ErrServerClosed
+	}
+	s.listeners[lis] = struct{}{}
+	s.mu.Unlock()
+
+	for {
+		conn, err := lis.Accept()
+		if err != nil {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			delete(s.listeners, lis)
+			if s.closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			_ = conn.Close()
+			return ErrServerClosed
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+		go s.serveConn(conn)
+	}
+}
+
+// Close closes all listeners and connections, then waits for the executing commands.
+// Filters are not closed, they're owned by manager.Manager.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.cancel()
+	var err error
+	for lis := range s.listeners {
+		if e := lis.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer func() {
+		_ = conn.Close()
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		s.wg.Done()
+	}()
+
+	r := &reader{r: bufio.NewReader(conn)}
+	w := &writer{w: bufio.NewWriter(conn)}
+	for {
+		args, err := r.readCommand()
+		if err != nil {
+			if errors.Is(err, errProtocol) {
+				w.writeError(err)
+				_ = w.w.Flush()
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		quit := s.exec(w, args)
+		// replies of pipelined commands are flushed together.
+		if quit || r.r.Buffered() == 0 {
+			if err := w.w.Flush(); err != nil {
+				return
+			}
+		}
+		if quit {
+			return
+		}
+	}
+}
+
+// exec executes args and writes the reply, it returns whether the connection should be closed.
+func (s *Server) exec(w *writer, args [][]byte) bool {
+	name := strings.ToUpper(string(args[0]))
+	cmd, ok := commands[name]
+	if !ok {
+		w.writeError(fmt.Errorf("unknown command '%s'", args[0]))
+		return false
+	}
+	if n := len(args) - 1; n < cmd.minArgs || (cmd.maxArgs >= 0 && n > cmd.maxArgs) {
+		w.writeError(fmt.Errorf("wrong number of arguments for '%s' command", strings.ToLower(name)))
+		return false
+	}
+	if err := cmd.exec(s, w, args[1:]); err != nil {
+		w.writeError(err)
+	}
+	return name == "QUIT"
+}
+
+func (s *Server) ping(w *writer, args [][]byte) error {
+	if len(args) == 1 {
+		w.writeBulk(args[0])
+	} else {
+		w.writeString("PONG")
+	}
+	return nil
+}
+
+func (s *Server) quit(w *writer, _ [][]byte) error {
+	w.writeString("OK")
+	return nil
+}
+
+// reserve creates the filter of M and K estimated by the capacity and the error rate.
+func (s *Server) reserve(w *writer, args [][]byte) error {
+	fp, err := strconv.ParseFloat(string(args[1]), 64)
+	if err != nil || fp <= 0 || fp >= 1 {
+		return errors.New("(0 < error rate range < 1)")
+	}
+	capacity, err := strconv.ParseUint(string(args[2]), 10, 32)
+	if err != nil || capacity == 0 {
+		return errors.New("(capacity should be larger than 0)")
+	}
+	if len(args) == 4 && !strings.EqualFold(string(args[3]), "NONSCALING") {
+		return errors.New("scaling is not supported, only NONSCALING is allowed")
+	}
+	m, k := bloom.EstimateParameters(uint(capacity), fp)
+	_, err = s.manager.Create(string(args[0]), func(_ string, cfg config.FactoryConfig) (config.FactoryConfig, error) {
+		cfg.FilterConfig.M, cfg.FilterConfig.K = uint64(m), uint64(k)
+		return cfg, cfg.Validate()
+	})
+	if errors.Is(err, manager.ErrFilterExists) {
+		return errors.New("item exists")
+	}
+	if err != nil {
+		return err
+	}
+	w.writeString("OK")
+	return nil
+}
+
+// add replies whether item is newly added, it's checked before adding thus not atomic for concurrent commands.
+func (s *Server) add(w *writer, args [][]byte) error {
+	f, err := s.manager.Get(string(args[0]))
+	if err != nil {
+		return err
+	}
+	added, err := s.addItem(f, args[1])
+	if err != nil {
+		return err
+	}
+	w.writeBool(added)
+	return nil
+}
+
+// madd replies an array in the order of items, each element is either whether the item is newly added or the error.
+func (s *Server) madd(w *writer, args [][]byte) error {
+	f, err := s.manager.Get(string(args[0]))
+	if err != nil {
+		return err
+	}
+	w.writeArrayLen(len(args) - 1)
+	for _, item := range args[1:] {
+		added, err := s.addItem(f, item)
+		if err != nil {
+			w.writeError(err)
+			continue
+		}
+		w.writeBool(added)
+	}
+	return nil
+}
+
+func (s *Server) addItem(f filter.Filter, item []byte) (bool, error) {
+	exist, err := filter.ExistContext(s.ctx, f, string(item))
+	if err != nil {
+		return false, err
+	}
+	if exist {
+		return false, nil
+	}
+	return true, filter.AddContext(s.ctx, f, string(item))
+}
+
+func (s *Server) exists(w *writer, args [][]byte) error {
+	f, err := s.manager.Lookup(string(args[0]))
+	if errors.Is(err, manager.ErrFilterNotFound) {
+		w.writeBool(false)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	exist, err := filter.ExistContext(s.ctx, f, string(args[1]))
+	if err != nil {
+		return err
+	}
+	w.writeBool(exist)
+	return nil
+}
+
+// mexists replies an array in the order of items, each element is either whether the item exists or the error.
+func (s *Server) mexists(w *writer, args [][]byte) error {
+	f, err := s.manager.Lookup(string(args[0]))
+	if errors.Is(err, manager.ErrFilterNotFound) {
+		w.writeArrayLen(len(args) - 1)
+		for range args[1:] {
+			w.writeBool(false)
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	w.writeArrayLen(len(args) - 1)
+	for _, item := range args[1:] {
+		exist, err := filter.ExistContext(s.ctx, f, string(item))
+		if err != nil {
+			w.writeError(err)
+			continue
+		}
+		w.writeBool(exist)
+	}
+	return nil
+}
+
+// info replies fields of the filter as pairs of name and value, or the value of the given field in an array.
+// Capacity is estimated by M and K, and number of items inserted is estimated by fill ratio,
+// which is nil if the filter doesn't report fill ratio. Expansion rate is always nil since filters don't scale.
+func (s *Server) info(w *writer, args [][]byte) error {
+	name := string(args[0])
+	f, err := s.manager.Lookup(name)
+	if errors.Is(err, manager.ErrFilterNotFound) {
+		return errors.New("not found")
+	}
+	if err != nil {
+		return err
+	}
+	cfg, ok := s.manager.Config(name)
+	if !ok {
+		return errors.New("not found")
+	}
+	m, k := float64(cfg.FilterConfig.M), float64(cfg.FilterConfig.K)
+	generations := 1
+	if cfg.RotatorConfig.Enable {
+		generations = cfg.RotatorConfig.Generations()
+	}
+	items := func() {
+		ratio, err := fillRatio(f)
+		if errors.Is(err, filter.ErrUnsupported) {
+			w.writeNil()
+			return
+		}
+		if err != nil {
+			w.writeError(err)
+			return
+		}
+		if ratio >= 1 {
+			// saturated, the estimation is infinite.
+			w.writeInt(int64(m))
+			return
+		}
+		w.writeInt(int64(math.Round(-m / k * math.Log(1-ratio))))
+	}
+	fields := []struct {
+		name  string
+		arg   string
+		write func()
+	}{
+		{"Capacity", "CAPACITY", func() { w.writeInt(int64(m * math.Ln2 / k)) }},
+		{"Size", "SIZE", func() { w.writeInt(int64(cfg.FilterConfig.M+7) / 8 * int64(generations)) }},
+		{"Number of filters", "FILTERS", func() { w.writeInt(int64(generations)) }},
+		{"Number of items inserted", "ITEMS", items},
+		{"Expansion rate", "EXPANSION", w.writeNil},
+	}
+
+	if len(args) == 2 {
+		for _, field := range fields {
+			if strings.EqualFold(string(args[1]), field.arg) {
+				w.writeArrayLen(1)
+				field.write()
+				return nil
+			}
+		}
+		return errors.New("invalid information value")
+	}
+	w.writeArrayLen(len(fields) * 2)
+	for _, field := range fields {
+		w.writeString(field.name)
+		field.write()
+	}
+	return nil
+}
+
+// fillRatio returns fill ratio of f or the filter decorated by it, or filter.ErrUnsupported if none of them reports it.
+func fillRatio(f filter.Filter) (float64, error) {
+	for f != nil {
+		if fr, ok := f.(filter.FillRatioReporter); ok {
+			return fr.FillRatio()
+		}
+		u, ok := f.(interface{ Unwrap() filter.Filter })
+		if !ok {
+			break
+		}
+		f = u.Unwrap()
+	}
+	return 0, filter.ErrUnsupported
+}
+
+// NewServer returns Server serving filters of m, the caller closes m after closing the Server.
+func NewServer(m *manager.Manager) *Server {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Server{
+		manager:   m,
+		ctx:       ctx,
+		cancel:    cancel,
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[net.Conn]struct{}),
+	}
+}
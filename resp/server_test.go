@@ -0,0 +1,263 @@
+package resp
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/x0rworld/go-bloomfilter/config"
+	"github.com/x0rworld/go-bloomfilter/manager"
+	"net"
+	"testing"
+	"time"
+)
+
+func newTestClient(t *testing.T, cfg config.FactoryConfig) *redis.Client {
+	m, err := manager.NewManager(context.Background(), cfg)
+	assert.NoError(t, err)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	s := NewServer(m)
+	go func() {
+		_ = s.Serve(lis)
+	}()
+	client := redis.NewClient(&redis.Options{Addr: lis.Addr().String()})
+	t.Cleanup(func() {
+		_ = client.Close()
+		_ = s.Close()
+		_ = m.Close()
+	})
+	return client
+}
+
+func TestServer(t *testing.T) {
+	client := newTestClient(t, config.FactoryConfig{
+		FilterConfig: config.FilterConfig{
+			BitmapConfig: config.BitmapConfig{
+				Type: config.BitmapTypeInMemory,
+			},
+			M: 1000,
+			K: 3,
+		},
+	})
+	ctx := context.Background()
+
+	assert.NoError(t, client.Ping(ctx).Err())
+
+	added, err := client.Do(ctx, "BF.ADD", "a", "hello").Bool()
+	assert.NoError(t, err)
+	assert.True(t, added)
+	added, err = client.Do(ctx, "BF.ADD", "a", "hello").Bool()
+	assert.NoError(t, err)
+	assert.False(t, added)
+	exist, err := client.Do(ctx, "BF.EXISTS", "a", "hello").Bool()
+	assert.NoError(t, err)
+	assert.True(t, exist)
+	// filters are isolated by key
+	exist, err = client.Do(ctx, "BF.EXISTS", "b", "hello").Bool()
+	assert.NoError(t, err)
+	assert.False(t, exist)
+
+	adds, err := client.Do(ctx, "BF.MADD", "a", "x", "hello", "y").Int64Slice()
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 0, 1}, adds)
+	exists, err := client.Do(ctx, "BF.MEXISTS", "a", "x", "z", "y").Int64Slice()
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 0, 1}, exists)
+
+	info, err := client.Do(ctx, "BF.INFO", "a").Slice()
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{
+		"Capacity", int64(231),
+		"Size", int64(125),
+		"Number of filters", int64(1),
+		"Number of items inserted", int64(3),
+		"Expansion rate", nil,
+	}, info)
+	items, err := client.Do(ctx, "BF.INFO", "a", "items").Int64Slice()
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{3}, items)
+}
+
+func TestServer_reserve(t *testing.T) {
+	client := newTestClient(t, config.FactoryConfig{
+		FilterConfig: config.FilterConfig{
+			BitmapConfig: config.BitmapConfig{
+				Type: config.BitmapTypeInMemory,
+			},
+			M: 1000,
+			K: 3,
+		},
+	})
+	ctx := context.Background()
+
+	assert.NoError(t, client.Do(ctx, "BF.RESERVE", "a", "0.01", "10000", "NONSCALING").Err())
+	err := client.Do(ctx, "BF.RESERVE", "a", "0.01", "10000").Err()
+	assert.EqualError(t, err, "ERR item exists")
+	// missing keys are not created by read commands
+	exist, err := client.Do(ctx, "BF.EXISTS", "b", "hello").Bool()
+	assert.NoError(t, err)
+	assert.False(t, exist)
+	exists, err := client.Do(ctx, "BF.MEXISTS", "b", "hello", "world").Int64Slice()
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{0, 0}, exists)
+	err = client.Do(ctx, "BF.INFO", "b").Err()
+	assert.EqualError(t, err, "ERR not found")
+	assert.NoError(t, client.Do(ctx, "BF.RESERVE", "c", "0.01", "100").Err())
+
+	// created by the template config on first add
+	assert.NoError(t, client.Do(ctx, "BF.ADD", "b", "hello").Err())
+	err = client.Do(ctx, "BF.RESERVE", "b", "0.01", "10000").Err()
+	assert.EqualError(t, err, "ERR item exists")
+
+	info, err := client.Do(ctx, "BF.INFO", "a", "SIZE").Int64Slice()
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{11982}, info)
+	capacity, err := client.Do(ctx, "BF.INFO", "a", "CAPACITY").Int64Slice()
+	assert.NoError(t, err)
+	assert.InDelta(t, 10000, capacity[0], 1000)
+}
+
+func TestServer_invalid(t *testing.T) {
+	client := newTestClient(t, config.NewDefaultFactoryConfig())
+	ctx := context.Background()
+	assert.NoError(t, client.Do(ctx, "BF.ADD", "a", "x").Err())
+
+	tests := []struct {
+		name string
+		args []interface{}
+		want string
+	}{
+		{name: "unknown command", args: []interface{}{"BF.SCANDUMP", "a", "0"}, want: "ERR unknown command 'BF.SCANDUMP'"},
+		{name: "arity", args: []interface{}{"BF.ADD", "a"}, want: "ERR wrong number of arguments for 'bf.add' command"},
+		{name: "arity of multiple items", args: []interface{}{"bf.mexists", "a"}, want: "ERR wrong number of arguments for 'bf.mexists' command"},
+		{name: "error rate", args: []interface{}{"BF.RESERVE", "a", "1", "100"}, want: "ERR (0 < error rate range < 1)"},
+		{name: "capacity", args: []interface{}{"BF.RESERVE", "a", "0.01", "0"}, want: "ERR (capacity should be larger than 0)"},
+		{name: "scaling", args: []interface{}{"BF.RESERVE", "a", "0.01", "100", "EXPANSION"}, want: "ERR scaling is not supported, only NONSCALING is allowed"},
+		{name: "info value", args: []interface{}{"BF.INFO", "a", "NAME"}, want: "ERR invalid information value"},
+		{name: "empty key", args: []interface{}{"BF.ADD", "", "x"}, want: "ERR empty name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.EqualError(t, client.Do(ctx, tt.args...).Err(), tt.want)
+		})
+	}
+	// the connection is still usable
+	assert.NoError(t, client.Ping(ctx).Err())
+}
+
+func TestServer_redis(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := newTestClient(t, config.FactoryConfig{
+		FilterConfig: config.FilterConfig{
+			BitmapConfig: config.BitmapConfig{
+				Type: config.BitmapTypeRedis,
+			},
+			M: 1000,
+			K: 3,
+		},
+		RedisConfig: config.RedisConfig{
+			Addr:    mr.Addr(),
+			Timeout: time.Second,
+			Key:     "test-resp",
+		},
+		RotatorConfig: config.RotatorConfig{
+			Enable: true,
+			Mode:   config.RotatorModeDefault,
+			Freq:   time.Hour,
+		},
+	})
+	ctx := context.Background()
+
+	// pipelined commands are replied in order
+	cmds, err := client.Pipelined(ctx, func(p redis.Pipeliner) error {
+		p.Do(ctx, "BF.MADD", "a", "x", "y")
+		p.Do(ctx, "BF.EXISTS", "a", "x")
+		p.Do(ctx, "BF.EXISTS", "a", "z")
+		return nil
+	})
+	assert.NoError(t, err)
+	if assert.Len(t, cmds, 3) {
+		assert.Equal(t, []interface{}{int64(1), int64(1)}, cmds[0].(*redis.Cmd).Val())
+		assert.Equal(t, int64(1), cmds[1].(*redis.Cmd).Val())
+		assert.Equal(t, int64(0), cmds[2].(*redis.Cmd).Val())
+	}
+	assert.NotEmpty(t, mr.Keys())
+
+	filters, err := client.Do(ctx, "BF.INFO", "a", "FILTERS").Int64Slice()
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{2}, filters)
+}
+
+func TestServer_Close(t *testing.T) {
+	m, err := manager.NewManager(context.Background(), config.NewDefaultFactoryConfig())
+	assert.NoError(t, err)
+	defer m.Close()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	s := NewServer(m)
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Serve(lis)
+	}()
+
+	conn, err := net.Dial("tcp", lis.Addr().String())
+	assert.NoError(t, err)
+	defer conn.Close()
+	// inline command
+	_, err = conn.Write([]byte("PING\r\n"))
+	assert.NoError(t, err)
+	buf := make([]byte, 7)
+	_, err = conn.Read(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "+PONG\r\n", string(buf))
+
+	assert.NoError(t, s.Close())
+	assert.ErrorIs(t, <-done, ErrServerClosed)
+	// the connection is closed
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = conn.Read(buf)
+	assert.Error(t, err)
+	assert.ErrorIs(t, s.Serve(lis), ErrServerClosed)
+}
+
+func TestServer_reserve_redis(t *testing.T) {
+	mr := miniredis.RunT(t)
+	cfg := config.FactoryConfig{
+		FilterConfig: config.FilterConfig{
+			BitmapConfig: config.BitmapConfig{
+				Type: config.BitmapTypeRedis,
+			},
+			M: 1000,
+			K: 3,
+		},
+		RedisConfig: config.RedisConfig{
+			Addr:    mr.Addr(),
+			Timeout: time.Second,
+			Key:     "test-resp",
+		},
+	}
+	ctx := context.Background()
+	client := newTestClient(t, cfg)
+	assert.NoError(t, client.Do(ctx, "BF.RESERVE", "a", "0.01", "10000").Err())
+	assert.NoError(t, client.Do(ctx, "BF.ADD", "a", "hello").Err())
+
+	// the reserved filter keeps its M and K after restart
+	client = newTestClient(t, cfg)
+	err := client.Do(ctx, "BF.RESERVE", "a", "0.01", "10000").Err()
+	assert.EqualError(t, err, "ERR item exists")
+	exist, err := client.Do(ctx, "BF.EXISTS", "a", "hello").Bool()
+	assert.NoError(t, err)
+	assert.True(t, exist)
+	size, err := client.Do(ctx, "BF.INFO", "a", "SIZE").Int64Slice()
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{11982}, size)
+
+	client = newTestClient(t, cfg)
+	added, err := client.Do(ctx, "BF.ADD", "a", "world").Bool()
+	assert.NoError(t, err)
+	assert.True(t, added)
+	exist, err = client.Do(ctx, "BF.EXISTS", "a", "hello").Bool()
+	assert.NoError(t, err)
+	assert.True(t, exist)
+}
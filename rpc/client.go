@@ -0,0 +1,112 @@
+package rpc
+
+import (
+	"context"
+	"github.com/x0rworld/go-bloomfilter/filter"
+	"github.com/x0rworld/go-bloomfilter/rpc/pb"
+	"google.golang.org/grpc"
+	"io"
+)
+
+// Client is a filter.Filter backed by the filter named name of the remote Server.
+type Client struct {
+	client pb.BloomFilterClient
+	name   string
+}
+
+func (c *Client) Exist(data string) (bool, error) {
+	return c.ExistContext(context.Background(), data)
+}
+
+func (c *Client) ExistContext(ctx context.Context, data string) (bool, error) {
+	resp, err := c.client.Exist(ctx, &pb.ExistRequest{Name: c.name, Data: []byte(data)})
+	if err != nil {
+		return false, err
+	}
+	return resp.GetExist(), nil
+}
+
+func (c *Client) Add(data string) error {
+	return c.AddContext(context.Background(), data)
+}
+
+func (c *Client) AddContext(ctx context.Context, data string) error {
+	_, err := c.client.Add(ctx, &pb.AddRequest{Name: c.name, Data: []byte(data)})
+	return err
+}
+
+// BulkAdd adds all of data by a single stream.
+func (c *Client) BulkAdd(ctx context.Context, data []string) error {
+	stream, err := c.client.BulkAdd(ctx)
+	if err != nil {
+		return err
+	}
+	for _, d := range data {
+		if err := stream.Send(&pb.AddRequest{Name: c.name, Data: []byte(d)}); err != nil {
+			// the cause is returned by CloseAndRecv.
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+	}
+	_, err = stream.CloseAndRecv()
+	return err
+}
+
+// BulkExist returns whether each of data exists by a single stream, in the order of data.
+func (c *Client) BulkExist(ctx context.Context, data []string) ([]bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := c.client.BulkExist(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sendErr := make(chan error, 1)
+	go func() {
+		for _, d := range data {
+			if err := stream.Send(&pb.ExistRequest{Name: c.name, Data: []byte(d)}); err != nil {
+				// the cause is returned by Recv.
+				if err == io.EOF {
+					err = nil
+				}
+				sendErr <- err
+				return
+			}
+		}
+		sendErr <- stream.CloseSend()
+	}()
+
+	exist := make([]bool, 0, len(data))
+	for range data {
+		resp, err := stream.Recv()
+		if err != nil {
+			return nil, err
+		}
+		exist = append(exist, resp.GetExist())
+	}
+	if err := <-sendErr; err != nil {
+		return nil, err
+	}
+	return exist, nil
+}
+
+// FillRatio returns fill ratio of the remote filter, it returns filter.ErrUnsupported if the filter doesn't support it.
+func (c *Client) FillRatio() (float64, error) {
+	resp, err := c.client.Stats(context.Background(), &pb.StatsRequest{Name: c.name})
+	if err != nil {
+		return 0, err
+	}
+	if resp.FillRatio == nil {
+		return 0, filter.ErrUnsupported
+	}
+	return resp.GetFillRatio(), nil
+}
+
+// NewClient returns Client of the filter named name served through conn.
+func NewClient(conn grpc.ClientConnInterface, name string) *Client {
+	return &Client{
+		client: pb.NewBloomFilterClient(conn),
+		name:   name,
+	}
+}
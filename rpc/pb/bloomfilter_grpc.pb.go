@@ -0,0 +1,326 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             (unknown)
+// source: bloomfilter.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// BloomFilterClient is the client API for BloomFilter service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type BloomFilterClient interface {
+	// Add adds data into the filter.
+	Add(ctx context.Context, in *AddRequest, opts ...grpc.CallOption) (*AddResponse, error)
+	// Exist returns whether data is in the filter.
+	Exist(ctx context.Context, in *ExistRequest, opts ...grpc.CallOption) (*ExistResponse, error)
+	// BulkAdd adds data of all requests, the response is sent once the client closes the stream.
+	BulkAdd(ctx context.Context, opts ...grpc.CallOption) (BloomFilter_BulkAddClient, error)
+	// BulkExist responds to each request in order.
+	BulkExist(ctx context.Context, opts ...grpc.CallOption) (BloomFilter_BulkExistClient, error)
+	// Stats returns statistics of the filter.
+	Stats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (*StatsResponse, error)
+}
+
+type bloomFilterClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewBloomFilterClient(cc grpc.ClientConnInterface) BloomFilterClient {
+	return &bloomFilterClient{cc}
+}
+
+func (c *bloomFilterClient) Add(ctx context.Context, in *AddRequest, opts ...grpc.CallOption) (*AddResponse, error) {
+	out := new(AddResponse)
+	err := c.cc.Invoke(ctx, "/bloomfilter.v1.BloomFilter/Add", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *bloomFilterClient) Exist(ctx context.Context, in *ExistRequest, opts ...grpc.CallOption) (*ExistResponse, error) {
+	out := new(ExistResponse)
+	err := c.cc.Invoke(ctx, "/bloomfilter.v1.BloomFilter/Exist", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *bloomFilterClient) BulkAdd(ctx context.Context, opts ...grpc.CallOption) (BloomFilter_BulkAddClient, error) {
+	stream, err := c.cc.NewStream(ctx, &BloomFilter_ServiceDesc.Streams[0], "/bloomfilter.v1.BloomFilter/BulkAdd", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &bloomFilterBulkAddClient{stream}
+	return x, nil
+}
+
+type BloomFilter_BulkAddClient interface {
+	Send(*AddRequest) error
+	CloseAndRecv() (*BulkAddResponse, error)
+	grpc.ClientStream
+}
+
+type bloomFilterBulkAddClient struct {
+	grpc.ClientStream
+}
+
+func (x *bloomFilterBulkAddClient) Send(m *AddRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *bloomFilterBulkAddClient) CloseAndRecv() (*BulkAddResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(BulkAddResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *bloomFilterClient) BulkExist(ctx context.Context, opts ...grpc.CallOption) (BloomFilter_BulkExistClient, error) {
+	stream, err := c.cc.NewStream(ctx, &BloomFilter_ServiceDesc.Streams[1], "/bloomfilter.v1.BloomFilter/BulkExist", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &bloomFilterBulkExistClient{stream}
+	return x, nil
+}
+
+type BloomFilter_BulkExistClient interface {
+	Send(*ExistRequest) error
+	Recv() (*ExistResponse, error)
+	grpc.ClientStream
+}
+
+type bloomFilterBulkExistClient struct {
+	grpc.ClientStream
+}
+
+func (x *bloomFilterBulkExistClient) Send(m *ExistRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *bloomFilterBulkExistClient) Recv() (*ExistResponse, error) {
+	m := new(ExistResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *bloomFilterClient) Stats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (*StatsResponse, error) {
+	out := new(StatsResponse)
+	err := c.cc.Invoke(ctx, "/bloomfilter.v1.BloomFilter/Stats", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// BloomFilterServer is the server API for BloomFilter service.
+// All implementations must embed UnimplementedBloomFilterServer
+// for forward compatibility
+type BloomFilterServer interface {
+	// Add adds data into the filter.
+	Add(context.Context, *AddRequest) (*AddResponse, error)
+	// Exist returns whether data is in the filter.
+	Exist(context.Context, *ExistRequest) (*ExistResponse, error)
+	// BulkAdd adds data of all requests, the response is sent once the client closes the stream.
+	BulkAdd(BloomFilter_BulkAddServer) error
+	// BulkExist responds to each request in order.
+	BulkExist(BloomFilter_BulkExistServer) error
+	// Stats returns statistics of the filter.
+	Stats(context.Context, *StatsRequest) (*StatsResponse, error)
+	mustEmbedUnimplementedBloomFilterServer()
+}
+
+// UnimplementedBloomFilterServer must be embedded to have forward compatible implementations.
+type UnimplementedBloomFilterServer struct {
+}
+
+func (UnimplementedBloomFilterServer) Add(context.Context, *AddRequest) (*AddResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Add not implemented")
+}
+func (UnimplementedBloomFilterServer) Exist(context.Context, *ExistRequest) (*ExistResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Exist not implemented")
+}
+func (UnimplementedBloomFilterServer) BulkAdd(BloomFilter_BulkAddServer) error {
+	return status.Errorf(codes.Unimplemented, "method BulkAdd not implemented")
+}
+func (UnimplementedBloomFilterServer) BulkExist(BloomFilter_BulkExistServer) error {
+	return status.Errorf(codes.Unimplemented, "method BulkExist not implemented")
+}
+func (UnimplementedBloomFilterServer) Stats(context.Context, *StatsRequest) (*StatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Stats not implemented")
+}
+func (UnimplementedBloomFilterServer) mustEmbedUnimplementedBloomFilterServer() {}
+
+// UnsafeBloomFilterServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to BloomFilterServer will
+// result in compilation errors.
+type UnsafeBloomFilterServer interface {
+	mustEmbedUnimplementedBloomFilterServer()
+}
+
+func RegisterBloomFilterServer(s grpc.ServiceRegistrar, srv BloomFilterServer) {
+	s.RegisterService(&BloomFilter_ServiceDesc, srv)
+}
+
+func _BloomFilter_Add_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BloomFilterServer).Add(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/bloomfilter.v1.BloomFilter/Add",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BloomFilterServer).Add(ctx, req.(*AddRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BloomFilter_Exist_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExistRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BloomFilterServer).Exist(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/bloomfilter.v1.BloomFilter/Exist",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BloomFilterServer).Exist(ctx, req.(*ExistRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BloomFilter_BulkAdd_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(BloomFilterServer).BulkAdd(&bloomFilterBulkAddServer{stream})
+}
+
+type BloomFilter_BulkAddServer interface {
+	SendAndClose(*BulkAddResponse) error
+	Recv() (*AddRequest, error)
+	grpc.ServerStream
+}
+
+type bloomFilterBulkAddServer struct {
+	grpc.ServerStream
+}
+
+func (x *bloomFilterBulkAddServer) SendAndClose(m *BulkAddResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *bloomFilterBulkAddServer) Recv() (*AddRequest, error) {
+	m := new(AddRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _BloomFilter_BulkExist_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(BloomFilterServer).BulkExist(&bloomFilterBulkExistServer{stream})
+}
+
+type BloomFilter_BulkExistServer interface {
+	Send(*ExistResponse) error
+	Recv() (*ExistRequest, error)
+	grpc.ServerStream
+}
+
+type bloomFilterBulkExistServer struct {
+	grpc.ServerStream
+}
+
+func (x *bloomFilterBulkExistServer) Send(m *ExistResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *bloomFilterBulkExistServer) Recv() (*ExistRequest, error) {
+	m := new(ExistRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _BloomFilter_Stats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BloomFilterServer).Stats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/bloomfilter.v1.BloomFilter/Stats",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BloomFilterServer).Stats(ctx, req.(*StatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// BloomFilter_ServiceDesc is the grpc.ServiceDesc for BloomFilter service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var BloomFilter_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "bloomfilter.v1.BloomFilter",
+	HandlerType: (*BloomFilterServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Add",
+			Handler:    _BloomFilter_Add_Handler,
+		},
+		{
+			MethodName: "Exist",
+			Handler:    _BloomFilter_Exist_Handler,
+		},
+		{
+			MethodName: "Stats",
+			Handler:    _BloomFilter_Stats_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "BulkAdd",
+			Handler:       _BloomFilter_BulkAdd_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "BulkExist",
+			Handler:       _BloomFilter_BulkExist_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "bloomfilter.proto",
+}
@@ -0,0 +1,4 @@
+// Package pb is generated from bloomfilter.proto by protoc-gen-go and protoc-gen-go-grpc.
+package pb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative bloomfilter.proto
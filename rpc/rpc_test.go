@@ -0,0 +1,176 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/x0rworld/go-bloomfilter/config"
+	"github.com/x0rworld/go-bloomfilter/filter"
+	"github.com/x0rworld/go-bloomfilter/manager"
+	"github.com/x0rworld/go-bloomfilter/rpc/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"net"
+	"testing"
+)
+
+// the client implements filter.Filter
+var _ filter.Filter = (*Client)(nil)
+
+func newTestConn(t *testing.T, fn FilterFunc, opts ...ServerOption) *grpc.ClientConn {
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	pb.RegisterBloomFilterServer(s, NewServer(fn, opts...))
+	go func() {
+		_ = s.Serve(lis)
+	}()
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+		s.Stop()
+	})
+	return conn
+}
+
+func newTestManager(t *testing.T) *manager.Manager {
+	m, err := manager.NewManager(context.Background(), config.FactoryConfig{
+		FilterConfig: config.FilterConfig{
+			BitmapConfig: config.BitmapConfig{
+				Type: config.BitmapTypeInMemory,
+			},
+			M: 1000,
+			K: 3,
+		},
+	})
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		_ = m.Close()
+	})
+	return m
+}
+
+func TestClient(t *testing.T) {
+	m := newTestManager(t)
+	conn := newTestConn(t, m.Get)
+	c := NewClient(conn, "a")
+
+	assert.NoError(t, c.Add("hello"))
+	exist, err := c.Exist("hello")
+	assert.NoError(t, err)
+	assert.True(t, exist)
+	exist, err = c.Exist("world")
+	assert.NoError(t, err)
+	assert.False(t, exist)
+
+	// filters are isolated by name
+	exist, err = NewClient(conn, "b").Exist("hello")
+	assert.NoError(t, err)
+	assert.False(t, exist)
+
+	// data is not necessarily valid utf-8
+	assert.NoError(t, c.Add("\xff\xfe"))
+	exist, err = c.Exist("\xff\xfe")
+	assert.NoError(t, err)
+	assert.True(t, exist)
+
+	ratio, err := c.FillRatio()
+	assert.NoError(t, err)
+	assert.Greater(t, ratio, 0.0)
+}
+
+func TestClient_bulk(t *testing.T) {
+	m := newTestManager(t)
+	conn := newTestConn(t, m.Get)
+	c := NewClient(conn, "a")
+	ctx := context.Background()
+
+	assert.NoError(t, c.BulkAdd(ctx, []string{"x", "y", "z"}))
+	exist, err := c.BulkExist(ctx, []string{"x", "hello", "z", "y"})
+	assert.NoError(t, err)
+	assert.Equal(t, []bool{true, false, true, true}, exist)
+
+	exist, err = c.BulkExist(ctx, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, exist)
+
+	// the server stops at the first error
+	c = NewClient(conn, "")
+	assert.Equal(t, codes.InvalidArgument, status.Code(c.BulkAdd(ctx, []string{"x", "y"})))
+	_, err = c.BulkExist(ctx, []string{"x", "y"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestServer_errors(t *testing.T) {
+	errBroken := errors.New("broken")
+	conn := newTestConn(t, func(name string) (filter.Filter, error) {
+		switch name {
+		case "closed":
+			return nil, manager.ErrManagerClosed
+		case "broken":
+			return nil, errBroken
+		}
+		return brokenFilter{}, nil
+	})
+
+	tests := []struct {
+		name string
+		want codes.Code
+	}{
+		{name: "", want: codes.InvalidArgument},
+		{name: "closed", want: codes.Unavailable},
+		{name: "broken", want: codes.Internal},
+		{name: "filter", want: codes.Internal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewClient(conn, tt.name)
+			assert.Equal(t, tt.want, status.Code(c.Add("x")))
+			_, err := c.Exist("x")
+			assert.Equal(t, tt.want, status.Code(err))
+		})
+	}
+
+	// fill ratio is not supported
+	_, err := NewClient(conn, "filter").FillRatio()
+	assert.ErrorIs(t, err, filter.ErrUnsupported)
+}
+
+type brokenFilter struct{}
+
+func (brokenFilter) Exist(string) (bool, error) {
+	return false, errors.New("broken")
+}
+
+func (brokenFilter) Add(string) error {
+	return errors.New("broken")
+}
+
+func TestServer_lookup(t *testing.T) {
+	m := newTestManager(t)
+	conn := newTestConn(t, m.Get, ServerLookupFunc(m.Lookup))
+	ctx := context.Background()
+
+	// read-only calls don't create filters
+	c := NewClient(conn, "a")
+	_, err := c.Exist("hello")
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = c.BulkExist(ctx, []string{"hello"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = c.FillRatio()
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Equal(t, 0, m.Len())
+
+	assert.NoError(t, c.Add("hello"))
+	exist, err := c.Exist("hello")
+	assert.NoError(t, err)
+	assert.True(t, exist)
+}
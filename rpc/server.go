@@ -0,0 +1,168 @@
+// Package rpc serves filters by gRPC, see pb/bloomfilter.proto for the service definition.
+package rpc
+
+import (
+	"context"
+	"errors"
+	"github.com/x0rworld/go-bloomfilter/filter"
+	"github.com/x0rworld/go-bloomfilter/manager"
+	"github.com/x0rworld/go-bloomfilter/rpc/pb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"io"
+)
+
+// FilterFunc returns the filter named name, e.g. manager.Manager.Get.
+type FilterFunc func(name string) (filter.Filter, error)
+
+// Server implements pb.BloomFilterServer by filters of FilterFunc.
+type Server struct {
+	pb.UnimplementedBloomFilterServer
+	filterOf FilterFunc
+	// lookup returns the filter for read-only calls, it's filterOf unless ServerLookupFunc is given.
+	lookup FilterFunc
+}
+
+type ServerOption func(*Server)
+
+func (s *Server) Add(ctx context.Context, req *pb.AddRequest) (*pb.AddResponse, error) {
+	f, err := s.filter(req.GetName(), s.filterOf)
+	if err != nil {
+		return nil, err
+	}
+	if err := filter.AddContext(ctx, f, string(req.GetData())); err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.AddResponse{}, nil
+}
+
+func (s *Server) Exist(ctx context.Context, req *pb.ExistRequest) (*pb.ExistResponse, error) {
+	f, err := s.filter(req.GetName(), s.lookup)
+	if err != nil {
+		return nil, err
+	}
+	exist, err := filter.ExistContext(ctx, f, string(req.GetData()))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.ExistResponse{Exist: exist}, nil
+}
+
+// BulkAdd adds data of requests which may name different filters, it stops at the first error.
+func (s *Server) BulkAdd(stream pb.BloomFilter_BulkAddServer) error {
+	var count uint64
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(&pb.BulkAddResponse{Count: count})
+		}
+		if err != nil {
+			return err
+		}
+		f, err := s.filter(req.GetName(), s.filterOf)
+		if err != nil {
+			return err
+		}
+		if err := filter.AddContext(stream.Context(), f, string(req.GetData())); err != nil {
+			return toStatus(err)
+		}
+		count++
+	}
+}
+
+// BulkExist responds to requests which may name different filters in order, it stops at the first error.
+func (s *Server) BulkExist(stream pb.BloomFilter_BulkExistServer) error {
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		f, err := s.filter(req.GetName(), s.lookup)
+		if err != nil {
+			return err
+		}
+		exist, err := filter.ExistContext(stream.Context(), f, string(req.GetData()))
+		if err != nil {
+			return toStatus(err)
+		}
+		if err := stream.Send(&pb.ExistResponse{Exist: exist}); err != nil {
+			return err
+		}
+	}
+}
+
+// Stats responds fill ratio if the filter or the one decorated by it is filter.FillRatioReporter.
+func (s *Server) Stats(_ context.Context, req *pb.StatsRequest) (*pb.StatsResponse, error) {
+	f, err := s.filter(req.GetName(), s.lookup)
+	if err != nil {
+		return nil, err
+	}
+	resp := &pb.StatsResponse{Name: req.GetName()}
+	for f != nil {
+		if fr, ok := f.(filter.FillRatioReporter); ok {
+			ratio, err := fr.FillRatio()
+			if err == nil {
+				resp.FillRatio = &ratio
+			} else if !errors.Is(err, filter.ErrUnsupported) {
+				return nil, toStatus(err)
+			}
+			break
+		}
+		u, ok := f.(interface{ Unwrap() filter.Filter })
+		if !ok {
+			break
+		}
+		f = u.Unwrap()
+	}
+	return resp, nil
+}
+
+func (s *Server) filter(name string, fn FilterFunc) (filter.Filter, error) {
+	if name == "" {
+		return nil, status.Error(codes.InvalidArgument, "empty name")
+	}
+	f, err := fn(name)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return f, nil
+}
+
+// toStatus converts err into gRPC status by the known errors, the others are codes.Internal.
+func toStatus(err error) error {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	case errors.Is(err, manager.ErrManagerClosed):
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, manager.ErrFilterNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, manager.ErrOverBudget):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, filter.ErrUnsupported):
+		return status.Error(codes.Unimplemented, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// ServerLookupFunc returns filters of Exist, BulkExist and Stats by fn instead, e.g. manager.Manager.Lookup,
+// so that read-only calls don't create filters of unknown names.
+func ServerLookupFunc(fn FilterFunc) ServerOption {
+	return func(s *Server) {
+		s.lookup = fn
+	}
+}
+
+// NewServer returns Server serving filters of fn, it's registered by pb.RegisterBloomFilterServer.
+func NewServer(fn FilterFunc, opts ...ServerOption) *Server {
+	s := &Server{filterOf: fn, lookup: fn}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
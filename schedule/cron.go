@@ -0,0 +1,235 @@
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// searchDays is the maximum number of days to search activation time, it covers leap years.
+const searchDays = 366 * 5
+
+var (
+	ErrInvalidSpec = errors.New("invalid schedule spec")
+
+	descriptors = map[string]string{
+		"@yearly":   "0 0 1 1 *",
+		"@annually": "0 0 1 1 *",
+		"@monthly":  "0 0 1 * *",
+		"@weekly":   "0 0 * * 0",
+		"@daily":    "0 0 * * *",
+		"@midnight": "0 0 * * *",
+		"@hourly":   "0 * * * *",
+	}
+	monthNames = map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}
+	dowNames = map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}
+)
+
+type field struct {
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minuteField = field{min: 0, max: 59}
+	hourField   = field{min: 0, max: 23}
+	domField    = field{min: 1, max: 31}
+	monthField  = field{min: 1, max: 12, names: monthNames}
+	// 7 is accepted as sunday as well.
+	dowField = field{min: 0, max: 7, names: dowNames}
+)
+
+// Cron activates by standard 5-field cron expression (minute, hour, day of month, month, day of week) in location.
+type Cron struct {
+	minute, hour, dom, month, dow uint64
+	// domStar & dowStar indicate whether the field is `*`,
+	// if both are restricted, it activates when either day of month or day of week matches.
+	domStar, dowStar bool
+	loc              *time.Location
+}
+
+func (c *Cron) Next(t time.Time) time.Time {
+	t = t.In(c.loc)
+	y, m, d := t.Date()
+	for i := 0; i < searchDays; i++ {
+		day := time.Date(y, m, d+i, 0, 0, 0, 0, c.loc)
+		if !c.matchDay(day) {
+			continue
+		}
+		for h := hourField.min; h <= hourField.max; h++ {
+			if !has(c.hour, h) {
+				continue
+			}
+			for min := minuteField.min; min <= minuteField.max; min++ {
+				if !has(c.minute, min) {
+					continue
+				}
+				at := time.Date(day.Year(), day.Month(), day.Day(), h, min, 0, 0, c.loc)
+				if at.After(t) {
+					return at
+				}
+			}
+		}
+	}
+	return time.Time{}
+}
+
+func (c *Cron) Prev(t time.Time) time.Time {
+	t = t.In(c.loc)
+	y, m, d := t.Date()
+	for i := 0; i < searchDays; i++ {
+		day := time.Date(y, m, d-i, 0, 0, 0, 0, c.loc)
+		if !c.matchDay(day) {
+			continue
+		}
+		for h := hourField.max; h >= hourField.min; h-- {
+			if !has(c.hour, h) {
+				continue
+			}
+			for min := minuteField.max; min >= minuteField.min; min-- {
+				if !has(c.minute, min) {
+					continue
+				}
+				at := time.Date(day.Year(), day.Month(), day.Day(), h, min, 0, 0, c.loc)
+				if !at.After(t) {
+					return at
+				}
+			}
+		}
+	}
+	return time.Time{}
+}
+
+func (c *Cron) matchDay(day time.Time) bool {
+	if !has(c.month, int(day.Month())) {
+		return false
+	}
+	domMatched := has(c.dom, day.Day())
+	dowMatched := has(c.dow, int(day.Weekday()))
+	if !c.domStar && !c.dowStar {
+		return domMatched || dowMatched
+	}
+	return domMatched && dowMatched
+}
+
+func has(bits uint64, v int) bool {
+	return bits&(1<<uint(v)) != 0
+}
+
+// Parse returns Schedule by spec in loc, loc is UTC if it's nil. Supported spec:
+//   - standard 5-field cron expression such as `0 0 * * MON` (every Monday at midnight).
+//   - descriptors: `@yearly`, `@annually`, `@monthly`, `@weekly`, `@daily`, `@midnight` and `@hourly`.
+//   - `@every <duration>` such as `@every 3h`, it's the same with Every.
+func Parse(spec string, loc *time.Location) (Schedule, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	spec = strings.TrimSpace(spec)
+	if strings.HasPrefix(spec, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(spec, "@every ")))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidSpec, err)
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("%w: duration <= 0", ErrInvalidSpec)
+		}
+		return NewEvery(d), nil
+	}
+	if expr, ok := descriptors[strings.ToLower(spec)]; ok {
+		spec = expr
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("%w: expected 5 fields, got %d", ErrInvalidSpec, len(fields))
+	}
+	c := &Cron{
+		domStar: fields[2] == "*",
+		dowStar: fields[4] == "*",
+		loc:     loc,
+	}
+	var err error
+	for i, dst := range []struct {
+		bits *uint64
+		f    field
+	}{
+		{&c.minute, minuteField},
+		{&c.hour, hourField},
+		{&c.dom, domField},
+		{&c.month, monthField},
+		{&c.dow, dowField},
+	} {
+		*dst.bits, err = parseField(fields[i], dst.f)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// fold 7 into 0, both are sunday.
+	if has(c.dow, 7) {
+		c.dow |= 1
+	}
+	if c.Next(time.Date(2000, 1, 1, 0, 0, 0, 0, loc)).IsZero() {
+		return nil, fmt.Errorf("%w: %q never activates", ErrInvalidSpec, spec)
+	}
+	return c, nil
+}
+
+// parseField parses comma-separated list of `*`, `a`, `a-b`, with optional step `/n`.
+func parseField(expr string, f field) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(expr, ",") {
+		rng, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			s, err := strconv.Atoi(part[i+1:])
+			if err != nil || s <= 0 {
+				return 0, fmt.Errorf("%w: invalid step %q", ErrInvalidSpec, part)
+			}
+			rng, step = part[:i], s
+		}
+		lo, hi := f.min, f.max
+		if rng != "*" {
+			bounds := strings.SplitN(rng, "-", 2)
+			var err error
+			if lo, err = f.value(bounds[0]); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = f.value(bounds[1]); err != nil {
+					return 0, err
+				}
+			} else if step > 1 {
+				// `a/n` means from a to max by n.
+				hi = f.max
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("%w: invalid range %q", ErrInvalidSpec, rng)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (f field) value(s string) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid value %q", ErrInvalidSpec, s)
+	}
+	if v < f.min || v > f.max {
+		return 0, fmt.Errorf("%w: value %d out of range [%d, %d]", ErrInvalidSpec, v, f.min, f.max)
+	}
+	return v, nil
+}
@@ -0,0 +1,111 @@
+package schedule
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone database is unavailable: %v", err)
+	}
+	// 2022-09-06 (Tuesday) 08:24:31 in New York
+	now := time.Date(2022, 9, 6, 8, 24, 31, 0, ny)
+	tests := []struct {
+		name     string
+		spec     string
+		loc      *time.Location
+		wantErr  bool
+		wantNext time.Time
+		wantPrev time.Time
+	}{
+		{
+			name:     "midnight in New York",
+			spec:     "0 0 * * *",
+			loc:      ny,
+			wantNext: time.Date(2022, 9, 7, 0, 0, 0, 0, ny),
+			wantPrev: time.Date(2022, 9, 6, 0, 0, 0, 0, ny),
+		},
+		{
+			name:     "every Monday",
+			spec:     "0 0 * * MON",
+			loc:      ny,
+			wantNext: time.Date(2022, 9, 12, 0, 0, 0, 0, ny),
+			wantPrev: time.Date(2022, 9, 5, 0, 0, 0, 0, ny),
+		},
+		{
+			name:     "descriptor: weekly",
+			spec:     "@weekly",
+			loc:      ny,
+			wantNext: time.Date(2022, 9, 11, 0, 0, 0, 0, ny),
+			wantPrev: time.Date(2022, 9, 4, 0, 0, 0, 0, ny),
+		},
+		{
+			name:     "step and range",
+			spec:     "*/15 8-9 * * *",
+			loc:      ny,
+			wantNext: time.Date(2022, 9, 6, 8, 30, 0, 0, ny),
+			wantPrev: time.Date(2022, 9, 6, 8, 15, 0, 0, ny),
+		},
+		{
+			name:     "day of month or day of week",
+			spec:     "0 0 1 * SUN",
+			loc:      ny,
+			wantNext: time.Date(2022, 9, 11, 0, 0, 0, 0, ny),
+			wantPrev: time.Date(2022, 9, 4, 0, 0, 0, 0, ny),
+		},
+		{
+			name:     "sunday as 7",
+			spec:     "0 0 * * 7",
+			loc:      ny,
+			wantNext: time.Date(2022, 9, 11, 0, 0, 0, 0, ny),
+			wantPrev: time.Date(2022, 9, 4, 0, 0, 0, 0, ny),
+		},
+		{
+			name:     "nil location is UTC",
+			spec:     "0 0 * * *",
+			wantNext: time.Date(2022, 9, 7, 0, 0, 0, 0, time.UTC),
+			wantPrev: time.Date(2022, 9, 6, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "every",
+			spec:     "@every 3h",
+			wantNext: now.Add(3 * time.Hour).Truncate(3 * time.Hour),
+			wantPrev: now.Truncate(3 * time.Hour),
+		},
+		{
+			name:    "invalid: fields",
+			spec:    "0 0 * *",
+			wantErr: true,
+		},
+		{
+			name:    "invalid: out of range",
+			spec:    "60 0 * * *",
+			wantErr: true,
+		},
+		{
+			name:    "invalid: never activates",
+			spec:    "0 0 30 2 *",
+			wantErr: true,
+		},
+		{
+			name:    "invalid: every",
+			spec:    "@every -1h",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.spec, tt.loc)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidSpec)
+				return
+			}
+			assert.NoError(t, err)
+			assert.True(t, tt.wantNext.Equal(s.Next(now)), "Next() got = %v, want %v", s.Next(now), tt.wantNext)
+			assert.True(t, tt.wantPrev.Equal(s.Prev(now)), "Prev() got = %v, want %v", s.Prev(now), tt.wantPrev)
+		})
+	}
+}
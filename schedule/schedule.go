@@ -0,0 +1,32 @@
+// Package schedule provides activation times for rotation, either by fixed frequency or by cron expression.
+package schedule
+
+import (
+	"time"
+)
+
+type Schedule interface {
+	// Next returns the first activation time after t, or zero time if there is no activation.
+	Next(t time.Time) time.Time
+	// Prev returns the latest activation time at or before t, or zero time if there is no activation.
+	Prev(t time.Time) time.Time
+}
+
+// Every activates at every multiple of d since the zero time of UTC.
+type Every struct {
+	d time.Duration
+}
+
+func (e *Every) Next(t time.Time) time.Time {
+	return t.Add(e.d).Truncate(e.d)
+}
+
+func (e *Every) Prev(t time.Time) time.Time {
+	return t.Truncate(e.d)
+}
+
+// NewEvery returns Every which activates by d.
+// It's the same way with rotation by config.RotatorConfig.Freq.
+func NewEvery(d time.Duration) *Every {
+	return &Every{d: d}
+}
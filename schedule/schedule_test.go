@@ -0,0 +1,19 @@
+package schedule
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestEvery(t *testing.T) {
+	e := NewEvery(3 * time.Hour)
+	now := time.Date(2022, 9, 6, 8, 24, 31, 0, time.UTC)
+	assert.Equal(t, time.Date(2022, 9, 6, 9, 0, 0, 0, time.UTC), e.Next(now))
+	assert.Equal(t, time.Date(2022, 9, 6, 6, 0, 0, 0, time.UTC), e.Prev(now))
+
+	// activation time itself is the prev one
+	at := time.Date(2022, 9, 6, 9, 0, 0, 0, time.UTC)
+	assert.Equal(t, at, e.Prev(at))
+	assert.Equal(t, time.Date(2022, 9, 6, 12, 0, 0, 0, time.UTC), e.Next(at))
+}
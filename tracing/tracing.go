@@ -0,0 +1,53 @@
+// Package tracing provides OpenTelemetry tracer used by filter, bitmap and rotator.
+//
+// Tracing is optional: spans are recorded by the global trace.TracerProvider of OpenTelemetry,
+// which is no-op unless it's set by otel.SetTracerProvider or SetTracerProvider of this package.
+package tracing
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"sync/atomic"
+)
+
+const instrumentationName = "github.com/x0rworld/go-bloomfilter"
+
+// Attribute keys of spans.
+const (
+	AttrFilterM           = "bloomfilter.m"
+	AttrFilterK           = "bloomfilter.k"
+	AttrRedisKey          = "redis.key"
+	AttrRedisPipelineSize = "redis.pipeline.size"
+	AttrRotatorGeneration = "rotator.generation"
+	AttrRotatorLookup     = "rotator.lookup"
+)
+
+// providerHolder wraps trace.TracerProvider since atomic.Value requires the consistent concrete type.
+type providerHolder struct {
+	tp trace.TracerProvider
+}
+
+var provider atomic.Value
+
+// SetTracerProvider sets tp used by this library instead of the global one of OpenTelemetry.
+func SetTracerProvider(tp trace.TracerProvider) {
+	provider.Store(providerHolder{tp: tp})
+}
+
+// Tracer returns tracer of this library.
+func Tracer() trace.Tracer {
+	if h, ok := provider.Load().(providerHolder); ok && h.tp != nil {
+		return h.tp.Tracer(instrumentationName)
+	}
+	return otel.Tracer(instrumentationName)
+}
+
+// End records err into span if present, then ends span.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,96 @@
+package tracing_test
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/x0rworld/go-bloomfilter/bitmap"
+	"github.com/x0rworld/go-bloomfilter/config"
+	"github.com/x0rworld/go-bloomfilter/filter"
+	"github.com/x0rworld/go-bloomfilter/filter/rotator"
+	"github.com/x0rworld/go-bloomfilter/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"testing"
+)
+
+func setupExporter(t *testing.T) *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	tracing.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	t.Cleanup(func() { tracing.SetTracerProvider(nil) })
+	return exporter
+}
+
+func attrs(s tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	m := map[attribute.Key]attribute.Value{}
+	for _, kv := range s.Attributes {
+		m[kv.Key] = kv.Value
+	}
+	return m
+}
+
+func TestSpans(t *testing.T) {
+	exporter := setupExporter(t)
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+
+	r, err := rotator.NewRotator(context.Background(), config.RotatorConfig{
+		Enable: true,
+		Mode:   config.RotatorModeManual,
+	}, func(ctx context.Context) (filter.Filter, error) {
+		bm, err := bitmap.NewRedis(ctx, client, "test-tracing", 100)
+		if err != nil {
+			return nil, err
+		}
+		return filter.NewBloomFilter(bm, 100, 3), nil
+	})
+	assert.NoError(t, err)
+	err = r.Rotate(context.Background())
+	assert.NoError(t, err)
+	exporter.Reset()
+
+	_, err = r.ExistContext(context.Background(), "hello")
+	assert.NoError(t, err)
+
+	// spans end from the innermost one: Redis.CheckBits -> BloomFilter.Exist -> Rotator.Exist
+	spans := exporter.GetSpans()
+	if !assert.Len(t, spans, 3) {
+		return
+	}
+	redisSpan, bfSpan, rotatorSpan := spans[0], spans[1], spans[2]
+	assert.Equal(t, "Redis.CheckBits", redisSpan.Name)
+	assert.Equal(t, "BloomFilter.Exist", bfSpan.Name)
+	assert.Equal(t, "Rotator.Exist", rotatorSpan.Name)
+	assert.Equal(t, bfSpan.SpanContext.SpanID(), redisSpan.Parent.SpanID())
+	assert.Equal(t, rotatorSpan.SpanContext.SpanID(), bfSpan.Parent.SpanID())
+
+	assert.Equal(t, "test-tracing", attrs(redisSpan)[tracing.AttrRedisKey].AsString())
+	assert.Equal(t, int64(3), attrs(redisSpan)[tracing.AttrRedisPipelineSize].AsInt64())
+	assert.Equal(t, int64(3), attrs(bfSpan)[tracing.AttrFilterK].AsInt64())
+	assert.Equal(t, int64(100), attrs(bfSpan)[tracing.AttrFilterM].AsInt64())
+	assert.Equal(t, int64(1), attrs(rotatorSpan)[tracing.AttrRotatorGeneration].AsInt64())
+}
+
+func TestSpans_error(t *testing.T) {
+	exporter := setupExporter(t)
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	bm, err := bitmap.NewRedis(context.Background(), client, "test-tracing-error", 100)
+	assert.NoError(t, err)
+	exporter.Reset()
+
+	mr.Close()
+	err = filter.NewBloomFilter(bm, 100, 3).AddContext(context.Background(), "hello")
+	assert.Error(t, err)
+
+	spans := exporter.GetSpans()
+	if !assert.Len(t, spans, 2) {
+		return
+	}
+	for _, s := range spans {
+		assert.Equal(t, codes.Error, s.Status.Code)
+	}
+}